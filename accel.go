@@ -0,0 +1,578 @@
+package macho
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blacktop/go-dwarf"
+)
+
+const (
+	appleHashMagic       = 0x48415348 // "HASH"
+	appleHashEmptyBucket = 0xffffffff
+)
+
+// Apple accelerator table atom types.
+const (
+	appleAtomDIEOffset = 1 // DW_ATOM_die_offset
+	appleAtomCUOffset  = 2 // DW_ATOM_cu_offset
+	appleAtomDIETag    = 3 // DW_ATOM_die_tag
+)
+
+// __debug_names index attributes.
+const (
+	debugNamesIdxCompileUnit = 1 // DW_IDX_compile_unit
+	debugNamesIdxDIEOffset   = 3 // DW_IDX_die_offset
+)
+
+// DWARF attribute forms used by the accelerator tables.
+const (
+	formData2       = 0x05
+	formData4       = 0x06
+	formData8       = 0x07
+	formData1       = 0x0b
+	formFlag        = 0x0c
+	formSdata       = 0x0d
+	formUdata       = 0x0f
+	formRef1        = 0x11
+	formRef2        = 0x12
+	formRef4        = 0x13
+	formRef8        = 0x14
+	formRefUdata    = 0x15
+	formFlagPresent = 0x19
+)
+
+// An AccelEntry is a DIE found by name in an accelerator table.
+type AccelEntry struct {
+	Offset dwarf.Offset // offset of the DIE in __debug_info
+	Tag    dwarf.Tag    // DIE tag, 0 if the table does not record it
+}
+
+// An AppleAccelTable is one of the Apple DWARF accelerator tables
+// (__apple_names, __apple_types, __apple_namespac or __apple_objc),
+// a hash table from names to the DIEs that have them.
+type AppleAccelTable struct {
+	bo      binary.ByteOrder
+	dat     []byte
+	str     []byte // __debug_str
+	base    uint32 // DIE offset base
+	atoms   [][2]uint16
+	buckets []uint32
+	hashes  []uint32
+	offsets []uint32
+}
+
+// NewAppleAccelTable parses an Apple accelerator table; str is the __debug_str section its names point into.
+func NewAppleAccelTable(dat, str []byte, bo binary.ByteOrder) (*AppleAccelTable, error) {
+	var hdr struct {
+		Magic            uint32
+		Version          uint16
+		HashFunction     uint16
+		BucketCount      uint32
+		HashesCount      uint32
+		HeaderDataLength uint32
+		DieOffsetBase    uint32
+		AtomCount        uint32
+	}
+	r := bytes.NewReader(dat)
+	if err := binary.Read(r, bo, &hdr); err != nil {
+		return nil, fmt.Errorf("failed to read accelerator table header: %v", err)
+	}
+	if hdr.Magic != appleHashMagic {
+		return nil, fmt.Errorf("invalid accelerator table magic %#x", hdr.Magic)
+	}
+	if hdr.HashFunction != 0 {
+		return nil, fmt.Errorf("unsupported accelerator table hash function %d", hdr.HashFunction)
+	}
+
+	// the header data is the DIE offset base, the atom count and the atoms
+	if uint64(hdr.AtomCount)*4+8 > uint64(hdr.HeaderDataLength) || 20+uint64(hdr.HeaderDataLength) > uint64(len(dat)) {
+		return nil, fmt.Errorf("invalid accelerator table header data length %#x for %d atoms", hdr.HeaderDataLength, hdr.AtomCount)
+	}
+
+	t := &AppleAccelTable{bo: bo, dat: dat, str: str, base: hdr.DieOffsetBase}
+	t.atoms = make([][2]uint16, hdr.AtomCount)
+	if err := binary.Read(r, bo, t.atoms); err != nil {
+		return nil, fmt.Errorf("failed to read accelerator table atoms: %v", err)
+	}
+	// the header data may be padded
+	if _, err := r.Seek(int64(20+hdr.HeaderDataLength), 0); err != nil {
+		return nil, err
+	}
+	if uint64(hdr.BucketCount)*4+uint64(hdr.HashesCount)*8 > uint64(r.Len()) {
+		return nil, fmt.Errorf("accelerator table of %d buckets and %d hashes is larger than its section", hdr.BucketCount, hdr.HashesCount)
+	}
+	t.buckets = make([]uint32, hdr.BucketCount)
+	t.hashes = make([]uint32, hdr.HashesCount)
+	t.offsets = make([]uint32, hdr.HashesCount)
+	for _, v := range [][]uint32{t.buckets, t.hashes, t.offsets} {
+		if err := binary.Read(r, bo, v); err != nil {
+			return nil, fmt.Errorf("failed to read accelerator table: %v", err)
+		}
+	}
+	return t, nil
+}
+
+// djbHash is the hash function of the Apple accelerator tables.
+func djbHash(s string) uint32 {
+	h := uint32(5381)
+	for i := 0; i < len(s); i++ {
+		h = h*33 + uint32(s[i])
+	}
+	return h
+}
+
+// Lookup returns the DIEs with the given name.
+func (t *AppleAccelTable) Lookup(name string) ([]AccelEntry, error) {
+	if len(t.buckets) == 0 {
+		return nil, nil
+	}
+	h := djbHash(name)
+	bucket := h % uint32(len(t.buckets))
+	idx := t.buckets[bucket]
+	if idx == appleHashEmptyBucket {
+		return nil, nil
+	}
+	var entries []AccelEntry
+	for i := idx; i < uint32(len(t.hashes)) && t.hashes[i]%uint32(len(t.buckets)) == bucket; i++ {
+		if t.hashes[i] != h {
+			continue
+		}
+		es, err := t.readHashData(t.offsets[i], name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	return entries, nil
+}
+
+// readHashData reads the entries of the hash data at off whose name is name.
+func (t *AppleAccelTable) readHashData(off uint32, name string) ([]AccelEntry, error) {
+	var entries []AccelEntry
+	for {
+		if uint64(off)+4 > uint64(len(t.dat)) {
+			return nil, fmt.Errorf("accelerator table hash data offset %#x out of range", off)
+		}
+		strOff := t.bo.Uint32(t.dat[off:])
+		off += 4
+		if strOff == 0 {
+			return entries, nil
+		}
+		if uint64(off)+4 > uint64(len(t.dat)) {
+			return nil, fmt.Errorf("accelerator table hash data offset %#x out of range", off)
+		}
+		count := t.bo.Uint32(t.dat[off:])
+		off += 4
+		// every entry takes at least a byte unless the table has no atoms
+		if uint64(count) > uint64(len(t.dat))-uint64(off) {
+			return nil, fmt.Errorf("accelerator table hash data count %d at offset %#x out of range", count, off-4)
+		}
+		match := cstringAt(t.str, strOff) == name
+		for j := uint32(0); j < count; j++ {
+			var e AccelEntry
+			for _, atom := range t.atoms {
+				v, n, err := readAccelForm(t.dat[off:], atom[1], t.bo)
+				if err != nil {
+					return nil, err
+				}
+				off += uint32(n)
+				switch atom[0] {
+				case appleAtomDIEOffset:
+					e.Offset = dwarf.Offset(v + uint64(t.base))
+				case appleAtomDIETag:
+					e.Tag = dwarf.Tag(v)
+				}
+			}
+			if match {
+				entries = append(entries, e)
+			}
+		}
+	}
+}
+
+// A DebugNamesTable is a DWARF 5 __debug_names name index.
+type DebugNamesTable struct {
+	bo         binary.ByteOrder
+	str        []byte // __debug_str
+	cus        []uint32
+	buckets    []uint32
+	hashes     []uint32
+	strOffsets []uint32
+	entOffsets []uint32
+	abbrevs    map[uint64]debugNamesAbbrev
+	pool       []byte
+}
+
+type debugNamesAbbrev struct {
+	tag   dwarf.Tag
+	attrs [][2]uint64 // index attribute, form
+}
+
+// NewDebugNamesTable parses the first name index of a __debug_names section;
+// str is the __debug_str section its names point into.
+func NewDebugNamesTable(dat, str []byte, bo binary.ByteOrder) (*DebugNamesTable, error) {
+	var hdr struct {
+		UnitLength           uint32
+		Version              uint16
+		Padding              uint16
+		CompUnitCount        uint32
+		LocalTypeUnitCount   uint32
+		ForeignTypeUnitCount uint32
+		BucketCount          uint32
+		NameCount            uint32
+		AbbrevTableSize      uint32
+		AugmentationSize     uint32
+	}
+	r := bytes.NewReader(dat)
+	if err := binary.Read(r, bo, &hdr); err != nil {
+		return nil, fmt.Errorf("failed to read __debug_names header: %v", err)
+	}
+	if hdr.UnitLength == 0xffffffff {
+		return nil, fmt.Errorf("64-bit DWARF __debug_names is not supported")
+	}
+	if hdr.Version != 5 {
+		return nil, fmt.Errorf("unsupported __debug_names version %d", hdr.Version)
+	}
+	if uint64(hdr.UnitLength)+4 > uint64(len(dat)) {
+		return nil, fmt.Errorf("__debug_names unit length %#x is larger than its section", hdr.UnitLength)
+	}
+	end := int64(hdr.UnitLength) + 4
+	off := int64(36) + int64((hdr.AugmentationSize+3)&^3)
+
+	t := &DebugNamesTable{bo: bo, str: str, abbrevs: make(map[uint64]debugNamesAbbrev)}
+	read := func(n uint32) ([]uint32, error) {
+		if off+int64(n)*4 > end {
+			return nil, fmt.Errorf("__debug_names tables are larger than its unit")
+		}
+		v := make([]uint32, n)
+		for i := range v {
+			v[i] = bo.Uint32(dat[off:])
+			off += 4
+		}
+		return v, nil
+	}
+	var err error
+	if t.cus, err = read(hdr.CompUnitCount); err != nil {
+		return nil, err
+	}
+	off += int64(hdr.LocalTypeUnitCount)*4 + int64(hdr.ForeignTypeUnitCount)*8
+	if t.buckets, err = read(hdr.BucketCount); err != nil {
+		return nil, err
+	}
+	if hdr.BucketCount > 0 {
+		if t.hashes, err = read(hdr.NameCount); err != nil {
+			return nil, err
+		}
+	}
+	if t.strOffsets, err = read(hdr.NameCount); err != nil {
+		return nil, err
+	}
+	if t.entOffsets, err = read(hdr.NameCount); err != nil {
+		return nil, err
+	}
+	if off+int64(hdr.AbbrevTableSize) > end {
+		return nil, fmt.Errorf("__debug_names abbreviation table is larger than its unit")
+	}
+
+	abbrevs := bytes.NewReader(dat[off : off+int64(hdr.AbbrevTableSize)])
+	for {
+		code, err := binary.ReadUvarint(abbrevs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read __debug_names abbreviation: %v", err)
+		}
+		if code == 0 {
+			break
+		}
+		tag, err := binary.ReadUvarint(abbrevs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read __debug_names abbreviation: %v", err)
+		}
+		a := debugNamesAbbrev{tag: dwarf.Tag(tag)}
+		for {
+			idx, err := binary.ReadUvarint(abbrevs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read __debug_names abbreviation: %v", err)
+			}
+			form, err := binary.ReadUvarint(abbrevs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read __debug_names abbreviation: %v", err)
+			}
+			if idx == 0 && form == 0 {
+				break
+			}
+			a.attrs = append(a.attrs, [2]uint64{idx, form})
+		}
+		t.abbrevs[code] = a
+	}
+	t.pool = dat[off+int64(hdr.AbbrevTableSize) : end]
+
+	return t, nil
+}
+
+// caseFoldedDJBHash is the hash function of __debug_names: djbHash of the lower case name.
+func caseFoldedDJBHash(s string) uint32 {
+	h := uint32(5381)
+	for _, r := range s {
+		var b [utf8.UTFMax]byte
+		n := utf8.EncodeRune(b[:], unicode.ToLower(r))
+		for _, c := range b[:n] {
+			h = h*33 + uint32(c)
+		}
+	}
+	return h
+}
+
+// Lookup returns the DIEs with the given name.
+func (t *DebugNamesTable) Lookup(name string) ([]AccelEntry, error) {
+	var entries []AccelEntry
+	if len(t.buckets) == 0 { // no hash table: search all the names
+		for i := range t.strOffsets {
+			if cstringAt(t.str, t.strOffsets[i]) == name {
+				es, err := t.readEntries(t.entOffsets[i])
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, es...)
+			}
+		}
+		return entries, nil
+	}
+	h := caseFoldedDJBHash(name)
+	bucket := h % uint32(len(t.buckets))
+	idx := t.buckets[bucket] // 1-based, 0 for an empty bucket
+	if idx == 0 {
+		return nil, nil
+	}
+	for i := idx - 1; i < uint32(len(t.hashes)) && t.hashes[i]%uint32(len(t.buckets)) == bucket; i++ {
+		if t.hashes[i] != h || cstringAt(t.str, t.strOffsets[i]) != name {
+			continue
+		}
+		es, err := t.readEntries(t.entOffsets[i])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	return entries, nil
+}
+
+// readEntries reads the list of entries at off in the entry pool.
+func (t *DebugNamesTable) readEntries(off uint32) ([]AccelEntry, error) {
+	if uint64(off) > uint64(len(t.pool)) {
+		return nil, fmt.Errorf("__debug_names entry offset %#x out of range", off)
+	}
+	dat := t.pool[off:]
+	var entries []AccelEntry
+	for {
+		code, n := binary.Uvarint(dat)
+		if n <= 0 {
+			return nil, fmt.Errorf("failed to read __debug_names entry at offset %#x", off)
+		}
+		dat = dat[n:]
+		if code == 0 {
+			return entries, nil
+		}
+		a, ok := t.abbrevs[code]
+		if !ok {
+			return nil, fmt.Errorf("unknown __debug_names abbreviation code %d", code)
+		}
+		var cu, dieOff uint64
+		for _, attr := range a.attrs {
+			v, n, err := readAccelForm(dat, uint16(attr[1]), t.bo)
+			if err != nil {
+				return nil, err
+			}
+			dat = dat[n:]
+			switch attr[0] {
+			case debugNamesIdxCompileUnit:
+				cu = v
+			case debugNamesIdxDIEOffset:
+				dieOff = v
+			}
+		}
+		if cu < uint64(len(t.cus)) {
+			dieOff += uint64(t.cus[cu]) // DW_IDX_die_offset is relative to its unit
+		}
+		entries = append(entries, AccelEntry{Offset: dwarf.Offset(dieOff), Tag: a.tag})
+	}
+}
+
+// readAccelForm reads a value of the given form and returns it and its size.
+func readAccelForm(dat []byte, form uint16, bo binary.ByteOrder) (uint64, int, error) {
+	need := func(n int) error {
+		if len(dat) < n {
+			return fmt.Errorf("accelerator table data truncated")
+		}
+		return nil
+	}
+	switch form {
+	case formData1, formRef1, formFlag:
+		if err := need(1); err != nil {
+			return 0, 0, err
+		}
+		return uint64(dat[0]), 1, nil
+	case formData2, formRef2:
+		if err := need(2); err != nil {
+			return 0, 0, err
+		}
+		return uint64(bo.Uint16(dat)), 2, nil
+	case formData4, formRef4:
+		if err := need(4); err != nil {
+			return 0, 0, err
+		}
+		return uint64(bo.Uint32(dat)), 4, nil
+	case formData8, formRef8:
+		if err := need(8); err != nil {
+			return 0, 0, err
+		}
+		return bo.Uint64(dat), 8, nil
+	case formUdata, formRefUdata:
+		v, n := binary.Uvarint(dat)
+		if n <= 0 {
+			return 0, 0, fmt.Errorf("accelerator table data truncated")
+		}
+		return v, n, nil
+	case formSdata:
+		v, n := binary.Varint(dat)
+		if n <= 0 {
+			return 0, 0, fmt.Errorf("accelerator table data truncated")
+		}
+		return uint64(v), n, nil
+	case formFlagPresent:
+		return 1, 0, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported accelerator table form %#x", form)
+}
+
+// cstringAt returns the NUL terminated string at off in dat.
+func cstringAt(dat []byte, off uint32) string {
+	if uint64(off) >= uint64(len(dat)) {
+		return ""
+	}
+	s := dat[off:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s)
+}
+
+// AccelTables are the DWARF accelerator tables of a Mach-O, used to find DIEs
+// by name without walking __debug_info. A table is nil if the file does not have it.
+type AccelTables struct {
+	Names      *AppleAccelTable // __apple_names: functions and variables
+	Types      *AppleAccelTable // __apple_types
+	Namespaces *AppleAccelTable // __apple_namespac
+	ObjC       *AppleAccelTable // __apple_objc: ObjC methods by class name
+	DebugNames *DebugNamesTable // DWARF 5 __debug_names
+}
+
+// AccelTables parses the Apple (__apple_*) and DWARF 5 (__debug_names) accelerator tables.
+func (f *File) AccelTables() (*AccelTables, error) {
+	var str []byte
+	if sec := f.Section("__DWARF", "__debug_str"); sec != nil {
+		dat, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read __debug_str: %v", err)
+		}
+		str = dat
+	}
+
+	a := &AccelTables{}
+	for _, sec := range f.Sections {
+		var apple **AppleAccelTable
+		switch sec.Name {
+		case "__apple_names":
+			apple = &a.Names
+		case "__apple_types":
+			apple = &a.Types
+		case "__apple_namespac":
+			apple = &a.Namespaces
+		case "__apple_objc":
+			apple = &a.ObjC
+		case "__debug_names":
+		default:
+			continue
+		}
+		dat, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s.%s: %v", sec.Seg, sec.Name, err)
+		}
+		if apple == nil {
+			if a.DebugNames, err = NewDebugNamesTable(dat, str, f.ByteOrder); err != nil {
+				return nil, fmt.Errorf("failed to parse %s.%s: %v", sec.Seg, sec.Name, err)
+			}
+			continue
+		}
+		if *apple, err = NewAppleAccelTable(dat, str, f.ByteOrder); err != nil {
+			return nil, fmt.Errorf("failed to parse %s.%s: %v", sec.Seg, sec.Name, err)
+		}
+	}
+	if a.Names == nil && a.Types == nil && a.Namespaces == nil && a.ObjC == nil && a.DebugNames == nil {
+		return nil, fmt.Errorf("no DWARF accelerator tables found")
+	}
+	return a, nil
+}
+
+// lookup queries the Apple table if present, otherwise __debug_names, keeping the entries with one of tags.
+func (a *AccelTables) lookup(apple *AppleAccelTable, name string, tags ...dwarf.Tag) ([]dwarf.Offset, error) {
+	var entries []AccelEntry
+	var err error
+	switch {
+	case apple != nil:
+		entries, err = apple.Lookup(name)
+	case a.DebugNames != nil:
+		entries, err = a.DebugNames.Lookup(name)
+	default:
+		return nil, fmt.Errorf("no accelerator table to look up %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var offs []dwarf.Offset
+	for _, e := range entries {
+		if e.Tag == 0 || len(tags) == 0 || hasTag(e.Tag, tags) {
+			offs = append(offs, e.Offset)
+		}
+	}
+	return offs, nil
+}
+
+func hasTag(tag dwarf.Tag, tags []dwarf.Tag) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupFunction returns the offsets of the DW_TAG_subprogram DIEs named name.
+func (a *AccelTables) LookupFunction(name string) ([]dwarf.Offset, error) {
+	return a.lookup(a.Names, name, dwarf.TagSubprogram, dwarf.TagInlinedSubroutine)
+}
+
+// LookupType returns the offsets of the type DIEs named name.
+func (a *AccelTables) LookupType(name string) ([]dwarf.Offset, error) {
+	return a.lookup(a.Types, name, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType,
+		dwarf.TagUnionType, dwarf.TagEnumerationType, dwarf.TagTypedef, dwarf.TagPointerType,
+		dwarf.TagSubroutineType, dwarf.TagUnspecifiedType)
+}
+
+// LookupNamespace returns the offsets of the DW_TAG_namespace DIEs named name.
+func (a *AccelTables) LookupNamespace(name string) ([]dwarf.Offset, error) {
+	return a.lookup(a.Namespaces, name, dwarf.TagNamespace)
+}
+
+// LookupObjCMethods returns the offsets of the DW_TAG_subprogram DIEs of the
+// methods of the ObjC class (or "class(category)") named class.
+// Only __apple_objc indexes methods by class; __debug_names is not used.
+func (a *AccelTables) LookupObjCMethods(class string) ([]dwarf.Offset, error) {
+	if a.ObjC == nil {
+		return nil, fmt.Errorf("no __apple_objc accelerator table to look up %s", class)
+	}
+	return a.lookup(a.ObjC, class, dwarf.TagSubprogram)
+}
@@ -0,0 +1,414 @@
+package macho
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+const (
+	archiveMagic      = "!<arch>\n"
+	archiveHeaderSize = 60
+	archiveFmag       = "`\n"
+	// archiveBSDLongName is the prefix of a BSD 4.4 extended member name
+	// whose length follows and whose bytes are stored at the start of the member data.
+	archiveBSDLongName = "#1/"
+)
+
+// Names of the members holding an archive's ranlib symbol table.
+const (
+	SymdefName         = "__.SYMDEF"
+	SymdefSortedName   = "__.SYMDEF SORTED"
+	Symdef64Name       = "__.SYMDEF_64"
+	Symdef64SortedName = "__.SYMDEF_64 SORTED"
+)
+
+// ErrNotArchive is returned from NewArchive or OpenArchive when the file does
+// not start with the static archive magic.
+var ErrNotArchive = &FormatError{0, "not a static archive", nil}
+
+// An ArchiveMemberHeader represents an ar(5) member header.
+type ArchiveMemberHeader struct {
+	Name    string
+	ModTime time.Time
+	UID     int
+	GID     int
+	Mode    os.FileMode
+	Offset  int64 // offset of the member header in the archive
+	Size    int64 // size of the member data, not including a BSD extended name
+}
+
+// An ArchiveMember is a single file stored inside a static archive.
+type ArchiveMember struct {
+	ArchiveMemberHeader
+	sr *io.SectionReader
+
+	once sync.Once
+	file *File
+	err  error
+}
+
+// Open returns the Mach-O file stored in the member.
+// The member is only parsed the first time Open is called.
+func (m *ArchiveMember) Open() (*File, error) {
+	m.once.Do(func() {
+		m.file, m.err = NewFile(m.sr)
+		if m.err != nil {
+			m.err = fmt.Errorf("failed to parse archive member %s: %v", m.Name, m.err)
+		}
+	})
+	return m.file, m.err
+}
+
+// Data returns the raw contents of the member.
+func (m *ArchiveMember) Data() ([]byte, error) {
+	dat := make([]byte, m.sr.Size())
+	if _, err := m.sr.ReadAt(dat, 0); err != nil {
+		return nil, err
+	}
+	return dat, nil
+}
+
+// Reader returns a new ReadSeeker reading the member data.
+func (m *ArchiveMember) Reader() io.ReadSeeker {
+	return io.NewSectionReader(m.sr, 0, 1<<63-1)
+}
+
+func (m *ArchiveMember) String() string {
+	return fmt.Sprintf("%s %d/%d %6d %s %s", m.Mode, m.UID, m.GID, m.Size, m.ModTime.Format("Jan _2 15:04 2006"), m.Name)
+}
+
+// An ArchiveSymbol is an entry in an archive's ranlib symbol table.
+type ArchiveSymbol struct {
+	Name   string
+	Offset uint64 // offset of the defining member's header
+	Member *ArchiveMember
+}
+
+// An Archive is a static library (ar(5) file) of Mach-O objects.
+type Archive struct {
+	// Symdef is the name of the member the symbol table was read from, or
+	// empty when the archive has no symbol table.
+	Symdef  string
+	Symbols []ArchiveSymbol
+	Members []*ArchiveMember
+
+	closer io.Closer
+}
+
+// NewArchive creates a new Archive for accessing the members of a static
+// library. The archive is expected to start at position 0 in the ReaderAt.
+// Members are not parsed until ArchiveMember.Open is called.
+func NewArchive(r io.ReaderAt) (*Archive, error) {
+	var magic [len(archiveMagic)]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return nil, &FormatError{0, "error reading magic number", nil}
+	}
+	if string(magic[:]) != archiveMagic {
+		return nil, ErrNotArchive
+	}
+
+	a := &Archive{}
+	var symdef *ArchiveMember
+
+	offset := int64(len(archiveMagic))
+	for {
+		var hdr [archiveHeaderSize]byte
+		n, err := r.ReadAt(hdr[:], offset)
+		if n == 0 && err == io.EOF {
+			break
+		} else if n < archiveHeaderSize {
+			return nil, &FormatError{offset, "truncated archive member header", nil}
+		}
+		if string(hdr[58:60]) != archiveFmag {
+			return nil, &FormatError{offset + 58, "invalid archive member header terminator", string(hdr[58:60])}
+		}
+
+		m := &ArchiveMember{}
+		m.Offset = offset
+
+		size, err := archiveDecimal(hdr[48:58])
+		if err != nil || size < 0 {
+			return nil, &FormatError{offset + 48, "invalid archive member size", string(hdr[48:58])}
+		}
+		if size > 0 {
+			// make sure the member data is in the file before moving past it
+			var last [1]byte
+			if _, err := r.ReadAt(last[:], offset+archiveHeaderSize+size-1); err != nil {
+				return nil, &FormatError{offset + 48, "archive member extends past the end of the file", size}
+			}
+		}
+		date, err := archiveDecimal(hdr[16:28])
+		if err != nil {
+			return nil, &FormatError{offset + 16, "invalid archive member date", string(hdr[16:28])}
+		}
+		uid, err := archiveDecimal(hdr[28:34])
+		if err != nil {
+			return nil, &FormatError{offset + 28, "invalid archive member uid", string(hdr[28:34])}
+		}
+		gid, err := archiveDecimal(hdr[34:40])
+		if err != nil {
+			return nil, &FormatError{offset + 34, "invalid archive member gid", string(hdr[34:40])}
+		}
+		mode, err := strconv.ParseUint(strings.TrimSpace(string(hdr[40:48])), 8, 32)
+		if err != nil && len(strings.TrimSpace(string(hdr[40:48]))) > 0 {
+			return nil, &FormatError{offset + 40, "invalid archive member mode", string(hdr[40:48])}
+		}
+		m.ModTime = time.Unix(date, 0)
+		m.UID = int(uid)
+		m.GID = int(gid)
+		m.Mode = os.FileMode(mode & 0777)
+
+		dataOff := offset + archiveHeaderSize
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		if strings.HasPrefix(name, archiveBSDLongName) {
+			nameLen, err := strconv.ParseInt(name[len(archiveBSDLongName):], 10, 64)
+			if err != nil || nameLen < 0 || nameLen > size {
+				return nil, &FormatError{offset, "invalid BSD extended archive member name", name}
+			}
+			nameDat := make([]byte, nameLen)
+			if _, err := r.ReadAt(nameDat, dataOff); err != nil {
+				return nil, &FormatError{dataOff, "failed to read BSD extended archive member name", nil}
+			}
+			// the name is padded with NULs to keep the member data aligned
+			name = strings.TrimRight(string(nameDat), "\x00")
+			dataOff += nameLen
+			size -= nameLen
+		} else {
+			// SysV/GNU style names are terminated by a '/'
+			if name != "/" && name != "//" {
+				name = strings.TrimSuffix(name, "/")
+			}
+		}
+		m.Name = name
+		m.Size = size
+		m.sr = io.NewSectionReader(r, dataOff, size)
+
+		switch name {
+		case SymdefName, SymdefSortedName, Symdef64Name, Symdef64SortedName:
+			if symdef == nil {
+				symdef = m
+			}
+		default:
+			a.Members = append(a.Members, m)
+		}
+
+		offset = dataOff + size
+		// members are aligned on an even offset
+		if offset&1 != 0 {
+			offset++
+		}
+	}
+
+	if symdef != nil {
+		a.Symdef = symdef.Name
+		if err := a.parseSymdef(symdef); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+func archiveDecimal(b []byte) (int64, error) {
+	s := strings.TrimSpace(string(b))
+	if len(s) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseSymdef reads the ranlib (or ranlib_64) table stored in the symdef member.
+func (a *Archive) parseSymdef(m *ArchiveMember) error {
+	dat, err := m.Data()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", m.Name, err)
+	}
+
+	is64 := m.Name == Symdef64Name || m.Name == Symdef64SortedName
+	wordSize := 4
+	if is64 {
+		wordSize = 8
+	}
+	word := func(bo binary.ByteOrder, b []byte) uint64 {
+		if is64 {
+			return bo.Uint64(b)
+		}
+		return uint64(bo.Uint32(b))
+	}
+
+	if len(dat) < wordSize {
+		return &FormatError{m.Offset, "truncated archive symbol table", m.Name}
+	}
+
+	// The table is written in the byte order of the tool that created the
+	// archive, so pick the one that gives a sane ranlib array size.
+	var bo binary.ByteOrder = binary.LittleEndian
+	if word(bo, dat) > uint64(len(dat)-wordSize) {
+		bo = binary.BigEndian
+	}
+	tblSize := word(bo, dat)
+	if tblSize > uint64(len(dat)-wordSize) || tblSize%uint64(2*wordSize) != 0 {
+		return &FormatError{m.Offset, "invalid archive symbol table size", tblSize}
+	}
+	tbl := dat[wordSize : uint64(wordSize)+tblSize]
+
+	strOff := uint64(wordSize) + tblSize
+	if strOff+uint64(wordSize) > uint64(len(dat)) {
+		return &FormatError{m.Offset, "truncated archive symbol string table", m.Name}
+	}
+	strSize := word(bo, dat[strOff:])
+	strOff += uint64(wordSize)
+	if strOff+strSize > uint64(len(dat)) {
+		return &FormatError{m.Offset, "invalid archive symbol string table size", strSize}
+	}
+	strtab := dat[strOff : strOff+strSize]
+
+	byOffset := make(map[uint64]*ArchiveMember, len(a.Members))
+	for _, mem := range a.Members {
+		byOffset[uint64(mem.Offset)] = mem
+	}
+
+	for i := 0; i+2*wordSize <= len(tbl); i += 2 * wordSize {
+		strx := word(bo, tbl[i:])
+		off := word(bo, tbl[i+wordSize:])
+		if strx >= uint64(len(strtab)) {
+			return &FormatError{m.Offset, "invalid archive symbol name index", strx}
+		}
+		name, _, _ := bytes.Cut(strtab[strx:], []byte{0})
+		a.Symbols = append(a.Symbols, ArchiveSymbol{
+			Name:   string(name),
+			Offset: off,
+			Member: byOffset[off],
+		})
+	}
+
+	return nil
+}
+
+// Member returns the first member with the given name, or nil if none exists.
+func (a *Archive) Member(name string) *ArchiveMember {
+	for _, m := range a.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// SymbolMember returns the member that defines the given symbol according to
+// the archive's symbol table, or nil if it isn't listed.
+func (a *Archive) SymbolMember(name string) *ArchiveMember {
+	for _, sym := range a.Symbols {
+		if sym.Name == name {
+			return sym.Member
+		}
+	}
+	return nil
+}
+
+// OpenArchive opens the named file using os.Open and prepares it for use as a
+// static archive.
+func OpenArchive(name string) (*Archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewArchive(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	a.closer = f
+	return a, nil
+}
+
+// Close closes the Archive.
+// If the Archive was created using NewArchive directly instead of OpenArchive,
+// Close has no effect.
+func (a *Archive) Close() error {
+	var err error
+	if a.closer != nil {
+		err = a.closer.Close()
+		a.closer = nil
+	}
+	return err
+}
+
+// A FatArchiveArch is a static archive slice inside a universal archive.
+type FatArchiveArch struct {
+	FatArchHeader
+	*Archive
+}
+
+// A FatArchive is a universal file whose slices are static archives.
+type FatArchive struct {
+	Magic  types.Magic
+	Arches []FatArchiveArch
+	closer io.Closer
+}
+
+// NewFatArchive creates a new FatArchive for accessing the static archives
+// in a universal file. ErrNotFat is returned if the file is a thin archive or
+// Mach-O.
+func NewFatArchive(r io.ReaderAt) (*FatArchive, error) {
+	var magic [len(archiveMagic)]byte
+	if _, err := r.ReadAt(magic[:], 0); err == nil && string(magic[:]) == archiveMagic {
+		return nil, ErrNotFat
+	}
+
+	var err error
+	var fa FatArchive
+
+	var hdrs []FatArchHeader
+	fa.Magic, hdrs, err = readFatHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fa.Arches = make([]FatArchiveArch, len(hdrs))
+	for i := range hdrs {
+		arch := &fa.Arches[i]
+		arch.FatArchHeader = hdrs[i]
+		arch.Archive, err = NewArchive(io.NewSectionReader(r, int64(arch.Offset), int64(arch.Size)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s archive slice: %w", arch.CPU, err)
+		}
+	}
+
+	return &fa, nil
+}
+
+// OpenFatArchive opens the named file using os.Open and prepares it for use as
+// a universal static archive.
+func OpenFatArchive(name string) (*FatArchive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	fa, err := NewFatArchive(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fa.closer = f
+	return fa, nil
+}
+
+// Close closes the FatArchive.
+func (fa *FatArchive) Close() error {
+	var err error
+	if fa.closer != nil {
+		err = fa.closer.Close()
+		fa.closer = nil
+	}
+	return err
+}
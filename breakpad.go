@@ -0,0 +1,481 @@
+package macho
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-dwarf"
+	"github.com/blacktop/go-macho/pkg/unwind"
+	"github.com/blacktop/go-macho/types"
+)
+
+// BreakpadConfig is a WriteBreakpadSymbols configuration.
+type BreakpadConfig struct {
+	// Name is the module name of the MODULE record, by default the base name of the dylib ID
+	// or of the path the file was opened from (files from NewFile without a dylib ID must set it)
+	Name string
+	// DWARF is used instead of the file's own DWARF, e.g. the DWARF of its dSYM
+	DWARF *dwarf.Data
+	// NoCFI disables the STACK CFI records
+	NoCFI bool
+}
+
+type breakpadFunc struct {
+	addr, size uint64
+	name       string
+	lines      []breakpadLine
+}
+
+type breakpadLine struct {
+	addr, size uint64
+	line       int
+	file       int
+}
+
+// BreakpadArch returns the Breakpad name of the file's architecture.
+func (f *File) BreakpadArch() string {
+	switch f.CPU {
+	case types.CPU386:
+		return "x86"
+	case types.CPUAmd64:
+		if f.SubCPU&types.CpuSubtypeMask == types.CPUSubtypeX86_64H {
+			return "x86_64h"
+		}
+		return "x86_64"
+	case types.CPUArm:
+		return "arm"
+	case types.CPUArm64:
+		if f.SubCPU&types.CpuSubtypeMask == types.CPUSubtypeArm64E {
+			return "arm64e"
+		}
+		return "arm64"
+	case types.CPUArm6432:
+		return "arm64_32"
+	case types.CPUPpc:
+		return "ppc"
+	case types.CPUPpc64:
+		return "ppc64"
+	}
+	return strings.ToLower(f.CPU.String())
+}
+
+// WriteBreakpadSymbols writes a Breakpad text symbol file (the output of dump_syms)
+// for the file to w:
+//   - the MODULE record from the CPU type and UUID
+//   - FILE, FUNC and line records from the DWARF (see BreakpadConfig.DWARF)
+//   - PUBLIC records from the symtab and exports for the code without a FUNC
+//   - STACK CFI records from __eh_frame and __unwind_info
+//
+// Addresses are relative to the __TEXT segment.
+func (f *File) WriteBreakpadSymbols(w io.Writer, config ...BreakpadConfig) error {
+	var cfg BreakpadConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	u := f.UUID()
+	if u == nil {
+		return fmt.Errorf("failed to write Breakpad symbols: binary has no LC_UUID load command")
+	}
+	text := f.Segment("__TEXT")
+	if text == nil {
+		return fmt.Errorf("failed to write Breakpad symbols: no __TEXT segment")
+	}
+	name := cfg.Name
+	if name == "" {
+		if id := f.DylibID(); id != nil {
+			name = filepath.Base(id.Name)
+		} else if f.path != "" {
+			name = filepath.Base(f.path)
+		} else {
+			return fmt.Errorf("failed to write Breakpad symbols: no module name")
+		}
+	}
+	base := text.Addr
+	id := strings.ReplaceAll(u.UUID.String(), "-", "")
+
+	d := cfg.DWARF
+	if d == nil {
+		d = f.cachedDWARF()
+	}
+	var files []string
+	var funcs []*breakpadFunc
+	if d != nil {
+		var err error
+		if files, funcs, err = breakpadFuncs(d); err != nil {
+			return fmt.Errorf("failed to write Breakpad symbols: %v", err)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "MODULE mac %s %s0 %s\n", f.BreakpadArch(), id, name)
+	fmt.Fprintf(bw, "INFO CODE_ID %s\n", id)
+	for i, file := range files {
+		fmt.Fprintf(bw, "FILE %d %s\n", i, file)
+	}
+
+	starts := make(map[uint64]bool, len(funcs))
+	for _, fn := range funcs {
+		if fn.addr < base {
+			continue
+		}
+		starts[fn.addr] = true
+		fmt.Fprintf(bw, "FUNC %x %x 0 %s\n", fn.addr-base, fn.size, fn.name)
+		for _, l := range fn.lines {
+			fmt.Fprintf(bw, "%x %x %d %d\n", l.addr-base, l.size, l.line, l.file)
+		}
+	}
+
+	seen := make(map[uint64]bool)
+	for _, sym := range f.SymbolIndex().Symbols() {
+		if sym.Source == SymbolSourceFunctionStart || starts[sym.Addr] || seen[sym.Addr] {
+			continue
+		}
+		if sym.Addr < text.Addr || sym.Addr >= text.Addr+text.Memsz {
+			continue
+		}
+		seen[sym.Addr] = true
+		// dump_syms drops the leading underscore of C symbols
+		fmt.Fprintf(bw, "PUBLIC %x 0 %s\n", sym.Addr-base, strings.TrimPrefix(sym.Name, "_"))
+	}
+
+	if !cfg.NoCFI {
+		if err := f.writeBreakpadCFI(bw, base); err != nil {
+			return fmt.Errorf("failed to write Breakpad symbols: %v", err)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// breakpadFuncs returns the source files and the functions with their lines of the DWARF.
+func breakpadFuncs(d *dwarf.Data) ([]string, []*breakpadFunc, error) {
+	var files []string
+	fileIdx := make(map[string]int)
+	var funcs []*breakpadFunc
+	var lines []breakpadLine
+
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read DWARF entry: %v", err)
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			lr, err := d.LineReader(e)
+			if err != nil || lr == nil {
+				continue
+			}
+			var le, prev dwarf.LineEntry
+			var hasPrev bool
+			for {
+				if err := lr.Next(&le); err != nil {
+					if err == io.EOF {
+						break
+					}
+					return nil, nil, fmt.Errorf("failed to read line table: %v", err)
+				}
+				if hasPrev && le.Address > prev.Address && prev.Line > 0 && prev.File != nil {
+					idx, ok := fileIdx[prev.File.Name]
+					if !ok {
+						idx = len(files)
+						fileIdx[prev.File.Name] = idx
+						files = append(files, prev.File.Name)
+					}
+					lines = append(lines, breakpadLine{
+						addr: prev.Address,
+						size: le.Address - prev.Address,
+						line: prev.Line,
+						file: idx,
+					})
+				}
+				prev, hasPrev = le, !le.EndSequence
+			}
+		case dwarf.TagSubprogram:
+			ranges, err := d.Ranges(e)
+			if err != nil {
+				continue
+			}
+			name := dieName(d, e, 0)
+			if name == "" {
+				name = "<name omitted>"
+			}
+			for _, rng := range ranges {
+				if rng[1] > rng[0] {
+					funcs = append(funcs, &breakpadFunc{addr: rng[0], size: rng[1] - rng[0], name: name})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(funcs, func(i, j int) bool { return funcs[i].addr < funcs[j].addr })
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].addr < lines[j].addr })
+
+	uniq := funcs[:0]
+	for _, fn := range funcs {
+		if len(uniq) > 0 && uniq[len(uniq)-1].addr == fn.addr {
+			continue
+		}
+		uniq = append(uniq, fn)
+	}
+	for _, fn := range uniq {
+		end := fn.addr + fn.size
+		i := sort.Search(len(lines), func(i int) bool { return lines[i].addr+lines[i].size > fn.addr })
+		for ; i < len(lines) && lines[i].addr < end; i++ {
+			l := lines[i]
+			if l.addr < fn.addr {
+				l.size -= fn.addr - l.addr
+				l.addr = fn.addr
+			}
+			if l.addr+l.size > end {
+				l.size = end - l.addr
+			}
+			fn.lines = append(fn.lines, l)
+		}
+	}
+
+	return files, uniq, nil
+}
+
+// breakpadRegName returns the Breakpad name of a DWARF register number, or "" if unknown.
+func (f *File) breakpadRegName(reg uint64) string {
+	switch f.CPU {
+	case types.CPUAmd64:
+		names := []string{"$rax", "$rdx", "$rcx", "$rbx", "$rsi", "$rdi", "$rbp", "$rsp",
+			"$r8", "$r9", "$r10", "$r11", "$r12", "$r13", "$r14", "$r15", "$rip"}
+		if reg < uint64(len(names)) {
+			return names[reg]
+		}
+	case types.CPU386:
+		// Darwin swaps the numbers of esp and ebp in __eh_frame
+		names := []string{"$eax", "$ecx", "$edx", "$ebx", "$ebp", "$esp", "$esi", "$edi", "$eip"}
+		if reg < uint64(len(names)) {
+			return names[reg]
+		}
+	case types.CPUArm64, types.CPUArm6432:
+		switch {
+		case reg <= 30:
+			return fmt.Sprintf("x%d", reg)
+		case reg == 31:
+			return "sp"
+		case reg == 32:
+			return "pc"
+		case reg >= 64 && reg <= 95:
+			return fmt.Sprintf("v%d", reg-64)
+		}
+	}
+	return ""
+}
+
+// breakpadCompactRegName returns the Breakpad name of a register named by unwind.Encoding.Decode.
+func (f *File) breakpadCompactRegName(reg string) string {
+	if f.CPU == types.CPUAmd64 || f.CPU == types.CPU386 {
+		return "$" + reg
+	}
+	return reg
+}
+
+// breakpadRules returns the Breakpad CFI rules of an __eh_frame row, and false
+// if the CFA cannot be expressed (DWARF expressions). Only the rules that
+// differ from prev are returned if prev is not nil.
+func (f *File) breakpadRules(row unwind.Row, ra uint64, prev map[string]string) (map[string]string, bool) {
+	rules := make(map[string]string)
+	if row.CFA.Kind != unwind.RuleCFA || row.CFA.Expression != nil {
+		return nil, false
+	}
+	cfaReg := f.breakpadRegName(row.CFA.Register)
+	if cfaReg == "" {
+		return nil, false
+	}
+	rules[".cfa"] = fmt.Sprintf("%s %d +", cfaReg, row.CFA.Offset)
+	for reg, rule := range row.Registers {
+		name := f.breakpadRegName(reg)
+		if reg == ra {
+			name = ".ra"
+		}
+		if name == "" {
+			continue
+		}
+		switch rule.Kind {
+		case unwind.RuleUndefined:
+			rules[name] = ".undef"
+		case unwind.RuleSameValue:
+			if reg != ra {
+				rules[name] = name
+			}
+		case unwind.RuleOffset:
+			rules[name] = fmt.Sprintf(".cfa %d + ^", rule.Offset)
+		case unwind.RuleValOffset:
+			rules[name] = fmt.Sprintf(".cfa %d +", rule.Offset)
+		case unwind.RuleRegister:
+			if other := f.breakpadRegName(rule.Register); other != "" {
+				rules[name] = other
+			}
+		}
+	}
+	if prev == nil {
+		if _, ok := rules[".ra"]; !ok {
+			if name := f.breakpadRegName(ra); name != "" {
+				rules[".ra"] = name // the return address is still in its register
+			}
+		}
+	}
+	return rules, true
+}
+
+func formatBreakpadRules(rules map[string]string) string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	// .cfa and .ra first like dump_syms
+	sort.Slice(names, func(i, j int) bool {
+		if strings.HasPrefix(names[i], ".") != strings.HasPrefix(names[j], ".") {
+			return strings.HasPrefix(names[i], ".")
+		}
+		return names[i] < names[j]
+	})
+	var sb strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%s: %s", name, rules[name])
+	}
+	return sb.String()
+}
+
+// writeBreakpadCFI writes the STACK CFI records of the __eh_frame FDEs, then
+// of the __unwind_info entries whose encoding does not defer to __eh_frame.
+func (f *File) writeBreakpadCFI(w io.Writer, base uint64) error {
+	covered := make(map[uint64]bool)
+
+	if f.Section("__TEXT", "__eh_frame") != nil {
+		eh, err := f.EHFrame()
+		if err != nil {
+			return err
+		}
+		for _, fde := range eh.FDEs {
+			rows, err := fde.Rows()
+			if err != nil || len(rows) == 0 || fde.PCBegin < base {
+				continue
+			}
+			init, ok := f.breakpadRules(rows[0], fde.CIE.ReturnAddressRegister, nil)
+			if !ok {
+				continue
+			}
+			covered[fde.PCBegin] = true
+			fmt.Fprintf(w, "STACK CFI INIT %x %x %s\n", fde.PCBegin-base, fde.PCEnd-fde.PCBegin, formatBreakpadRules(init))
+			prev := init
+			for _, row := range rows[1:] {
+				rules, ok := f.breakpadRules(row, fde.CIE.ReturnAddressRegister, prev)
+				if !ok || row.Loc >= fde.PCEnd {
+					break
+				}
+				changed := make(map[string]string)
+				for name, rule := range rules {
+					if prev[name] != rule {
+						changed[name] = rule
+					}
+				}
+				for name := range prev {
+					if _, ok := rules[name]; !ok && name != ".ra" {
+						changed[name] = name // restored to its value at the function entry
+					}
+				}
+				if len(changed) > 0 {
+					fmt.Fprintf(w, "STACK CFI %x %s\n", row.Loc-base, formatBreakpadRules(changed))
+				}
+				prev = rules
+			}
+		}
+	}
+
+	if f.Section("__TEXT", "__unwind_info") == nil {
+		return nil
+	}
+	info, err := f.UnwindInfo()
+	if err != nil {
+		return err
+	}
+	for _, fn := range info.Functions {
+		if covered[fn.Start] || fn.Start < base || fn.End <= fn.Start {
+			continue
+		}
+		u, err := fn.Encoding.Decode(f.CPU)
+		if err != nil {
+			return nil // compact unwind of an unsupported CPU
+		}
+		rules := f.breakpadCompactRules(fn, u)
+		if rules == nil {
+			continue
+		}
+		fmt.Fprintf(w, "STACK CFI INIT %x %x %s\n", fn.Start-base, fn.End-fn.Start, formatBreakpadRules(rules))
+	}
+	return nil
+}
+
+// breakpadCompactRules returns the Breakpad CFI rules of a compact unwind
+// encoding. Like libunwind they describe the function body, after the prologue.
+func (f *File) breakpadCompactRules(fn unwind.Function, u unwind.Unwind) map[string]string {
+	ptr := int64(f.pointerSize())
+	rules := make(map[string]string)
+	switch f.CPU {
+	case types.CPUAmd64, types.CPU386:
+		sp, fp := "$rsp", "$rbp"
+		if f.CPU == types.CPU386 {
+			sp, fp = "$esp", "$ebp"
+		}
+		switch u.Mode {
+		case unwind.ModeFrame:
+			rules[".cfa"] = fmt.Sprintf("%s %d +", fp, 2*ptr)
+			rules[fp] = fmt.Sprintf(".cfa %d + ^", -2*ptr)
+		case unwind.ModeFrameless:
+			rules[".cfa"] = fmt.Sprintf("%s %d +", sp, u.StackSize)
+		case unwind.ModeFramelessIndirect:
+			// the stack size is the immediate of the function's sub instruction
+			var buf [4]byte
+			off, err := f.GetOffset(fn.Start + uint64(u.StackSizeOffset))
+			if err != nil {
+				return nil
+			}
+			if _, err := f.ReadAt(buf[:], int64(off)); err != nil {
+				return nil
+			}
+			size := binary.LittleEndian.Uint32(buf[:]) + u.StackAdjust
+			rules[".cfa"] = fmt.Sprintf("%s %d +", sp, size)
+		default:
+			return nil
+		}
+		rules[".ra"] = fmt.Sprintf(".cfa %d + ^", -ptr)
+	case types.CPUArm64, types.CPUArm6432:
+		switch u.Mode {
+		case unwind.ModeFrame:
+			rules[".cfa"] = "x29 16 +"
+			rules["x29"] = ".cfa -16 + ^"
+			rules[".ra"] = ".cfa -8 + ^"
+		case unwind.ModeFrameless:
+			rules[".cfa"] = fmt.Sprintf("sp %d +", u.StackSize)
+			rules[".ra"] = "x30"
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+	for i, reg := range u.Registers {
+		if i < len(u.SavedAt) {
+			rules[f.breakpadCompactRegName(reg)] = fmt.Sprintf(".cfa %d + ^", u.SavedAt[i])
+		}
+	}
+	return rules
+}
@@ -200,13 +200,17 @@ func (s *Segment) Data() ([]byte, error) {
 
 // UncompressedSize returns the size of the segment with its sections uncompressed, ignoring
 // its offset within the file.  The returned size is rounded up to the power of two in align.
-func (s *Segment) UncompressedSize(t *FileTOC, align uint64) uint64 {
+func (s *Segment) UncompressedSize(t *FileTOC, align uint64) (uint64, error) {
 	sz := uint64(0)
 	for j := uint32(0); j < s.Nsect; j++ {
 		c := t.Sections[j+s.Firstsect]
-		sz += c.UncompressedSize()
+		csz, err := c.UncompressedSize()
+		if err != nil {
+			return 0, err
+		}
+		sz += csz
 	}
-	return (sz + align - 1) & uint64(-int64(align))
+	return (sz + align - 1) & uint64(-int64(align)), nil
 }
 
 func (s *Segment) Copy() *Segment {
@@ -293,6 +297,8 @@ type relocInfo struct {
 type Section struct {
 	SectionHeader
 	Relocs []Reloc
+	// Decoded is the value returned by a registered SectionDecoder, if any.
+	Decoded interface{}
 
 	// Embed ReaderAt for ReadAt method.
 	// Do not embed SectionReader directly
@@ -424,61 +430,62 @@ func (s *Section) PutRelocs(b []byte, o binary.ByteOrder) int {
 	return a
 }
 
-func (s *Section) UncompressedSize() uint64 {
+func (s *Section) UncompressedSize() (uint64, error) {
 	if !strings.HasPrefix(s.Name, "__z") {
-		return s.Size
+		return s.Size, nil
 	}
 	b := make([]byte, 12)
 	n, err := s.sr.ReadAt(b, 0)
 	if err != nil {
-		panic("Malformed object file")
+		return 0, fmt.Errorf("malformed object file: failed to read section %s.%s header: %v", s.Seg, s.Name, err)
 	}
 	if n != len(b) {
-		return s.Size
+		return s.Size, nil
 	}
 	if string(b[:4]) == "ZLIB" {
-		return binary.BigEndian.Uint64(b[4:12])
+		return binary.BigEndian.Uint64(b[4:12]), nil
 	}
-	return s.Size
+	return s.Size, nil
 }
 
-func (s *Section) PutData(b []byte) {
+func (s *Section) PutData(b []byte) error {
 	bb := b[0:s.Size]
 	n, err := s.sr.ReadAt(bb, 0)
 	if err != nil || uint64(n) != s.Size {
-		panic("Malformed object file (ReadAt error)")
+		return fmt.Errorf("malformed object file: failed to read section %s.%s data: %v", s.Seg, s.Name, err)
 	}
+	return nil
 }
 
-func (s *Section) PutUncompressedData(b []byte) {
+func (s *Section) PutUncompressedData(b []byte) error {
 	if strings.HasPrefix(s.Name, "__z") {
 		bb := make([]byte, 12)
 		n, err := s.sr.ReadAt(bb, 0)
 		if err != nil {
-			panic("Malformed object file")
+			return fmt.Errorf("malformed object file: failed to read section %s.%s header: %v", s.Seg, s.Name, err)
 		}
 		if n == len(bb) && string(bb[:4]) == "ZLIB" {
 			size := binary.BigEndian.Uint64(bb[4:12])
 			// Decompress starting at b[12:]
 			r, err := zlib.NewReader(io.NewSectionReader(s, 12, int64(size)-12))
 			if err != nil {
-				panic("Malformed object file (zlib.NewReader error)")
+				return fmt.Errorf("malformed object file: zlib.NewReader error: %v", err)
 			}
 			n, err := io.ReadFull(r, b[0:size])
 			if err != nil {
-				panic("Malformed object file (ReadFull error)")
+				return fmt.Errorf("malformed object file: ReadFull error: %v", err)
 			}
 			if uint64(n) != size {
-				panic(fmt.Sprintf("PutUncompressedData, expected to read %d bytes, instead read %d", size, n))
+				return fmt.Errorf("PutUncompressedData, expected to read %d bytes, instead read %d", size, n)
 			}
 			if err := r.Close(); err != nil {
-				panic("Malformed object file (Close error)")
+				return fmt.Errorf("malformed object file: Close error: %v", err)
 			}
-			return
+			return nil
 		}
 	}
 	// Not compressed
-	s.PutData(b)
+	return s.PutData(b)
 }
 
 func (s *Section) Copy() *Section {
@@ -578,16 +585,86 @@ func (s *SymSeg) String() string {
  * LC_THREAD
  *******************************************************************************/
 
+// A ThreadState is a single flavor of thread state stored in a LC_THREAD or
+// LC_UNIXTHREAD command.
+type ThreadState struct {
+	Flavor types.ThreadFlavor
+	Count  uint32 // count of uint32s in Data
+	Data   []byte
+	// Regs is the decoded state, e.g. *RegsARM64, *FloatStateAMD64 or
+	// *ExceptionStateARM64; it is nil for flavors that are not decoded.
+	Regs interface{}
+	cpu  types.CPU
+}
+
+// PC returns the program counter of a general purpose register state.
+func (s ThreadState) PC() (uint64, bool) {
+	switch r := s.Regs.(type) {
+	case *Regs386:
+		return uint64(r.IP), true
+	case *RegsAMD64:
+		return r.IP, true
+	case *RegsARM:
+		return uint64(r.PC), true
+	case *RegsARM64:
+		return r.PC, true
+	}
+	return 0, false
+}
+
+// SP returns the stack pointer of a general purpose register state.
+func (s ThreadState) SP() (uint64, bool) {
+	switch r := s.Regs.(type) {
+	case *Regs386:
+		return uint64(r.SP), true
+	case *RegsAMD64:
+		return r.SP, true
+	case *RegsARM:
+		return uint64(r.SP), true
+	case *RegsARM64:
+		return r.SP, true
+	}
+	return 0, false
+}
+
+func (s ThreadState) String() string {
+	return fmt.Sprintf("flavor=%s count=%d", s.Flavor.String(s.cpu), s.Count)
+}
+
+// ThreadStates are the thread states stored in a LC_THREAD or LC_UNIXTHREAD command.
+type ThreadStates []ThreadState
+
+// PC returns the program counter of the first general purpose register state.
+func (ts ThreadStates) PC() (uint64, bool) {
+	for _, s := range ts {
+		if pc, ok := s.PC(); ok {
+			return pc, true
+		}
+	}
+	return 0, false
+}
+
+func (ts ThreadStates) String() string {
+	var out []string
+	for _, s := range ts {
+		if pc, ok := s.PC(); ok {
+			out = append(out, fmt.Sprintf("%s pc=%#x", s, pc))
+		} else {
+			out = append(out, s.String())
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
 // A Thread represents a Mach-O LC_THREAD command.
 type Thread struct {
 	LoadBytes
 	types.Thread
-	Type uint32
-	Data []uint32
+	States ThreadStates
 }
 
 func (t *Thread) String() string {
-	return fmt.Sprintf("Type: %d", t.Type)
+	return t.States.String()
 }
 
 /*******************************************************************************
@@ -598,6 +675,7 @@ func (t *Thread) String() string {
 type UnixThread struct {
 	LoadBytes
 	types.UnixThreadCmd
+	States     ThreadStates
 	EntryPoint uint64
 }
 
@@ -1062,6 +1140,61 @@ type SplitInfo struct {
 	Size    uint32
 	Version uint8
 	Offsets []uint64
+	Entries []SplitInfoEntry
+}
+
+// A SplitInfoEntry is a single reference recorded in a LC_SEGMENT_SPLIT_INFO
+// payload that must be adjusted when the image is put in a shared cache.
+//
+// For v1 payloads only Kind and FromOffset (the offset from the start of
+// __TEXT) are set. For v2 payloads the sections are indexes into the image's
+// sections in load command order, starting at 1; section 0 is the mach header.
+type SplitInfoEntry struct {
+	Kind        uint64
+	FromSection uint64
+	FromOffset  uint64
+	ToSection   uint64
+	ToOffset    uint64
+	v1          bool
+}
+
+func (e SplitInfoEntry) String() string {
+	if e.v1 {
+		return fmt.Sprintf("kind=%#x offset=%#x", e.Kind, e.FromOffset)
+	}
+	return fmt.Sprintf("kind=%s from=%d:%#x to=%d:%#x", splitInfoKindName(e.Kind), e.FromSection, e.FromOffset, e.ToSection, e.ToOffset)
+}
+
+func splitInfoKindName(kind uint64) string {
+	switch kind {
+	case types.DYLD_CACHE_ADJ_V2_POINTER_32:
+		return "pointer32"
+	case types.DYLD_CACHE_ADJ_V2_POINTER_64:
+		return "pointer64"
+	case types.DYLD_CACHE_ADJ_V2_DELTA_32:
+		return "delta32"
+	case types.DYLD_CACHE_ADJ_V2_DELTA_64:
+		return "delta64"
+	case types.DYLD_CACHE_ADJ_V2_ARM64_ADRP:
+		return "arm64_adrp"
+	case types.DYLD_CACHE_ADJ_V2_ARM64_OFF12:
+		return "arm64_off12"
+	case types.DYLD_CACHE_ADJ_V2_ARM64_BR26:
+		return "arm64_br26"
+	case types.DYLD_CACHE_ADJ_V2_ARM_MOVW_MOVT:
+		return "arm_movw_movt"
+	case types.DYLD_CACHE_ADJ_V2_ARM_BR24:
+		return "arm_br24"
+	case types.DYLD_CACHE_ADJ_V2_THUMB_MOVW_MOVT:
+		return "thumb_movw_movt"
+	case types.DYLD_CACHE_ADJ_V2_THUMB_BR22:
+		return "thumb_br22"
+	case types.DYLD_CACHE_ADJ_V2_IMAGE_OFF_32:
+		return "image_off32"
+	case types.DYLD_CACHE_ADJ_V2_THREADED_POINTER_64:
+		return "threaded_pointer64"
+	}
+	return fmt.Sprintf("%#x", kind)
 }
 
 func (s *SplitInfo) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
@@ -1587,15 +1720,45 @@ func (o *LinkerOption) String() string {
  * LC_LINKER_OPTIMIZATION_HINT - linker options in MH_OBJECT files
  *******************************************************************************/
 
+// A LinkerOptimizationHint represents a Mach-O LC_LINKER_OPTIMIZATION_HINT command.
 type LinkerOptimizationHint struct {
 	LoadBytes
 	types.LinkerOptimizationHintCmd
 	Offset uint32
 	Size   uint32
+	Hints  []LOH
 }
 
 func (l *LinkerOptimizationHint) String() string {
-	return fmt.Sprintf("offset=0x%08x-0x%08x size=%5d", l.Offset, l.Offset+l.Size, l.Size)
+	return fmt.Sprintf("offset=0x%08x-0x%08x size=%5d hints=%d", l.Offset, l.Offset+l.Size, l.Size, len(l.Hints))
+}
+
+// A LOH is a single linker optimization hint that ld64 may apply to a
+// sequence of ARM64 instructions, e.g. replacing an ADRP/ADD pair with an ADR.
+type LOH struct {
+	Kind      types.LOHKind
+	Addresses []uint64 // addresses of the instructions in the sequence
+}
+
+// Within returns true if all the hint's instructions are in [start, end).
+func (h LOH) Within(start, end uint64) bool {
+	if len(h.Addresses) == 0 {
+		return false
+	}
+	for _, addr := range h.Addresses {
+		if addr < start || addr >= end {
+			return false
+		}
+	}
+	return true
+}
+
+func (h LOH) String() string {
+	var addrs []string
+	for _, addr := range h.Addresses {
+		addrs = append(addrs, fmt.Sprintf("%#x", addr))
+	}
+	return fmt.Sprintf("%s %s", h.Kind, strings.Join(addrs, ", "))
 }
 
 func (l *LinkerOptimizationHint) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
@@ -1775,6 +1938,156 @@ func (f *FilesetEntry) String() string {
 	return fmt.Sprintf("offset=0x%09x addr=0x%016x %s", f.Offset, f.Addr, f.EntryID)
 }
 
+/*******************************************************************************
+ * LC_ATOM_INFO
+ *******************************************************************************/
+
+// An AtomInfo used with linkedit_data_command
+type AtomInfo struct {
+	LoadBytes
+	types.AtomInfoCmd
+	Offset uint32
+	Size   uint32
+}
+
+func (a *AtomInfo) Put(b []byte, o binary.ByteOrder) int {
+	o.PutUint32(b[0*4:], uint32(a.LoadCmd))
+	o.PutUint32(b[1*4:], a.Len)
+	o.PutUint32(b[2*4:], a.Offset)
+	o.PutUint32(b[3*4:], a.Size)
+	return int(a.Len)
+}
+
+func (a *AtomInfo) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
+	if err := binary.Write(buf, o, types.AtomInfoCmd{
+		LoadCmd: a.LoadCmd,
+		Len:     a.Len,
+		Offset:  a.Offset,
+		Size:    a.Size,
+	}); err != nil {
+		return fmt.Errorf("failed to write LC_ATOM_INFO to buffer: %v", err)
+	}
+	return nil
+}
+
+func (a *AtomInfo) String() string {
+	return fmt.Sprintf("offset=0x%08x-0x%08x size=%5d", a.Offset, a.Offset+a.Size, a.Size)
+}
+
+/*******************************************************************************
+ * LC_FUNCTION_VARIANTS
+ *******************************************************************************/
+
+// A FunctionVariants used with linkedit_data_command, payload is the function variants tables
+type FunctionVariants struct {
+	LoadBytes
+	types.FunctionVariantsCmd
+	Offset uint32
+	Size   uint32
+}
+
+func (f *FunctionVariants) Put(b []byte, o binary.ByteOrder) int {
+	o.PutUint32(b[0*4:], uint32(f.LoadCmd))
+	o.PutUint32(b[1*4:], f.Len)
+	o.PutUint32(b[2*4:], f.Offset)
+	o.PutUint32(b[3*4:], f.Size)
+	return int(f.Len)
+}
+
+func (f *FunctionVariants) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
+	if err := binary.Write(buf, o, types.FunctionVariantsCmd{
+		LoadCmd: f.LoadCmd,
+		Len:     f.Len,
+		Offset:  f.Offset,
+		Size:    f.Size,
+	}); err != nil {
+		return fmt.Errorf("failed to write LC_FUNCTION_VARIANTS to buffer: %v", err)
+	}
+	return nil
+}
+
+func (f *FunctionVariants) String() string {
+	return fmt.Sprintf("offset=0x%08x-0x%08x size=%5d", f.Offset, f.Offset+f.Size, f.Size)
+}
+
+/*******************************************************************************
+ * LC_FUNCTION_VARIANT_FIXUPS
+ *******************************************************************************/
+
+// A FunctionVariantFixups used with linkedit_data_command, payload is the function variant fixups
+type FunctionVariantFixups struct {
+	LoadBytes
+	types.FunctionVariantFixupsCmd
+	Offset uint32
+	Size   uint32
+}
+
+func (f *FunctionVariantFixups) Put(b []byte, o binary.ByteOrder) int {
+	o.PutUint32(b[0*4:], uint32(f.LoadCmd))
+	o.PutUint32(b[1*4:], f.Len)
+	o.PutUint32(b[2*4:], f.Offset)
+	o.PutUint32(b[3*4:], f.Size)
+	return int(f.Len)
+}
+
+func (f *FunctionVariantFixups) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
+	if err := binary.Write(buf, o, types.FunctionVariantFixupsCmd{
+		LoadCmd: f.LoadCmd,
+		Len:     f.Len,
+		Offset:  f.Offset,
+		Size:    f.Size,
+	}); err != nil {
+		return fmt.Errorf("failed to write LC_FUNCTION_VARIANT_FIXUPS to buffer: %v", err)
+	}
+	return nil
+}
+
+func (f *FunctionVariantFixups) String() string {
+	return fmt.Sprintf("offset=0x%08x-0x%08x size=%5d count=%d", f.Offset, f.Offset+f.Size, f.Size, f.Size/uint32(binary.Size(types.FunctionVariantFixup{})))
+}
+
+/*******************************************************************************
+ * LC_TARGET_TRIPLE
+ *******************************************************************************/
+
+// A TargetTriple represents a Mach-O LC_TARGET_TRIPLE command.
+type TargetTriple struct {
+	LoadBytes
+	types.TargetTripleCmd
+	Triple string
+}
+
+func (t *TargetTriple) Put(b []byte, o binary.ByteOrder) int {
+	o.PutUint32(b[0*4:], uint32(t.LoadCmd))
+	o.PutUint32(b[1*4:], t.Len)
+	off := t.TargetTripleCmd.Triple
+	if off < uint32(binary.Size(t.TargetTripleCmd)) || off > t.Len {
+		off = uint32(binary.Size(t.TargetTripleCmd))
+	}
+	o.PutUint32(b[2*4:], off)
+	for i := 3 * 4; i < int(off); i++ {
+		b[i] = 0
+	}
+	n := copy(b[off:t.Len], t.Triple)
+	for i := int(off) + n; i < int(t.Len); i++ {
+		b[i] = 0
+	}
+	return int(t.Len)
+}
+
+func (t *TargetTriple) Write(buf *bytes.Buffer, o binary.ByteOrder) error {
+	dat := make([]byte, t.Len)
+	t.Put(dat, o)
+	if _, err := buf.Write(dat); err != nil {
+		return fmt.Errorf("failed to write LC_TARGET_TRIPLE to buffer: %v", err)
+	}
+	return nil
+}
+
+func (t *TargetTriple) String() string {
+	return t.Triple
+}
+
 /*******************************************************************************
  * LC_CODE_SIGNATURE, LC_SEGMENT_SPLIT_INFO,
  * LC_FUNCTION_STARTS, LC_DATA_IN_CODE,
@@ -0,0 +1,490 @@
+package macho
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// Well-known LC_NOTE data owners found in MH_CORE files.
+const (
+	NoteAddrableBits    = "addrable bits"
+	NoteMainBinSpec     = "main bin spec"
+	NoteLoadBinaries    = "load binaries"
+	NoteKernVerStr      = "kern ver str"
+	NoteProcessMetadata = "process metadata"
+)
+
+// CoreAddressUnknown is used by core file notes for addresses that were not recorded.
+const CoreAddressUnknown = ^uint64(0)
+
+// AddrableBits is the decoded "addrable bits" LC_NOTE payload; it records
+// the number of bits used for addressing so that PAC/TBI bits can be stripped.
+type AddrableBits struct {
+	Version uint32
+	// LowBits is the number of addressing bits for low (user) memory
+	LowBits uint32
+	// HighBits is the number of addressing bits for high (kernel) memory
+	HighBits uint32
+}
+
+// Mask returns the mask of the addressable bits of a low memory pointer.
+func (a *AddrableBits) Mask() uint64 {
+	if a.LowBits == 0 || a.LowBits >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<a.LowBits - 1
+}
+
+func (a *AddrableBits) String() string {
+	return fmt.Sprintf("version=%d low_bits=%d high_bits=%d", a.Version, a.LowBits, a.HighBits)
+}
+
+// MainBinType is the type of the main binary described in a "main bin spec" LC_NOTE.
+type MainBinType uint32
+
+const (
+	MainBinUnspecified MainBinType = 0
+	MainBinKernel      MainBinType = 1
+	MainBinUser        MainBinType = 2
+	MainBinStandalone  MainBinType = 3
+)
+
+func (t MainBinType) String() string {
+	switch t {
+	case MainBinUnspecified:
+		return "unspecified"
+	case MainBinKernel:
+		return "kernel"
+	case MainBinUser:
+		return "user process"
+	case MainBinStandalone:
+		return "standalone"
+	}
+	return fmt.Sprintf("MainBinType(%d)", t)
+}
+
+// MainBinSpec is the decoded "main bin spec" LC_NOTE payload.
+type MainBinSpec struct {
+	Version      uint32
+	Type         MainBinType
+	Address      uint64 // CoreAddressUnknown if not recorded
+	Slide        uint64 // CoreAddressUnknown if not recorded (version 2+)
+	UUID         types.UUID
+	Log2PageSize uint32
+	Platform     types.Platform // version 2+
+}
+
+func (m *MainBinSpec) String() string {
+	return fmt.Sprintf("version=%d type=%s address=%#x slide=%#x uuid=%s log2_pagesize=%d platform=%s",
+		m.Version, m.Type, m.Address, m.Slide, m.UUID, m.Log2PageSize, m.Platform)
+}
+
+type loadBinariesHdr struct {
+	Version        uint32
+	ImageCount     uint32
+	EntriesFileOff uint64
+	EntriesSize    uint32
+	Unused         uint32
+}
+
+type loadBinariesEntry struct {
+	FilePathOffset uint64
+	UUID           types.UUID
+	LoadAddress    uint64
+	SegAddrsOffset uint64
+	SegmentCount   uint32
+	Reserved       uint32
+}
+
+type loadBinariesSegment struct {
+	Name   [16]byte
+	VMAddr uint64
+	Unused uint64
+}
+
+// A CoreImageSegment is the load address of a segment of an image in a core file.
+type CoreImageSegment struct {
+	Name string
+	Addr uint64
+}
+
+// A CoreImage is a binary that was loaded in the process or kernel a core file was taken from.
+type CoreImage struct {
+	Name        string
+	UUID        types.UUID
+	LoadAddress uint64 // CoreAddressUnknown if not recorded
+	Segments    []CoreImageSegment
+	Main        bool // described by the "main bin spec" note
+}
+
+func (i CoreImage) String() string {
+	return fmt.Sprintf("%#016x %s %s", i.LoadAddress, i.UUID, i.Name)
+}
+
+// ProcessMetadata is the decoded "process metadata" LC_NOTE payload.
+type ProcessMetadata struct {
+	Threads []struct {
+		ThreadID uint64 `json:"thread_id"`
+	} `json:"threads,omitempty"`
+	// JSON is the raw metadata as stored in the note
+	JSON string `json:"-"`
+}
+
+// Notes returns all the LC_NOTE load commands.
+func (f *File) Notes() []*Note {
+	var notes []*Note
+	for _, l := range f.Loads {
+		if n, ok := l.(*Note); ok {
+			notes = append(notes, n)
+		}
+	}
+	return notes
+}
+
+// Note returns the first LC_NOTE with the given data owner, or nil if none exists.
+func (f *File) Note(owner string) *Note {
+	for _, l := range f.Loads {
+		if n, ok := l.(*Note); ok && n.DataOwner == owner {
+			return n
+		}
+	}
+	return nil
+}
+
+// GetNoteData returns the payload of a LC_NOTE.
+func (f *File) GetNoteData(n *Note) ([]byte, error) {
+	if n.Offset > math.MaxInt64 || n.Size > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid LC_NOTE %q data offset %#x or size %#x", n.DataOwner, n.Offset, n.Size)
+	}
+	// read through a section reader so a corrupt size fails at the end of the file instead of allocating it
+	dat, err := io.ReadAll(io.NewSectionReader(f.sr, int64(n.Offset), int64(n.Size)))
+	if err == nil && uint64(len(dat)) != n.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LC_NOTE %q data at offset %#x: %v", n.DataOwner, n.Offset, err)
+	}
+	return dat, nil
+}
+
+func (f *File) getNoteData(owner string) ([]byte, error) {
+	n := f.Note(owner)
+	if n == nil {
+		return nil, fmt.Errorf("LC_NOTE %q not found", owner)
+	}
+	return f.GetNoteData(n)
+}
+
+// GetAddrableBits returns the decoded "addrable bits" LC_NOTE.
+func (f *File) GetAddrableBits() (*AddrableBits, error) {
+	dat, err := f.getNoteData(NoteAddrableBits)
+	if err != nil {
+		return nil, err
+	}
+	if len(dat) < 8 {
+		return nil, fmt.Errorf("LC_NOTE %q is too small: %d bytes", NoteAddrableBits, len(dat))
+	}
+	ab := &AddrableBits{Version: f.ByteOrder.Uint32(dat[0:])}
+	switch ab.Version {
+	case 3:
+		ab.LowBits = f.ByteOrder.Uint32(dat[4:])
+		ab.HighBits = ab.LowBits
+	case 4:
+		if len(dat) < 12 {
+			return nil, fmt.Errorf("LC_NOTE %q is too small: %d bytes", NoteAddrableBits, len(dat))
+		}
+		ab.LowBits = f.ByteOrder.Uint32(dat[4:])
+		ab.HighBits = f.ByteOrder.Uint32(dat[8:])
+	default:
+		return nil, fmt.Errorf("unsupported LC_NOTE %q version: %d", NoteAddrableBits, ab.Version)
+	}
+	return ab, nil
+}
+
+// GetMainBinSpec returns the decoded "main bin spec" LC_NOTE.
+func (f *File) GetMainBinSpec() (*MainBinSpec, error) {
+	dat, err := f.getNoteData(NoteMainBinSpec)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(dat)
+	mbs := &MainBinSpec{Slide: CoreAddressUnknown}
+	if err := binary.Read(r, f.ByteOrder, &mbs.Version); err != nil {
+		return nil, fmt.Errorf("failed to read LC_NOTE %q version: %v", NoteMainBinSpec, err)
+	}
+	switch mbs.Version {
+	case 1:
+		var v1 struct {
+			Type         MainBinType
+			Address      uint64
+			UUID         types.UUID
+			Log2PageSize uint32
+			Unused       uint32
+		}
+		if err := binary.Read(r, f.ByteOrder, &v1); err != nil {
+			return nil, fmt.Errorf("failed to read LC_NOTE %q: %v", NoteMainBinSpec, err)
+		}
+		mbs.Type = v1.Type
+		mbs.Address = v1.Address
+		mbs.UUID = v1.UUID
+		mbs.Log2PageSize = v1.Log2PageSize
+	case 2:
+		var v2 struct {
+			Type         MainBinType
+			Address      uint64
+			Slide        uint64
+			UUID         types.UUID
+			Log2PageSize uint32
+			Platform     types.Platform
+		}
+		if err := binary.Read(r, f.ByteOrder, &v2); err != nil {
+			return nil, fmt.Errorf("failed to read LC_NOTE %q: %v", NoteMainBinSpec, err)
+		}
+		mbs.Type = v2.Type
+		mbs.Address = v2.Address
+		mbs.Slide = v2.Slide
+		mbs.UUID = v2.UUID
+		mbs.Log2PageSize = v2.Log2PageSize
+		mbs.Platform = v2.Platform
+	default:
+		return nil, fmt.Errorf("unsupported LC_NOTE %q version: %d", NoteMainBinSpec, mbs.Version)
+	}
+	return mbs, nil
+}
+
+// GetLoadBinaries returns the images listed in the "load binaries" LC_NOTE.
+func (f *File) GetLoadBinaries() ([]CoreImage, error) {
+	dat, err := f.getNoteData(NoteLoadBinaries)
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr loadBinariesHdr
+	if err := binary.Read(bytes.NewReader(dat), f.ByteOrder, &hdr); err != nil {
+		return nil, fmt.Errorf("failed to read LC_NOTE %q header: %v", NoteLoadBinaries, err)
+	}
+	if hdr.Version != 1 {
+		return nil, fmt.Errorf("unsupported LC_NOTE %q version: %d", NoteLoadBinaries, hdr.Version)
+	}
+
+	// entries_size is the size of one entry, entries may have grown in later versions
+	if hdr.EntriesSize < uint32(binary.Size(loadBinariesEntry{})) {
+		return nil, fmt.Errorf("LC_NOTE %q entry size %#x is too small", NoteLoadBinaries, hdr.EntriesSize)
+	}
+	var entries []loadBinariesEntry
+	for i := uint64(0); i < uint64(hdr.ImageCount); i++ {
+		var e loadBinariesEntry
+		sr := io.NewSectionReader(f.sr, int64(hdr.EntriesFileOff+i*uint64(hdr.EntriesSize)), int64(hdr.EntriesSize))
+		if err := binary.Read(sr, f.ByteOrder, &e); err != nil {
+			return nil, fmt.Errorf("failed to read LC_NOTE %q entry %d: %v", NoteLoadBinaries, i, err)
+		}
+		entries = append(entries, e)
+	}
+
+	images := make([]CoreImage, 0, len(entries))
+	for _, e := range entries {
+		img := CoreImage{
+			UUID:        e.UUID,
+			LoadAddress: e.LoadAddress,
+		}
+		if e.FilePathOffset != CoreAddressUnknown {
+			img.Name, err = f.GetCStringAtOffset(int64(e.FilePathOffset))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read LC_NOTE %q image path: %v", NoteLoadBinaries, err)
+			}
+		}
+		if e.SegAddrsOffset != CoreAddressUnknown && e.SegmentCount > 0 {
+			// read the segments one at a time, the count comes straight from the file
+			segSize := uint64(binary.Size(loadBinariesSegment{}))
+			for i := uint64(0); i < uint64(e.SegmentCount); i++ {
+				var seg loadBinariesSegment
+				sr := io.NewSectionReader(f.sr, int64(e.SegAddrsOffset+i*segSize), int64(segSize))
+				if err := binary.Read(sr, f.ByteOrder, &seg); err != nil {
+					return nil, fmt.Errorf("failed to read LC_NOTE %q segment addresses for %s: %v", NoteLoadBinaries, img.Name, err)
+				}
+				img.Segments = append(img.Segments, CoreImageSegment{
+					Name: strings.TrimRight(string(seg.Name[:]), "\x00"),
+					Addr: seg.VMAddr,
+				})
+			}
+		}
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+// GetKernelVersion returns the string stored in the "kern ver str" LC_NOTE.
+func (f *File) GetKernelVersion() (string, error) {
+	dat, err := f.getNoteData(NoteKernVerStr)
+	if err != nil {
+		return "", err
+	}
+	if len(dat) < 4 {
+		return "", fmt.Errorf("LC_NOTE %q is too small: %d bytes", NoteKernVerStr, len(dat))
+	}
+	if version := f.ByteOrder.Uint32(dat); version != 1 {
+		return "", fmt.Errorf("unsupported LC_NOTE %q version: %d", NoteKernVerStr, version)
+	}
+	return cstring(dat[4:]), nil
+}
+
+// GetProcessMetadata returns the decoded "process metadata" LC_NOTE.
+func (f *File) GetProcessMetadata() (*ProcessMetadata, error) {
+	dat, err := f.getNoteData(NoteProcessMetadata)
+	if err != nil {
+		return nil, err
+	}
+	pm := &ProcessMetadata{JSON: cstring(dat)}
+	if err := json.Unmarshal([]byte(pm.JSON), pm); err != nil {
+		return nil, fmt.Errorf("failed to parse LC_NOTE %q JSON: %v", NoteProcessMetadata, err)
+	}
+	return pm, nil
+}
+
+// CoreImages returns the images recorded in a core file's "main bin spec"
+// and "load binaries" LC_NOTEs.
+func (f *File) CoreImages() ([]CoreImage, error) {
+	var images []CoreImage
+
+	if f.Note(NoteLoadBinaries) != nil {
+		imgs, err := f.GetLoadBinaries()
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, imgs...)
+	}
+
+	if f.Note(NoteMainBinSpec) != nil {
+		mbs, err := f.GetMainBinSpec()
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for i := range images {
+			if images[i].UUID == mbs.UUID {
+				images[i].Main = true
+				found = true
+			}
+		}
+		if !found {
+			images = append([]CoreImage{{
+				Name:        mbs.Type.String(),
+				UUID:        mbs.UUID,
+				LoadAddress: mbs.Address,
+				Main:        true,
+			}}, images...)
+		}
+	}
+
+	return images, nil
+}
+
+// A VMReader reads a Mach-O's segment data by virtual address. Reads may span
+// adjacent segments, and memory past a segment's file size reads as zeros.
+// It is mainly used to read the memory saved in MH_CORE files.
+type VMReader struct {
+	f    *File
+	segs []*Segment
+}
+
+// VMReader returns a reader over the virtual memory described by the file's segments.
+func (f *File) VMReader() *VMReader {
+	segs := append([]*Segment{}, f.Segments()...)
+	sort.Slice(segs, func(i, j int) bool { return segs[i].Addr < segs[j].Addr })
+	return &VMReader{f: f, segs: segs}
+}
+
+func (r *VMReader) segment(addr uint64) *Segment {
+	i := sort.Search(len(r.segs), func(i int) bool { return r.segs[i].Addr+r.segs[i].Memsz > addr })
+	if i < len(r.segs) && r.segs[i].Addr <= addr {
+		return r.segs[i]
+	}
+	return nil
+}
+
+// ReadAtAddr reads len(buf) bytes starting at the virtual address addr.
+func (r *VMReader) ReadAtAddr(buf []byte, addr uint64) (int, error) {
+	n := 0
+	for n < len(buf) {
+		seg := r.segment(addr + uint64(n))
+		if seg == nil {
+			return n, fmt.Errorf("address %#x not within any segment's address range", addr+uint64(n))
+		}
+		segOff := addr + uint64(n) - seg.Addr
+		chunk := buf[n:]
+		if rem := seg.Memsz - segOff; uint64(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
+		// read what is backed by the file and zero fill the rest
+		fileLen := uint64(0)
+		if segOff < seg.Filesz {
+			fileLen = seg.Filesz - segOff
+			if fileLen > uint64(len(chunk)) {
+				fileLen = uint64(len(chunk))
+			}
+			if _, err := r.f.sr.ReadAt(chunk[:fileLen], int64(seg.Offset+segOff)); err != nil {
+				return n, fmt.Errorf("failed to read segment %s data at address %#x: %v", seg.Name, addr+uint64(n), err)
+			}
+		}
+		for i := fileLen; i < uint64(len(chunk)); i++ {
+			chunk[i] = 0
+		}
+		n += len(chunk)
+	}
+	return n, nil
+}
+
+// ReadAt implements io.ReaderAt, treating off as a virtual address.
+func (r *VMReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtAddr(p, uint64(off))
+}
+
+// GetPointerAtAddress returns the pointer sized value at a given virtual address.
+func (r *VMReader) GetPointerAtAddress(addr uint64) (uint64, error) {
+	if r.f.pointerSize() == 4 {
+		var ptr [4]byte
+		if _, err := r.ReadAtAddr(ptr[:], addr); err != nil {
+			return 0, err
+		}
+		return uint64(r.f.ByteOrder.Uint32(ptr[:])), nil
+	}
+	var ptr [8]byte
+	if _, err := r.ReadAtAddr(ptr[:], addr); err != nil {
+		return 0, err
+	}
+	return r.f.ByteOrder.Uint64(ptr[:]), nil
+}
+
+// GetCString returns the c-string at a given virtual address.
+func (r *VMReader) GetCString(addr uint64) (string, error) {
+	var sb strings.Builder
+	buf := make([]byte, 64)
+	for {
+		seg := r.segment(addr)
+		if seg == nil {
+			return "", fmt.Errorf("address %#x not within any segment's address range", addr)
+		}
+		chunk := buf
+		if rem := seg.Addr + seg.Memsz - addr; uint64(len(chunk)) > rem {
+			chunk = chunk[:rem]
+		}
+		if _, err := r.ReadAtAddr(chunk, addr); err != nil {
+			return "", err
+		}
+		if i := bytes.IndexByte(chunk, 0); i >= 0 {
+			sb.Write(chunk[:i])
+			return sb.String(), nil
+		}
+		sb.Write(chunk)
+		addr += uint64(len(chunk))
+	}
+}
@@ -0,0 +1,204 @@
+package macho
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// A DebugMapSymbol is a function or global of the linked binary and where it came from in its object file.
+type DebugMapSymbol struct {
+	Name       string
+	Type       types.NType // N_FUN, N_STSYM or N_GSYM
+	Sect       uint8       // section number in the linked binary, 0 for N_GSYM
+	BinaryAddr uint64      // address in the linked binary
+	ObjectAddr uint64      // address in the object file, see DebugMapObject.ResolveObjectAddrs
+	Size       uint64      // size of a function, 0 if unknown
+	Resolved   bool        // ObjectAddr was found in the object file's symtab
+}
+
+// IsFunction returns true if the symbol is a function (N_FUN).
+func (s DebugMapSymbol) IsFunction() bool {
+	return s.Type == types.N_FUN
+}
+
+// A DebugMapObject is a compile unit of the linked binary: the object file it
+// was linked from (N_OSO) and the functions and globals it contributed.
+type DebugMapObject struct {
+	Path       string    // N_OSO object file (or archive "lib.a(foo.o)") path
+	ModTime    time.Time // N_OSO modification time of the object file
+	SourceDir  string    // N_SO compilation directory
+	SourceFile string    // N_SO source file
+	Symbols    []DebugMapSymbol
+}
+
+// ResolveObjectAddrs sets the ObjectAddr of the symbols to the address of the
+// symbol with the same name in the object file's symtab.
+// It returns the number of symbols that could not be found.
+func (o *DebugMapObject) ResolveObjectAddrs(obj *File) int {
+	addrs := make(map[string]uint64)
+	if obj.Symtab != nil {
+		for _, sym := range obj.Symtab.Syms {
+			if sym.Type.IsDebugSym() || !(sym.Type.IsDefinedInSection() || sym.Type.IsAbsoluteSym()) {
+				continue
+			}
+			if _, ok := addrs[sym.Name]; !ok {
+				addrs[sym.Name] = sym.Value
+			}
+		}
+	}
+	var missing int
+	for i := range o.Symbols {
+		addr, ok := addrs[o.Symbols[i].Name]
+		if !ok {
+			missing++
+			continue
+		}
+		o.Symbols[i].ObjectAddr = addr
+		o.Symbols[i].Resolved = true
+	}
+	return missing
+}
+
+// A DebugMap maps the functions and globals of a linked binary to the object
+// files they were linked from, as described by its STABS debug entries.
+type DebugMap struct {
+	Objects []*DebugMapObject
+}
+
+// FindSymbol returns the object file and debug map symbol containing the
+// linked binary address addr.
+func (m *DebugMap) FindSymbol(addr uint64) (*DebugMapObject, *DebugMapSymbol) {
+	for _, obj := range m.Objects {
+		for i := range obj.Symbols {
+			sym := &obj.Symbols[i]
+			if addr == sym.BinaryAddr || (addr > sym.BinaryAddr && addr < sym.BinaryAddr+sym.Size) {
+				return obj, sym
+			}
+		}
+	}
+	return nil, nil
+}
+
+// String returns the debug map in the YAML format of `dsymutil --dump-debug-map`.
+func (m *DebugMap) String() string {
+	var sb strings.Builder
+	sb.WriteString("---\nobjects:\n")
+	for _, obj := range m.Objects {
+		fmt.Fprintf(&sb, "  - filename:        '%s'\n", obj.Path)
+		fmt.Fprintf(&sb, "    timestamp:       %d\n", obj.ModTime.Unix())
+		if len(obj.Symbols) == 0 {
+			continue
+		}
+		sb.WriteString("    symbols:\n")
+		for _, sym := range obj.Symbols {
+			fmt.Fprintf(&sb, "      - { sym: %s, objAddr: %#x, binAddr: %#x, size: %#x }\n", sym.Name, sym.ObjectAddr, sym.BinaryAddr, sym.Size)
+		}
+	}
+	sb.WriteString("...\n")
+	return sb.String()
+}
+
+// DebugMap parses the STABS debug entries (N_SO, N_OSO, N_BNSYM/N_FUN/N_ENSYM,
+// N_STSYM and N_GSYM) of the symtab into a debug map, like `dsymutil --dump-debug-map`.
+//
+// The ObjectAddr of the symbols are only set once the object files are opened
+// and passed to DebugMapObject.ResolveObjectAddrs.
+func (f *File) DebugMap() (*DebugMap, error) {
+	if f.Symtab == nil {
+		return nil, fmt.Errorf("no LC_SYMTAB load command")
+	}
+
+	m := &DebugMap{}
+	var obj *DebugMapObject
+	var dir, file string
+	fun := -1 // index of the open N_FUN in obj.Symbols
+
+	for _, sym := range f.Symtab.Syms {
+		if !sym.Type.IsDebugSym() {
+			continue
+		}
+		switch sym.Type {
+		case types.N_SO:
+			if sym.Name == "" { // end of the compile unit
+				obj, fun = nil, -1
+				dir, file = "", ""
+			} else if strings.HasSuffix(sym.Name, "/") {
+				dir = sym.Name
+			} else {
+				file = sym.Name
+			}
+		case types.N_OSO:
+			obj = &DebugMapObject{
+				Path:       sym.Name,
+				ModTime:    time.Unix(int64(sym.Value), 0),
+				SourceDir:  dir,
+				SourceFile: file,
+			}
+			m.Objects = append(m.Objects, obj)
+			fun = -1
+		case types.N_BNSYM, types.N_ENSYM:
+			fun = -1
+		case types.N_FUN:
+			if obj == nil {
+				continue
+			}
+			if sym.Name == "" { // end of the function: the value is its size
+				if fun >= 0 {
+					obj.Symbols[fun].Size = sym.Value
+				}
+				fun = -1
+				continue
+			}
+			obj.Symbols = append(obj.Symbols, DebugMapSymbol{
+				Name:       sym.Name,
+				Type:       types.N_FUN,
+				Sect:       sym.Sect,
+				BinaryAddr: sym.Value,
+			})
+			fun = len(obj.Symbols) - 1
+		case types.N_STSYM:
+			if obj == nil {
+				continue
+			}
+			obj.Symbols = append(obj.Symbols, DebugMapSymbol{
+				Name:       sym.Name,
+				Type:       types.N_STSYM,
+				Sect:       sym.Sect,
+				BinaryAddr: sym.Value,
+			})
+			fun = -1
+		case types.N_GSYM:
+			if obj == nil {
+				continue
+			}
+			// the address of a global is not in the stab, it is the address of the symbol in the linked binary
+			var addr uint64
+			for _, s := range f.SymbolIndex().Lookup(sym.Name) {
+				if s.Source == SymbolSourceSymtab {
+					addr = s.Addr
+					break
+				}
+			}
+			obj.Symbols = append(obj.Symbols, DebugMapSymbol{
+				Name:       sym.Name,
+				Type:       types.N_GSYM,
+				BinaryAddr: addr,
+			})
+			fun = -1
+		}
+	}
+
+	if len(m.Objects) == 0 {
+		return nil, fmt.Errorf("no N_OSO debug entries found in symtab")
+	}
+
+	for _, obj := range m.Objects {
+		sort.SliceStable(obj.Symbols, func(i, j int) bool { return obj.Symbols[i].BinaryAddr < obj.Symbols[j].BinaryAddr })
+	}
+
+	return m, nil
+}
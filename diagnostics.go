@@ -0,0 +1,133 @@
+package macho
+
+import (
+	"fmt"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// DiagnosticKind is the kind of problem found while parsing a Mach-O.
+type DiagnosticKind uint8
+
+const (
+	DiagUnknownLoadCmd         DiagnosticKind = iota + 1 // load command NewFile does not know about
+	DiagMalformedLoadCmd                                 // load command that failed to parse (lenient mode)
+	DiagMalformedSection                                 // section a SectionDecoder failed on (lenient mode)
+	DiagMalformedHeader                                  // truncated or invalid load command block (lenient mode)
+	DiagCodeSignature                                    // unsupported code signature content
+	DiagSwift                                            // unsupported Swift metadata
+	DiagDebugMap                                         // missing or out of date debug map object file
+	DiagLinkerOptimizationHint                           // malformed linker optimization hints
+	DiagRelocation                                       // relocation that could not be applied
+)
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case DiagUnknownLoadCmd:
+		return "unknown load command"
+	case DiagMalformedLoadCmd:
+		return "malformed load command"
+	case DiagMalformedSection:
+		return "malformed section"
+	case DiagMalformedHeader:
+		return "malformed header"
+	case DiagCodeSignature:
+		return "code signature"
+	case DiagSwift:
+		return "swift"
+	case DiagDebugMap:
+		return "debug map"
+	case DiagLinkerOptimizationHint:
+		return "linker optimization hint"
+	case DiagRelocation:
+		return "relocation"
+	}
+	return fmt.Sprintf("DiagnosticKind(%d)", k)
+}
+
+// DiagnosticSeverity is the severity of a Diagnostic.
+type DiagnosticSeverity uint8
+
+const (
+	// DiagWarning is a problem that did not stop parsing.
+	DiagWarning DiagnosticSeverity = iota
+	// DiagError is a problem that would have made NewFile fail if not in lenient mode.
+	DiagError
+)
+
+func (s DiagnosticSeverity) String() string {
+	if s == DiagError {
+		return "error"
+	}
+	return "warning"
+}
+
+// A Diagnostic is a problem found while parsing a Mach-O.
+type Diagnostic struct {
+	Kind     DiagnosticKind
+	Severity DiagnosticSeverity
+	Offset   int64         // file offset of the problem, -1 if unknown
+	Cmd      types.LoadCmd // load command being parsed, 0 if none
+	Message  string
+	Err      error // underlying error, if any
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s: %s", d.Severity, d.Kind)
+	if d.Cmd != 0 {
+		s += fmt.Sprintf(" (%s)", d.Cmd)
+	}
+	if d.Offset >= 0 {
+		s += fmt.Sprintf(" at offset %#x", d.Offset)
+	}
+	return s + ": " + d.Message
+}
+
+// Error implements the error interface.
+func (d Diagnostic) Error() string { return d.String() }
+
+// Unwrap returns the underlying error.
+func (d Diagnostic) Unwrap() error { return d.Err }
+
+// Diagnostics receives the problems found while parsing a Mach-O.
+// The library never writes to stdout/stderr; use a Diagnostics to surface warnings instead.
+type Diagnostics interface {
+	Report(d Diagnostic)
+}
+
+// DiagnosticList is a Diagnostics that collects all the reported problems.
+type DiagnosticList []Diagnostic
+
+// Report appends d to the list.
+func (l *DiagnosticList) Report(d Diagnostic) {
+	*l = append(*l, d)
+}
+
+// DiagnosticsFunc adapts a function to the Diagnostics interface.
+type DiagnosticsFunc func(d Diagnostic)
+
+// Report calls fn(d).
+func (fn DiagnosticsFunc) Report(d Diagnostic) {
+	fn(d)
+}
+
+// Diagnostics returns all the problems found while parsing the file.
+func (f *File) Diagnostics() []Diagnostic {
+	return f.diags
+}
+
+func (f *File) report(d Diagnostic) {
+	f.diags = append(f.diags, d)
+	if f.diag != nil {
+		f.diag.Report(d)
+	}
+}
+
+func (f *File) warn(kind DiagnosticKind, offset int64, format string, args ...interface{}) {
+	f.report(Diagnostic{
+		Kind:     kind,
+		Severity: DiagWarning,
+		Offset:   offset,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
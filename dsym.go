@@ -0,0 +1,205 @@
+package macho
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-dwarf"
+	"github.com/blacktop/go-macho/types"
+)
+
+// dsymDWARFDir is where a dSYM bundle keeps its DWARF companion files.
+const dsymDWARFDir = "Contents/Resources/DWARF"
+
+// A DSYM is the DWARF companion file of a dSYM bundle that matches a binary's UUID.
+type DSYM struct {
+	Path string     // path of the DWARF companion file inside the bundle
+	UUID types.UUID // UUID shared with the binary
+	File *File      // the (slice of the) companion file with that UUID
+
+	closer io.Closer
+}
+
+// DWARF returns the DWARF debug information of the dSYM.
+func (d *DSYM) DWARF() (*dwarf.Data, error) {
+	return d.File.DWARF()
+}
+
+// Close closes the dSYM companion file.
+func (d *DSYM) Close() error {
+	var err error
+	if d.closer != nil {
+		err = d.closer.Close()
+		d.closer = nil
+	}
+	return err
+}
+
+// dsymEntry is where a UUID was found in a DSYMIndex.
+type dsymEntry struct {
+	path string
+	arch int // index in FatFile.Arches, -1 for a thin file
+}
+
+// A DSYMIndex maps the UUIDs of all the architectures of a set of dSYM bundles
+// to their DWARF companion files so they can be paired with binaries.
+type DSYMIndex struct {
+	entries map[types.UUID]dsymEntry
+}
+
+// NewDSYMIndex walks dirs for .dSYM bundles (a dir may itself be a bundle)
+// and reads the UUID of every architecture of every file in their
+// Contents/Resources/DWARF directory. Files that are not Mach-Os are skipped.
+func NewDSYMIndex(dirs ...string) (*DSYMIndex, error) {
+	idx := &DSYMIndex{entries: make(map[types.UUID]dsymEntry)}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() || !strings.HasSuffix(d.Name(), ".dSYM") {
+				return nil
+			}
+			if err := idx.addBundle(path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk %s for dSYM bundles: %v", dir, err)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *DSYMIndex) addBundle(bundle string) error {
+	files, err := os.ReadDir(filepath.Join(bundle, dsymDWARFDir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // not a complete bundle
+		}
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		path := filepath.Join(bundle, dsymDWARFDir, fi.Name())
+		ff, err := OpenFat(path)
+		if err == nil {
+			for i, arch := range ff.Arches {
+				if u := arch.UUID(); u != nil {
+					idx.entries[u.UUID] = dsymEntry{path: path, arch: i}
+				}
+			}
+			ff.Close()
+			continue
+		}
+		if err != ErrNotFat {
+			continue
+		}
+		f, err := Open(path)
+		if err != nil {
+			continue
+		}
+		if u := f.UUID(); u != nil {
+			idx.entries[u.UUID] = dsymEntry{path: path, arch: -1}
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// UUIDs returns the sorted UUIDs of all the indexed dSYMs.
+func (idx *DSYMIndex) UUIDs() []types.UUID {
+	uuids := make([]types.UUID, 0, len(idx.entries))
+	for u := range idx.entries {
+		uuids = append(uuids, u)
+	}
+	sort.Slice(uuids, func(i, j int) bool { return uuids[i].String() < uuids[j].String() })
+	return uuids
+}
+
+// Open opens the dSYM with the given UUID.
+func (idx *DSYMIndex) Open(uuid types.UUID) (*DSYM, error) {
+	e, ok := idx.entries[uuid]
+	if !ok {
+		return nil, fmt.Errorf("no dSYM found for UUID %s", uuid)
+	}
+	if e.arch < 0 {
+		f, err := Open(e.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open dSYM %s: %v", e.path, err)
+		}
+		return &DSYM{Path: e.path, UUID: uuid, File: f, closer: f}, nil
+	}
+	ff, err := OpenFat(e.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dSYM %s: %v", e.path, err)
+	}
+	if e.arch >= len(ff.Arches) {
+		ff.Close()
+		return nil, fmt.Errorf("dSYM %s changed since it was indexed", e.path)
+	}
+	return &DSYM{Path: e.path, UUID: uuid, File: ff.Arches[e.arch].File, closer: ff}, nil
+}
+
+// Find opens the dSYM matching the UUID of the binary.
+func (idx *DSYMIndex) Find(f *File) (*DSYM, error) {
+	u := f.UUID()
+	if u == nil {
+		return nil, fmt.Errorf("binary has no LC_UUID load command")
+	}
+	return idx.Open(u.UUID)
+}
+
+// FindFat opens the dSYMs matching each slice of a universal binary.
+// The returned slice is indexed like ff.Arches, with nil for the slices without a dSYM.
+func (idx *DSYMIndex) FindFat(ff *FatFile) ([]*DSYM, error) {
+	dsyms := make([]*DSYM, len(ff.Arches))
+	var found bool
+	for i, arch := range ff.Arches {
+		u := arch.UUID()
+		if u == nil {
+			continue
+		}
+		if _, ok := idx.entries[u.UUID]; !ok {
+			continue
+		}
+		d, err := idx.Open(u.UUID)
+		if err != nil {
+			for _, d := range dsyms {
+				if d != nil {
+					d.Close()
+				}
+			}
+			return nil, err
+		}
+		dsyms[i] = d
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no dSYM found for any of the %d slices", len(ff.Arches))
+	}
+	return dsyms, nil
+}
+
+// FindDSYMDWARF searches dirs for the dSYM bundle matching the binary's UUID and returns its DWARF.
+func (f *File) FindDSYMDWARF(dirs ...string) (*dwarf.Data, error) {
+	idx, err := NewDSYMIndex(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	d, err := idx.Find(f)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	return d.DWARF()
+}
@@ -862,6 +862,22 @@ func (f *File) writeLoadCommands(buf *bytes.Buffer) error {
 			if err := l.(*FilesetEntry).Write(buf, f.ByteOrder); err != nil {
 				return err
 			}
+		case types.LC_ATOM_INFO:
+			if err := l.(*AtomInfo).Write(buf, f.ByteOrder); err != nil {
+				return err
+			}
+		case types.LC_FUNCTION_VARIANTS:
+			if err := l.(*FunctionVariants).Write(buf, f.ByteOrder); err != nil {
+				return err
+			}
+		case types.LC_FUNCTION_VARIANT_FIXUPS:
+			if err := l.(*FunctionVariantFixups).Write(buf, f.ByteOrder); err != nil {
+				return err
+			}
+		case types.LC_TARGET_TRIPLE:
+			if err := l.(*TargetTriple).Write(buf, f.ByteOrder); err != nil {
+				return err
+			}
 		default:
 			if _, err := buf.Write(l.Raw()); err != nil {
 				return fmt.Errorf("failed to write %s to buffer: %v", l.Command().String(), err)
@@ -21,7 +21,21 @@ type FatFile struct {
 }
 
 // A FatArchHeader represents a fat header for a specific image architecture.
+//
+// Offset and Size are widened to 64 bits so that both the classic fat_arch
+// and the fat_arch_64 layouts can be represented. Reserved is only present
+// in fat_arch_64 headers and is always zero for classic fat files.
 type FatArchHeader struct {
+	CPU      types.CPU
+	SubCPU   types.CPUSubtype
+	Offset   uint64
+	Size     uint64
+	Align    uint32
+	Reserved uint32
+}
+
+// fatArch32 is the on-disk layout of a fat_arch struct.
+type fatArch32 struct {
 	CPU    types.CPU
 	SubCPU types.CPUSubtype
 	Offset uint32
@@ -29,7 +43,20 @@ type FatArchHeader struct {
 	Align  uint32
 }
 
-const fatArchHeaderSize = 5 * 4
+// fatArch64 is the on-disk layout of a fat_arch_64 struct.
+type fatArch64 struct {
+	CPU      types.CPU
+	SubCPU   types.CPUSubtype
+	Offset   uint64
+	Size     uint64
+	Align    uint32
+	Reserved uint32
+}
+
+const (
+	fatArchHeaderSize   = 5 * 4
+	fatArch64HeaderSize = 8 * 4
+)
 
 // A FatArch is a Mach-O File inside a FatFile.
 type FatArch struct {
@@ -43,26 +70,66 @@ var ErrNotFat = &FormatError{0, "not a fat Mach-O file", nil}
 
 // NewFatFile creates a new FatFile for accessing all the Mach-O images in a
 // universal binary. The Mach-O binary is expected to start at position 0 in
-// the ReaderAt.
+// the ReaderAt. Both FAT_MAGIC and FAT_MAGIC_64 headers are supported; the
+// variant that was parsed is recorded in FatFile.Magic.
 func NewFatFile(r io.ReaderAt) (*FatFile, error) {
+	var err error
 	var ff FatFile
+
+	var hdrs []FatArchHeader
+	ff.Magic, hdrs, err = readFatHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure that all images are for the same MH_ type.
+	var machoType types.HeaderFileType
+
+	ff.Arches = make([]FatArch, len(hdrs))
+	for i := range hdrs {
+		fa := &ff.Arches[i]
+		fa.FatArchHeader = hdrs[i]
+
+		fr := io.NewSectionReader(r, int64(fa.Offset), int64(fa.Size))
+		fa.File, err = NewFile(fr)
+		if err != nil {
+			return nil, err
+		}
+
+		// Make sure the Mach-O type matches that of the first image.
+		if i == 0 {
+			machoType = fa.Type
+		} else {
+			if fa.Type != machoType {
+				return nil, &FormatError{int64(fa.Offset), fmt.Sprintf("Mach-O type for architecture #%d (type=%#x) does not match first (type=%#x)", i, fa.Type, machoType), nil}
+			}
+		}
+	}
+
+	return &ff, nil
+}
+
+// readFatHeader reads the fat_header and the fat_arch or fat_arch_64 structs
+// that follow it. The slices they describe are not parsed.
+func readFatHeader(r io.ReaderAt) (types.Magic, []FatArchHeader, error) {
+	var magic types.Magic
 	sr := io.NewSectionReader(r, 0, 1<<63-1)
 
 	// Read the fat_header struct, which is always in big endian.
 	// Start with the magic number.
-	err := binary.Read(sr, binary.BigEndian, &ff.Magic)
+	err := binary.Read(sr, binary.BigEndian, &magic)
 	if err != nil {
-		return nil, &FormatError{0, "error reading magic number", nil}
-	} else if ff.Magic != types.MagicFat {
+		return 0, nil, &FormatError{0, "error reading magic number", nil}
+	} else if magic != types.MagicFat && magic != types.MagicFat64 {
 		// See if this is a Mach-O file via its magic number. The magic
 		// must be converted to little endian first though.
 		var buf [4]byte
-		binary.BigEndian.PutUint32(buf[:], ff.Magic.Int())
+		binary.BigEndian.PutUint32(buf[:], magic.Int())
 		leMagic := binary.LittleEndian.Uint32(buf[:])
 		if leMagic == types.Magic32.Int() || leMagic == types.Magic64.Int() {
-			return nil, ErrNotFat
+			return 0, nil, ErrNotFat
 		}
-		return nil, &FormatError{0, "invalid magic number", nil}
+		return 0, nil, &FormatError{0, "invalid magic number", nil}
 
 	}
 	offset := int64(4)
@@ -71,55 +138,54 @@ func NewFatFile(r io.ReaderAt) (*FatFile, error) {
 	var narch uint32
 	err = binary.Read(sr, binary.BigEndian, &narch)
 	if err != nil {
-		return nil, &FormatError{offset, "invalid fat_header", nil}
+		return 0, nil, &FormatError{offset, "invalid fat_header", nil}
 	}
 	offset += 4
 
 	if narch < 1 {
-		return nil, &FormatError{offset, "file contains no images", nil}
+		return 0, nil, &FormatError{offset, "file contains no images", nil}
 	}
 
 	// Combine the Cpu and SubCpu (both uint32) into a uint64 to make sure
 	// there are not duplicate architectures.
 	seenArches := make(map[uint64]bool, narch)
-	// Make sure that all images are for the same MH_ type.
-	var machoType types.HeaderFileType
 
 	// Following the fat_header comes narch fat_arch structs that index
 	// Mach-O images further in the file.
-	ff.Arches = make([]FatArch, narch)
+	hdrs := make([]FatArchHeader, narch)
 	for i := uint32(0); i < narch; i++ {
-		fa := &ff.Arches[i]
-		err = binary.Read(sr, binary.BigEndian, &fa.FatArchHeader)
-		if err != nil {
-			return nil, &FormatError{offset, "invalid fat_arch header", nil}
-		}
-		offset += fatArchHeaderSize
-
-		fr := io.NewSectionReader(r, int64(fa.Offset), int64(fa.Size))
-		fa.File, err = NewFile(fr)
-		if err != nil {
-			return nil, err
+		fa := &hdrs[i]
+		if magic == types.MagicFat64 {
+			var fa64 fatArch64
+			if err := binary.Read(sr, binary.BigEndian, &fa64); err != nil {
+				return 0, nil, &FormatError{offset, "invalid fat_arch_64 header", nil}
+			}
+			*fa = FatArchHeader(fa64)
+			offset += fatArch64HeaderSize
+		} else {
+			var fa32 fatArch32
+			if err := binary.Read(sr, binary.BigEndian, &fa32); err != nil {
+				return 0, nil, &FormatError{offset, "invalid fat_arch header", nil}
+			}
+			*fa = FatArchHeader{
+				CPU:    fa32.CPU,
+				SubCPU: fa32.SubCPU,
+				Offset: uint64(fa32.Offset),
+				Size:   uint64(fa32.Size),
+				Align:  fa32.Align,
+			}
+			offset += fatArchHeaderSize
 		}
 
 		// Make sure the architecture for this image is not duplicate.
 		seenArch := (uint64(fa.CPU) << 32) | uint64(fa.SubCPU)
 		if o, k := seenArches[seenArch]; o || k {
-			return nil, &FormatError{offset, fmt.Sprintf("duplicate architecture cpu=%v, subcpu=%#x", fa.CPU, fa.SubCPU), nil}
+			return 0, nil, &FormatError{offset, fmt.Sprintf("duplicate architecture cpu=%v, subcpu=%#x", fa.CPU, fa.SubCPU), nil}
 		}
 		seenArches[seenArch] = true
-
-		// Make sure the Mach-O type matches that of the first image.
-		if i == 0 {
-			machoType = fa.Type
-		} else {
-			if fa.Type != machoType {
-				return nil, &FormatError{offset, fmt.Sprintf("Mach-O type for architecture #%d (type=%#x) does not match first (type=%#x)", i, fa.Type, machoType), nil}
-			}
-		}
 	}
 
-	return &ff, nil
+	return magic, hdrs, nil
 }
 
 // OpenFat opens the named file using os.Open and prepares it for use as a Mach-O
@@ -134,6 +200,9 @@ func OpenFat(name string) (*FatFile, error) {
 		f.Close()
 		return nil, err
 	}
+	for _, arch := range ff.Arches {
+		arch.path = name
+	}
 	ff.closer = f
 	return ff, nil
 }
@@ -10,10 +10,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"unsafe"
 
 	"github.com/blacktop/go-dwarf"
@@ -52,6 +53,18 @@ type File struct {
 
 	relativeSelectorBase uint64 // objc_opt version 16
 
+	diag  Diagnostics
+	diags []Diagnostic
+
+	symIndex     *SymbolIndex
+	symIndexOnce sync.Once
+	dwarf        *dwarf.Data // cached for Symbolicate
+	dwarfOnce    sync.Once
+	fixups       *fixupchains.Resolver
+	fixupsErr    error
+	fixupsOnce   sync.Once
+
+	path   string // the file opened by Open or OpenFat, if any
 	closer io.Closer
 }
 
@@ -152,8 +165,12 @@ func (t *FileTOC) DerivedCopy(Type types.HeaderFileType, Flags types.HeaderFlag)
 // of the header and Load Commands (including Segments and Sections, but
 // not their contents) at the beginning of a Mach-O file.  This typically
 // overlaps the text segment in the object file.
-func (t *FileTOC) TOCSize() uint32 {
-	return t.HdrSize() + t.LoadSize()
+func (t *FileTOC) TOCSize() (uint32, error) {
+	hdrSize, err := t.HdrSize()
+	if err != nil {
+		return 0, err
+	}
+	return hdrSize + t.LoadSize(), nil
 }
 
 // LoadAlign returns the required alignment of Load commands in a binary.
@@ -175,16 +192,16 @@ func (t *FileTOC) SymbolSize() uint32 {
 
 // HdrSize returns the size in bytes of the Macho header for a given
 // magic number (where the magic number has been appropriately byte-swapped).
-func (t *FileTOC) HdrSize() uint32 {
+func (t *FileTOC) HdrSize() (uint32, error) {
 	switch t.Magic {
 	case types.Magic32:
-		return types.FileHeaderSize32
+		return types.FileHeaderSize32, nil
 	case types.Magic64:
-		return types.FileHeaderSize64
-	case types.MagicFat:
-		panic("MagicFat not handled yet")
+		return types.FileHeaderSize64, nil
+	case types.MagicFat, types.MagicFat64:
+		return 0, fmt.Errorf("MagicFat not handled yet")
 	default:
-		panic(fmt.Sprintf("Unexpected magic number %#x, expected Mach-O object file", t.Magic))
+		return 0, fmt.Errorf("unexpected magic number %#x, expected Mach-O object file", t.Magic)
 	}
 }
 
@@ -218,7 +235,8 @@ func (t *FileTOC) FileSize() uint64 {
 // the byte ordering specified in FileTOC t.  For sections, this
 // writes the headers that come in-line with the segment Load commands,
 // but does not write the reference data for those sections.
-func (t *FileTOC) Put(buffer []byte) int {
+// Load commands that do not implement Put are copied from their raw bytes.
+func (t *FileTOC) Put(buffer []byte) (int, error) {
 	next := t.FileHeader.Put(buffer, t.ByteOrder)
 	for _, l := range t.Loads {
 		if s, ok := l.(*Segment); ok {
@@ -236,14 +254,20 @@ func (t *FileTOC) Put(buffer []byte) int {
 					next += c.Put32(buffer[next:], t.ByteOrder)
 				}
 			default:
-				panic(fmt.Sprintf("Unexpected magic number %#x", t.Magic))
+				return next, fmt.Errorf("unexpected magic number %#x", t.Magic)
 			}
 
 		} else {
-			next += l.Put(buffer[next:], t.ByteOrder)
+			n := l.Put(buffer[next:], t.ByteOrder)
+			if n == 0 {
+				if n = copy(buffer[next:], l.Raw()); n == 0 {
+					return next, fmt.Errorf("Put not implemented for %s", l.Command())
+				}
+			}
+			next += n
 		}
 	}
-	return next
+	return next, nil
 }
 
 /*
@@ -284,6 +308,13 @@ type FileConfig struct {
 	SectionReader        types.MachoReader
 	CacheReader          types.MachoReader
 	RelativeSelectorBase uint64
+	// Decoders are consulted before DefaultDecoders for unknown load commands and sections
+	Decoders *Decoders
+	// Diagnostics receives the warnings found while parsing (they are also available from File.Diagnostics)
+	Diagnostics Diagnostics
+	// Lenient makes NewFile report malformed load commands and sections as
+	// diagnostics and return the partially parsed File instead of failing
+	Lenient bool
 }
 
 // Open opens the named file using os.Open and prepares it for use as a Mach-O binary.
@@ -297,6 +328,7 @@ func Open(name string) (*File, error) {
 		f.Close()
 		return nil, err
 	}
+	ff.path = name
 	ff.closer = f
 	return ff, nil
 }
@@ -317,6 +349,8 @@ func (f *File) Close() error {
 // The Mach-O binary is expected to start at position 0 in the ReaderAt.
 func NewFile(r io.ReaderAt, config ...FileConfig) (*File, error) {
 	var loadsFilter []types.LoadCmd
+	var decoders decoderList
+	var lenient bool
 
 	f := new(File)
 
@@ -333,17 +367,27 @@ func NewFile(r io.ReaderAt, config ...FileConfig) (*File, error) {
 		}
 		f.vma = &config[0].VMAddrConverter
 		loadsFilter = config[0].LoadFilter
+		if config[0].Decoders != nil {
+			decoders = append(decoders, config[0].Decoders)
+		}
 		f.relativeSelectorBase = config[0].RelativeSelectorBase
-	} else {
+		f.diag = config[0].Diagnostics
+		lenient = config[0].Lenient
+	}
+	if f.sr == nil { // no config or a config without a SectionReader (e.g. only Decoders)
 		f.vma = &types.VMAddrConverter{
 			Converter:    f.convertToVMAddr,
 			VMAddr2Offet: f.getOffset,
 			Offet2VMAddr: f.getVMAddress,
 		}
 		f.sr = types.NewCustomSectionReader(r, f.vma, 0, 1<<63-1)
-		f.cr = f.sr
+		if f.cr == nil {
+			f.cr = f.sr
+		}
 	}
 
+	decoders = append(decoders, DefaultDecoders)
+
 	// Read and decode Mach magic to determine byte order, size.
 	// Magic32 and Magic64 differ only in the bottom bit.
 	var ident [4]byte
@@ -382,994 +426,1155 @@ func NewFile(r io.ReaderAt, config ...FileConfig) (*File, error) {
 	for i := range f.Loads {
 		// Each load command begins with uint32 command and length.
 		if len(dat) < 8 {
-			return nil, &FormatError{offset, "command block too small", nil}
+			if err := f.truncateLoads(i, &FormatError{offset, "command block too small", nil}, lenient); err != nil {
+				return nil, err
+			}
+			break
 		}
 		cmd, siz := types.LoadCmd(bo.Uint32(dat[0:4])), bo.Uint32(dat[4:8])
 		if siz < 8 || siz > uint32(len(dat)) {
-			return nil, &FormatError{offset, "invalid command block size", nil}
+			if err := f.truncateLoads(i, &FormatError{offset, "invalid command block size", nil}, lenient); err != nil {
+				return nil, err
+			}
+			break
 		}
 
 		var cmddat []byte
 		cmddat, dat = dat[0:siz], dat[siz:]
 		offset += int64(siz)
-		var s *Segment
 
 		// skip unwanted load commands
 		if len(loadsFilter) > 0 && !loadInSlice(cmd, loadsFilter) {
 			continue
 		}
 
-		switch cmd {
-		default:
-			log.Printf("found NEW load command: %s, please let the author know :)", cmd)
-			f.Loads[i] = LoadCmdBytes{types.LoadCmd(cmd), LoadBytes(cmddat)}
-		case types.LC_SEGMENT:
-			var seg32 types.Segment32
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &seg32); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SEGMENT: %v", err)
-			}
-			s = new(Segment)
-			s.LoadBytes = cmddat
-			s.LoadCmd = cmd
-			s.Len = siz
-			s.Name = cstring(seg32.Name[0:])
-			s.Addr = uint64(seg32.Addr)
-			s.Memsz = uint64(seg32.Memsz)
-			s.Offset = uint64(seg32.Offset)
-			s.Filesz = uint64(seg32.Filesz)
-			s.Maxprot = seg32.Maxprot
-			s.Prot = seg32.Prot
-			s.Nsect = seg32.Nsect
-			s.Flag = seg32.Flag
-			s.Firstsect = uint32(len(f.Sections))
-			f.Loads[i] = s
-			for i := 0; i < int(s.Nsect); i++ {
-				var sh32 types.Section32
-				if err := binary.Read(b, bo, &sh32); err != nil {
-					return nil, fmt.Errorf("failed to read Section32: %v", err)
-				}
-				sh := new(Section)
-				sh.Type = 32
-				sh.Name = cstring(sh32.Name[0:])
-				sh.Seg = cstring(sh32.Seg[0:])
-				sh.Addr = uint64(sh32.Addr)
-				sh.Size = uint64(sh32.Size)
-				sh.Offset = sh32.Offset
-				sh.Align = sh32.Align
-				sh.Reloff = sh32.Reloff
-				sh.Nreloc = sh32.Nreloc
-				sh.Flags = sh32.Flags
-				sh.Reserved1 = sh32.Reserve1
-				sh.Reserved2 = sh32.Reserve2
-				if err := f.pushSection(sh, f.cr); err != nil {
-					return nil, fmt.Errorf("failed to pushSection32: %v", err)
-				}
+		if err := f.parseLoadCmd(i, cmd, siz, cmddat, offset, decoders); err != nil {
+			if !lenient {
+				return nil, err
 			}
-		case types.LC_SEGMENT_64:
-			var seg64 types.Segment64
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &seg64); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SEGMENT_64: %v", err)
-			}
-			s = new(Segment)
-			s.LoadBytes = cmddat
-			s.LoadCmd = cmd
-			s.Len = siz
-			s.Name = cstring(seg64.Name[0:])
-			s.Addr = seg64.Addr
-			s.Memsz = seg64.Memsz
-			s.Offset = seg64.Offset
-			s.Filesz = seg64.Filesz
-			s.Maxprot = seg64.Maxprot
-			s.Prot = seg64.Prot
-			s.Nsect = seg64.Nsect
-			s.Flag = seg64.Flag
-			s.Firstsect = uint32(len(f.Sections))
-			f.Loads[i] = s
-			for i := 0; i < int(s.Nsect); i++ {
-				var sh64 types.Section64
-				if err := binary.Read(b, bo, &sh64); err != nil {
-					return nil, fmt.Errorf("failed to read Section64: %v", err)
-				}
-				sh := new(Section)
-				sh.Type = 64
-				sh.Name = cstring(sh64.Name[0:])
-				sh.Seg = cstring(sh64.Seg[0:])
-				sh.Addr = sh64.Addr
-				sh.Size = sh64.Size
-				sh.Offset = sh64.Offset
-				sh.Align = sh64.Align
-				sh.Reloff = sh64.Reloff
-				sh.Nreloc = sh64.Nreloc
-				sh.Flags = sh64.Flags
-				sh.Reserved1 = sh64.Reserve1
-				sh.Reserved2 = sh64.Reserve2
-				sh.Reserved3 = sh64.Reserve3
-				if err := f.pushSection(sh, f.cr); err != nil {
-					return nil, fmt.Errorf("failed to pushSection64: %v", err)
+			f.report(Diagnostic{
+				Kind:     DiagMalformedLoadCmd,
+				Severity: DiagError,
+				Offset:   offset - int64(siz),
+				Cmd:      cmd,
+				Message:  err.Error(),
+				Err:      err,
+			})
+			f.Loads[i] = LoadCmdBytes{cmd, LoadBytes(cmddat)}
+		}
+	}
+
+	for _, sec := range f.Sections {
+		if dec := decoders.section(sec.Seg, sec.Name); dec != nil {
+			v, err := dec(f, sec)
+			if err != nil {
+				err = fmt.Errorf("failed to decode section %s.%s: %v", sec.Seg, sec.Name, err)
+				if !lenient {
+					return nil, err
 				}
+				f.report(Diagnostic{
+					Kind:     DiagMalformedSection,
+					Severity: DiagError,
+					Offset:   int64(sec.Offset),
+					Message:  err.Error(),
+					Err:      err,
+				})
+				continue
 			}
-		case types.LC_SYMTAB:
-			var hdr types.SymtabCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SYMTAB: %v", err)
-			}
+			sec.Decoded = v
+		}
+	}
 
-			strtab := make([]byte, hdr.Strsize)
-			if _, err := f.cr.ReadAt(strtab, int64(hdr.Stroff)); err != nil {
-				return nil, fmt.Errorf("failed to read data at Stroff=%#x; %v", int64(hdr.Stroff), err)
-			}
+	return f, nil
+}
 
-			var symsz int
-			if f.Magic == types.Magic64 {
-				symsz = 16
-			} else {
-				symsz = 12
-			}
-			symdat := make([]byte, int(hdr.Nsyms)*symsz)
-			if _, err := f.cr.ReadAt(symdat, int64(hdr.Symoff)); err != nil {
-				return nil, fmt.Errorf("failed to read data at Symoff=%#x; %v", int64(hdr.Symoff), err)
-			}
+// truncateLoads drops the load commands from i on after a malformed command block.
+// It returns err unless in lenient mode, where the error is reported instead.
+func (f *File) truncateLoads(i int, err *FormatError, lenient bool) error {
+	if !lenient {
+		return err
+	}
+	f.report(Diagnostic{
+		Kind:     DiagMalformedHeader,
+		Severity: DiagError,
+		Offset:   err.off,
+		Message:  err.Error(),
+		Err:      err,
+	})
+	f.Loads = f.Loads[:i]
+	return nil
+}
 
-			st, err := f.parseSymtab(symdat, strtab, cmddat, &hdr, offset)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read parseSymtab: %v", err)
-			}
-			st.LoadBytes = cmddat
-			st.LoadCmd = cmd
-			st.Len = siz
-			f.Loads[i] = st
-			f.Symtab = st
-		case types.LC_SYMSEG:
-			var led types.SymsegCommand
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SYMSEG: %v", err)
-			}
-
-			l := new(SymSeg)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_THREAD:
-			var t types.Thread
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &t); err != nil {
-				return nil, fmt.Errorf("failed to read LC_THREAD: %v", err)
-			}
-			l := new(Thread)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Type = t.Type
-			l.Data = make([]uint32, t.Len-3*uint32(binary.Size(uint32(0)))/uint32(binary.Size(uint32(0))))
-			if err := binary.Read(b, bo, &l.Data); err != nil {
-				return nil, fmt.Errorf("failed to read Thread data: %v", err)
-			}
-			f.Loads[i] = l
-		case types.LC_UNIXTHREAD:
-			var ut types.UnixThreadCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &ut); err != nil {
-				return nil, fmt.Errorf("failed to read LC_UNIXTHREAD: %v", err)
-			}
-			l := new(UnixThread)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			// TODO: handle all flavors
-			if ut.Flavor == 6 {
-				regs := make([]uint64, ut.Count/2)
-				if err := binary.Read(b, bo, &regs); err != nil {
-					return nil, fmt.Errorf("failed to read UnixThread registers: %v", err)
-				}
-				// this is to get the program counter register
-				l.EntryPoint = regs[len(regs)-2]
-			}
-			f.Loads[i] = l
-		case types.LC_LOADFVMLIB:
-			var hdr types.LoadFvmLibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LOADFVMLIB: %v", err)
-			}
-			l := new(LoadFvmlib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in LC_LOADFVMLIB command", hdr.Name}
-			}
-			l.MinorVersion = types.Version(hdr.MinorVersion)
-			l.HeaderAddr = hdr.HeaderAddr
-			f.Loads[i] = l
-		case types.LC_IDFVMLIB:
-			var hdr types.IDFvmLibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_IDFVMLIB: %v", err)
-			}
-			l := new(IDFvmlib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in LC_IDFVMLIB command", hdr.Name}
-			}
-			l.MinorVersion = types.Version(hdr.MinorVersion)
-			l.HeaderAddr = hdr.HeaderAddr
-			f.Loads[i] = l
-		case types.LC_IDENT:
-			var hdr types.IdentCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_IDENT: %v", err)
-			}
-			l := new(Ident)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Length = hdr.Len
-			f.Loads[i] = l
-		case types.LC_FVMFILE:
-			var hdr types.FvmFileCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_FVMFILE: %v", err)
-			}
-			l := new(FvmFile)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in LC_FVMFILE command", hdr.Name}
-			}
-			l.HeaderAddr = hdr.HeaderAddr
-			f.Loads[i] = l
-		case types.LC_PREPAGE:
-			var hdr types.PrePageCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_PREPAGE: %v", err)
-			}
-			l := new(Prepage)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			f.Loads[i] = l
-		case types.LC_DYSYMTAB:
-			var hdr types.DysymtabCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYSYMTAB: %v", err)
-			}
-			if hdr.Iundefsym > uint32(len(f.Symtab.Syms)) {
-				return nil, &FormatError{offset, fmt.Sprintf(
-					"undefined symbols index in dynamic symbol table command is greater than symbol table length (%d > %d)",
-					hdr.Iundefsym, len(f.Symtab.Syms)), nil}
-			} else if hdr.Iundefsym+hdr.Nundefsym > uint32(len(f.Symtab.Syms)) {
-				return nil, &FormatError{offset, fmt.Sprintf(
-					"number of undefined symbols after index in dynamic symbol table command is greater than symbol table length (%d > %d)",
-					hdr.Iundefsym+hdr.Nundefsym, len(f.Symtab.Syms)), nil}
-			}
-			dat := make([]byte, hdr.Nindirectsyms*4)
-			if _, err := f.cr.ReadAt(dat, int64(hdr.Indirectsymoff)); err != nil {
-				return nil, fmt.Errorf("failed to read data at Indirectsymoff=%#x; %v", int64(hdr.Indirectsymoff), err)
-			}
-			x := make([]uint32, hdr.Nindirectsyms)
-			if err := binary.Read(bytes.NewReader(dat), bo, x); err != nil {
-				return nil, fmt.Errorf("failed to read Nindirectsyms: %v", err)
-			}
-			st := new(Dysymtab)
-			st.LoadBytes = cmddat
-			st.LoadCmd = cmd
-			st.Len = siz
-			st.DysymtabCmd = hdr
-			st.IndirectSyms = x
-			f.Loads[i] = st
-			f.Dysymtab = st
-		case types.LC_LOAD_DYLIB:
-			var hdr types.DylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LOAD_DYLIB: %v", err)
-			}
-			l := new(Dylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in dynamic library command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_ID_DYLIB:
-			var hdr types.DylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ID_DYLIB: %v", err)
-			}
-			l := new(DylibID)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in dynamic library ident command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_LOAD_DYLINKER:
-			var hdr types.DylinkerCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LOAD_DYLINKER: %v", err)
-			}
-			l := new(LoadDylinker)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in load dylinker command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			f.Loads[i] = l
-		case types.LC_ID_DYLINKER:
-			var hdr types.DylinkerIDCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ID_DYLINKER: %v", err)
-			}
-			l := new(DylinkerID)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in load dylinker command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			f.Loads[i] = l
-		case types.LC_PREBOUND_DYLIB:
-			var hdr types.PreboundDylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_PREBOUND_DYLIB: %v", err)
-			}
-			l := new(PreboundDylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in LC_PREBOUND_DYLIB command", hdr.Name}
-			}
-			l.NumModules = hdr.NumModules
-			l.Name = cstring(cmddat[hdr.Name:])
-			if hdr.LinkedModules >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid linked modules in LC_PREBOUND_DYLIB command", hdr.Name}
-			}
-			l.LinkedModules = cstring(cmddat[hdr.LinkedModules:])
-			f.Loads[i] = l
-		case types.LC_ROUTINES:
-			var rt types.RoutinesCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &rt); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ROUTINES: %v", err)
-			}
-			l := new(Routines)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.InitAddress = rt.InitAddress
-			l.InitModule = rt.InitModule
-			f.Loads[i] = l
-		case types.LC_SUB_FRAMEWORK:
-			var sf types.SubFrameworkCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &sf); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SUB_FRAMEWORK: %v", err)
-			}
-			l := new(SubFramework)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if sf.Framework >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid framework in sub-framework command", sf.Framework}
-			}
-			l.Framework = cstring(cmddat[sf.Framework:])
-			f.Loads[i] = l
-		case types.LC_SUB_UMBRELLA:
-			var su types.SubUmbrellaCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &su); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SUB_UMBRELLA: %v", err)
-			}
-			l := new(SubUmbrella)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if su.Umbrella >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid framework in sub-umbrella command", su.Umbrella}
-			}
-			l.Umbrella = cstring(cmddat[su.Umbrella:])
-			f.Loads[i] = l
-		case types.LC_SUB_CLIENT:
-			var sc types.SubClientCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &sc); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SUB_CLIENT: %v", err)
-			}
-			l := new(SubClient)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if sc.Client >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid path in sub client command", sc.Client}
-			}
-			l.Name = cstring(cmddat[sc.Client:])
-			f.Loads[i] = l
-		case types.LC_SUB_LIBRARY:
-			var s types.SubLibraryCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &s); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SUB_LIBRARY: %v", err)
-			}
-			l := new(SubLibrary)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if s.Library >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid framework in sub-library command", s.Library}
-			}
-			l.Library = cstring(cmddat[s.Library:])
-			f.Loads[i] = l
-		case types.LC_TWOLEVEL_HINTS:
-			var t types.TwolevelHintsCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &t); err != nil {
-				return nil, fmt.Errorf("failed to read LC_TWOLEVEL_HINTS: %v", err)
-			}
-			l := new(TwolevelHints)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = t.Offset
-			l.Hints = make([]types.TwolevelHint, t.NumHints)
-			if err := binary.Read(b, bo, &l.Hints); err != nil {
-				return nil, fmt.Errorf("failed to read hints data: %v", err)
-			}
-			f.Loads[i] = l
+// parseLoadCmd parses the i-th load command into f.Loads[i].
+// The offset is the file offset just past the load command.
+func (f *File) parseLoadCmd(i int, cmd types.LoadCmd, siz uint32, cmddat []byte, offset int64, decoders decoderList) error {
+	bo := f.ByteOrder
+	var s *Segment
 
-		case types.LC_PREBIND_CKSUM:
-			var p types.PrebindCksumCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &p); err != nil {
-				return nil, fmt.Errorf("failed to read LC_PREBIND_CKSUM: %v", err)
-			}
-			l := new(PrebindCksum)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.CheckSum = p.CheckSum
-			f.Loads[i] = l
-		case types.LC_LOAD_WEAK_DYLIB:
-			var hdr types.DylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LOAD_WEAK_DYLIB: %v", err)
-			}
-			l := new(WeakDylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in weak dynamic library command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_ROUTINES_64:
-			var r64 types.Routines64Cmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &r64); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ROUTINES_64: %v", err)
-			}
-			l := new(Routines64)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.InitAddress = r64.InitAddress
-			l.InitModule = r64.InitModule
-			f.Loads[i] = l
-		case types.LC_UUID:
-			var u types.UUIDCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &u); err != nil {
-				return nil, fmt.Errorf("failed to read LC_UUID: %v", err)
-			}
-			l := new(UUID)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.ID = u.UUID.String()
-			f.Loads[i] = l
-		case types.LC_RPATH:
-			var hdr types.RpathCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_RPATH: %v", err)
-			}
-			l := new(Rpath)
-			if hdr.Path >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid path in rpath command", hdr.Path}
-			}
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Path >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid path in rpath command", hdr.Path}
-			}
-			l.Path = cstring(cmddat[hdr.Path:])
-			f.Loads[i] = l
-		case types.LC_CODE_SIGNATURE:
-			var hdr types.CodeSignatureCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_CODE_SIGNATURE: %v", err)
-			}
-
-			l := new(CodeSignature)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = hdr.Offset
-			l.Size = hdr.Size
-			csdat := make([]byte, hdr.Size)
-			if _, err := f.cr.ReadAt(csdat, int64(hdr.Offset)); err != nil {
-				return nil, fmt.Errorf("failed to read CS data at offset=%#x; %v", int64(hdr.Offset), err)
-			}
-			cs, err := codesign.ParseCodeSignature(csdat)
+	switch cmd {
+	default:
+		if dec := decoders.loadCmd(cmd); dec != nil {
+			l, err := dec(f, cmd, cmddat)
 			if err != nil {
-				return nil, fmt.Errorf("failed to ParseCodeSignature: %v", err)
+				return fmt.Errorf("failed to decode %s: %w", cmd, err)
 			}
-			l.CodeSignature = *cs
-			f.Loads[i] = l
-		case types.LC_SEGMENT_SPLIT_INFO:
-			var hdr types.SegmentSplitInfoCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SEGMENT_SPLIT_INFO: %v", err)
-			}
-
-			l := new(SplitInfo)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = hdr.Offset
-			l.Size = hdr.Size
-			ldat := make([]byte, l.Size)
-			if _, err := f.cr.ReadAt(ldat, int64(l.Offset)); err != nil {
-				return nil, fmt.Errorf("failed to read SplitInfo data at offset=%#x; %v", int64(hdr.Offset), err)
-			}
-			fsr := bytes.NewReader(ldat)
-			if err := binary.Read(fsr, bo, &l.Version); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SEGMENT_SPLIT_INFO Version: %v", err)
-			}
-			// var offset uint64
-			// for {
-			// 	o, err := trie.ReadUleb128(fsr)
-			// 	if err == io.EOF {
-			// 		break
-			// 	}
-			// 	if err != nil {
-			// 		return nil, err
-			// 	}
-			// 	// if o == 0 {
-			// 	// 	break
-			// 	// }
-			// 	offset += o
-			// 	l.Offsets = append(l.Offsets, offset)
-			// }
-			f.Loads[i] = l
-		case types.LC_REEXPORT_DYLIB:
-			var hdr types.ReExportDylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_REEXPORT_DYLIB: %v", err)
-			}
-			l := new(ReExportDylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in dynamic library command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_LAZY_LOAD_DYLIB:
-			var hdr types.LazyLoadDylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LAZY_LOAD_DYLIB: %v", err)
-			}
-			l := new(LazyLoadDylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in load upwardl dylib command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_ENCRYPTION_INFO:
-			var ei types.EncryptionInfoCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &ei); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ENCRYPTION_INFO: %v", err)
-			}
-
-			l := new(EncryptionInfo)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = ei.Offset
-			l.Size = ei.Size
-			l.CryptID = ei.CryptID
-			f.Loads[i] = l
-		case types.LC_DYLD_INFO:
-			var info types.DyldInfoCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &info); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLD_INFO: %v", err)
-			}
-			l := new(DyldInfo)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.RebaseOff = info.RebaseOff
-			l.RebaseSize = info.RebaseSize
-			l.BindOff = info.BindOff
-			l.BindSize = info.BindSize
-			l.WeakBindOff = info.WeakBindOff
-			l.WeakBindSize = info.WeakBindSize
-			l.LazyBindOff = info.LazyBindOff
-			l.LazyBindSize = info.LazyBindSize
-			l.ExportOff = info.ExportOff
-			l.ExportSize = info.ExportSize
-			f.Loads[i] = l
-		case types.LC_DYLD_INFO_ONLY:
-			var info types.DyldInfoOnlyCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &info); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLD_INFO_ONLY: %v", err)
-			}
-			l := new(DyldInfoOnly)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.RebaseOff = info.RebaseOff
-			l.RebaseSize = info.RebaseSize
-			l.BindOff = info.BindOff
-			l.BindSize = info.BindSize
-			l.WeakBindOff = info.WeakBindOff
-			l.WeakBindSize = info.WeakBindSize
-			l.LazyBindOff = info.LazyBindOff
-			l.LazyBindSize = info.LazyBindSize
-			l.ExportOff = info.ExportOff
-			l.ExportSize = info.ExportSize
-			f.Loads[i] = l
-		case types.LC_LOAD_UPWARD_DYLIB:
-			var hdr types.UpwardDylibCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LOAD_UPWARD_DYLIB: %v", err)
-			}
-			l := new(UpwardDylib)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in load upwardl dylib command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			l.Time = hdr.Time
-			l.CurrentVersion = hdr.CurrentVersion.String()
-			l.CompatVersion = hdr.CompatVersion.String()
-			f.Loads[i] = l
-		case types.LC_VERSION_MIN_MACOSX:
-			var verMin types.VersionMinMacOSCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &verMin); err != nil {
-				return nil, fmt.Errorf("failed to read LC_VERSION_MIN_MACOSX: %v", err)
-			}
-			l := new(VersionMinMacOSX)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Version = verMin.Version.String()
-			l.Sdk = verMin.Sdk.String()
-			f.Loads[i] = l
-		case types.LC_VERSION_MIN_IPHONEOS:
-			var verMin types.VersionMinIPhoneOSCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &verMin); err != nil {
-				return nil, fmt.Errorf("failed to read LC_VERSION_MIN_IPHONEOS: %v", err)
-			}
-			l := new(VersionMiniPhoneOS)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Version = verMin.Version.String()
-			l.Sdk = verMin.Sdk.String()
-			f.Loads[i] = l
-		case types.LC_FUNCTION_STARTS:
-			var led types.LinkEditDataCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_FUNCTION_STARTS: %v", err)
-			}
-
-			l := new(FunctionStarts)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_DYLD_ENVIRONMENT:
-			var hdr types.DyldEnvironmentCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLD_ENVIRONMENT: %v", err)
-			}
-			l := new(DyldEnvironment)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.Name >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in dyld environment command", hdr.Name}
-			}
-			l.Name = cstring(cmddat[hdr.Name:])
-			f.Loads[i] = l
-		case types.LC_MAIN:
-			var hdr types.EntryPointCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_MAIN: %v", err)
-			}
-			l := new(EntryPoint)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.EntryOffset = hdr.Offset
-			l.StackSize = hdr.StackSize
-			f.Loads[i] = l
-		case types.LC_DATA_IN_CODE:
-			var led types.LinkEditDataCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DATA_IN_CODE: %v", err)
-			}
-			l := new(DataInCode)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			ldat := make([]byte, l.Size)
-			if _, err := f.cr.ReadAt(ldat, int64(l.Offset)); err != nil {
-				return nil, fmt.Errorf("failed to read DataInCode data at offset=%#x; %v", int64(led.Offset), err)
-			}
-			l.Entries = make([]types.DataInCodeEntry, len(ldat)/binary.Size(types.DataInCodeEntry{}))
-			if err := binary.Read(bytes.NewReader(ldat), bo, &l.Entries); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DATA_IN_CODE entries: %v", err)
-			}
-			f.Loads[i] = l
-		case types.LC_SOURCE_VERSION:
-			var sv types.SourceVersionCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &sv); err != nil {
-				return nil, fmt.Errorf("failed to read LC_SOURCE_VERSION: %v", err)
-			}
-			l := new(SourceVersion)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Version = sv.Version.String()
-			f.Loads[i] = l
-		case types.LC_DYLIB_CODE_SIGN_DRS:
-			var led types.LinkEditDataCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLIB_CODE_SIGN_DRS: %v", err)
-			}
-
-			l := new(DylibCodeSignDrs)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_ENCRYPTION_INFO_64:
-			var ei types.EncryptionInfo64Cmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &ei); err != nil {
-				return nil, fmt.Errorf("failed to read LC_ENCRYPTION_INFO_64: %v", err)
-			}
-			l := new(EncryptionInfo64)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = ei.Offset
-			l.Size = ei.Size
-			l.CryptID = ei.CryptID
-			f.Loads[i] = l
-		case types.LC_LINKER_OPTION:
-			var lo types.LinkerOptionCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &lo); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LINKER_OPTION: %v", err)
-			}
-			l := new(LinkerOption)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			for i := 0; i < int(lo.Count); i++ {
-				o, err := bufio.NewReader(b).ReadString('\x00')
-				if err != nil {
-					break // FIXME: should this error?
-				}
-				l.Options = append(l.Options, o)
-			}
-			f.Loads[i] = l
-		case types.LC_LINKER_OPTIMIZATION_HINT:
-			var led types.LinkEditDataCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_LINKER_OPTIMIZATION_HINT: %v", err)
-			}
-
-			l := new(LinkerOptimizationHint)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_VERSION_MIN_TVOS:
-			var verMin types.VersionMinMacOSCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &verMin); err != nil {
-				return nil, fmt.Errorf("failed to read LC_VERSION_MIN_TVOS: %v", err)
-			}
-			l := new(VersionMinTvOS)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Version = verMin.Version.String()
-			l.Sdk = verMin.Sdk.String()
-			f.Loads[i] = l
-		case types.LC_VERSION_MIN_WATCHOS:
-			var verMin types.VersionMinWatchOSCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &verMin); err != nil {
-				return nil, fmt.Errorf("failed to read LC_VERSION_MIN_WATCHOS: %v", err)
-			}
-			l := new(VersionMinWatchOS)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Version = verMin.Version.String()
-			l.Sdk = verMin.Sdk.String()
-			f.Loads[i] = l
-		case types.LC_NOTE:
-			var n types.NoteCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &n); err != nil {
-				return nil, fmt.Errorf("failed to read LC_NOTE: %v", err)
-			}
-			l := new(Note)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.DataOwner = string(n.DataOwner[:])
-			l.Offset = n.Offset
-			l.Size = n.Size
-			f.Loads[i] = l
-		case types.LC_BUILD_VERSION:
-			var build types.BuildVersionCmd
-			var buildTool types.BuildToolVersion
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &build); err != nil {
-				return nil, fmt.Errorf("failed to read LC_BUILD_VERSION: %v", err)
-			}
-			l := new(BuildVersion)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Platform = build.Platform.String()
-			l.Minos = build.Minos.String()
-			l.Sdk = build.Sdk.String()
-			l.NumTools = build.NumTools
-			// TODO: handle more than one tool case
-			if build.NumTools > 0 {
-				if err := binary.Read(b, bo, &buildTool); err != nil {
-					return nil, fmt.Errorf("failed to read LC_BUILD_VERSION buildTool: %v", err)
-				}
-				l.Tool = buildTool.Tool.String()
-				l.ToolVersion = buildTool.Version.String()
-			}
-			f.Loads[i] = l
-		case types.LC_DYLD_EXPORTS_TRIE:
-			var led types.LinkEditDataCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLD_EXPORTS_TRIE: %v", err)
-			}
-
-			l := new(DyldExportsTrie)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_DYLD_CHAINED_FIXUPS:
-			var led types.DyldChainedFixupsCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &led); err != nil {
-				return nil, fmt.Errorf("failed to read LC_DYLD_CHAINED_FIXUPS: %v", err)
-			}
-
-			l := new(DyldChainedFixups)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			l.Offset = led.Offset
-			l.Size = led.Size
-			f.Loads[i] = l
-		case types.LC_FILESET_ENTRY:
-			var hdr types.FilesetEntryCmd
-			b := bytes.NewReader(cmddat)
-			if err := binary.Read(b, bo, &hdr); err != nil {
-				return nil, fmt.Errorf("failed to read LC_FILESET_ENTRY: %v", err)
-			}
-			l := new(FilesetEntry)
-			l.LoadBytes = cmddat
-			l.LoadCmd = cmd
-			l.Len = siz
-			if hdr.EntryID >= uint32(len(cmddat)) {
-				return nil, &FormatError{offset, "invalid name in load fileset entry command", hdr.EntryID}
-			}
-			l.EntryID = cstring(cmddat[hdr.EntryID:])
-			l.Offset = hdr.Offset
-			l.Addr = hdr.Addr
 			f.Loads[i] = l
+			break
+		}
+		f.report(Diagnostic{
+			Kind:     DiagUnknownLoadCmd,
+			Severity: DiagWarning,
+			Offset:   offset - int64(siz),
+			Cmd:      cmd,
+			Message:  fmt.Sprintf("unknown load command %s", cmd),
+		})
+		f.Loads[i] = LoadCmdBytes{types.LoadCmd(cmd), LoadBytes(cmddat)}
+	case types.LC_SEGMENT:
+		var seg32 types.Segment32
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &seg32); err != nil {
+			return fmt.Errorf("failed to read LC_SEGMENT: %v", err)
+		}
+		s = new(Segment)
+		s.LoadBytes = cmddat
+		s.LoadCmd = cmd
+		s.Len = siz
+		s.Name = cstring(seg32.Name[0:])
+		s.Addr = uint64(seg32.Addr)
+		s.Memsz = uint64(seg32.Memsz)
+		s.Offset = uint64(seg32.Offset)
+		s.Filesz = uint64(seg32.Filesz)
+		s.Maxprot = seg32.Maxprot
+		s.Prot = seg32.Prot
+		s.Nsect = seg32.Nsect
+		s.Flag = seg32.Flag
+		s.Firstsect = uint32(len(f.Sections))
+		f.Loads[i] = s
+		for i := 0; i < int(s.Nsect); i++ {
+			var sh32 types.Section32
+			if err := binary.Read(b, bo, &sh32); err != nil {
+				f.Sections = f.Sections[:s.Firstsect] // drop the sections of the partial segment
+				return fmt.Errorf("failed to read Section32: %v", err)
+			}
+			sh := new(Section)
+			sh.Type = 32
+			sh.Name = cstring(sh32.Name[0:])
+			sh.Seg = cstring(sh32.Seg[0:])
+			sh.Addr = uint64(sh32.Addr)
+			sh.Size = uint64(sh32.Size)
+			sh.Offset = sh32.Offset
+			sh.Align = sh32.Align
+			sh.Reloff = sh32.Reloff
+			sh.Nreloc = sh32.Nreloc
+			sh.Flags = sh32.Flags
+			sh.Reserved1 = sh32.Reserve1
+			sh.Reserved2 = sh32.Reserve2
+			if err := f.pushSection(sh, f.cr); err != nil {
+				f.Sections = f.Sections[:s.Firstsect] // drop the sections of the partial segment
+				return fmt.Errorf("failed to pushSection32: %v", err)
+			}
+		}
+	case types.LC_SEGMENT_64:
+		var seg64 types.Segment64
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &seg64); err != nil {
+			return fmt.Errorf("failed to read LC_SEGMENT_64: %v", err)
+		}
+		s = new(Segment)
+		s.LoadBytes = cmddat
+		s.LoadCmd = cmd
+		s.Len = siz
+		s.Name = cstring(seg64.Name[0:])
+		s.Addr = seg64.Addr
+		s.Memsz = seg64.Memsz
+		s.Offset = seg64.Offset
+		s.Filesz = seg64.Filesz
+		s.Maxprot = seg64.Maxprot
+		s.Prot = seg64.Prot
+		s.Nsect = seg64.Nsect
+		s.Flag = seg64.Flag
+		s.Firstsect = uint32(len(f.Sections))
+		f.Loads[i] = s
+		for i := 0; i < int(s.Nsect); i++ {
+			var sh64 types.Section64
+			if err := binary.Read(b, bo, &sh64); err != nil {
+				f.Sections = f.Sections[:s.Firstsect] // drop the sections of the partial segment
+				return fmt.Errorf("failed to read Section64: %v", err)
+			}
+			sh := new(Section)
+			sh.Type = 64
+			sh.Name = cstring(sh64.Name[0:])
+			sh.Seg = cstring(sh64.Seg[0:])
+			sh.Addr = sh64.Addr
+			sh.Size = sh64.Size
+			sh.Offset = sh64.Offset
+			sh.Align = sh64.Align
+			sh.Reloff = sh64.Reloff
+			sh.Nreloc = sh64.Nreloc
+			sh.Flags = sh64.Flags
+			sh.Reserved1 = sh64.Reserve1
+			sh.Reserved2 = sh64.Reserve2
+			sh.Reserved3 = sh64.Reserve3
+			if err := f.pushSection(sh, f.cr); err != nil {
+				f.Sections = f.Sections[:s.Firstsect] // drop the sections of the partial segment
+				return fmt.Errorf("failed to pushSection64: %v", err)
+			}
+		}
+	case types.LC_SYMTAB:
+		var hdr types.SymtabCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_SYMTAB: %v", err)
+		}
+
+		strtab := make([]byte, hdr.Strsize)
+		if _, err := f.cr.ReadAt(strtab, int64(hdr.Stroff)); err != nil {
+			return fmt.Errorf("failed to read data at Stroff=%#x; %v", int64(hdr.Stroff), err)
+		}
+
+		var symsz int
+		if f.Magic == types.Magic64 {
+			symsz = 16
+		} else {
+			symsz = 12
 		}
-		if s != nil {
-			// s.sr = io.NewSectionReader(r, int64(s.Offset), int64(s.Filesz))
-			s.ReaderAt = f.sr
+		symdat := make([]byte, int(hdr.Nsyms)*symsz)
+		if _, err := f.cr.ReadAt(symdat, int64(hdr.Symoff)); err != nil {
+			return fmt.Errorf("failed to read data at Symoff=%#x; %v", int64(hdr.Symoff), err)
+		}
+
+		st, err := f.parseSymtab(symdat, strtab, cmddat, &hdr, offset)
+		if err != nil {
+			return fmt.Errorf("failed to read parseSymtab: %v", err)
+		}
+		st.LoadBytes = cmddat
+		st.LoadCmd = cmd
+		st.Len = siz
+		f.Loads[i] = st
+		f.Symtab = st
+	case types.LC_SYMSEG:
+		var led types.SymsegCommand
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_SYMSEG: %v", err)
+		}
+
+		l := new(SymSeg)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_THREAD:
+		if len(cmddat) < 12 {
+			return &FormatError{offset, "LC_THREAD command too small", len(cmddat)}
+		}
+		l := new(Thread)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Type = bo.Uint32(cmddat[8:])
+		l.Data = make([]uint32, (len(cmddat)-12)/4)
+		if err := binary.Read(bytes.NewReader(cmddat[12:]), bo, l.Data); err != nil {
+			return fmt.Errorf("failed to read LC_THREAD data: %v", err)
+		}
+		states, err := parseThreadStates(f.CPU, bo, cmddat[8:])
+		if err != nil {
+			return fmt.Errorf("failed to read LC_THREAD states: %v", err)
+		}
+		l.States = states
+		f.Loads[i] = l
+	case types.LC_UNIXTHREAD:
+		var ut types.UnixThreadCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &ut); err != nil {
+			return fmt.Errorf("failed to read LC_UNIXTHREAD: %v", err)
+		}
+		l := new(UnixThread)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Flavor = ut.Flavor
+		l.Count = ut.Count
+		states, err := parseThreadStates(f.CPU, bo, cmddat[8:])
+		if err != nil {
+			return fmt.Errorf("failed to read LC_UNIXTHREAD states: %v", err)
+		}
+		l.States = states
+		l.EntryPoint, _ = l.States.PC()
+		f.Loads[i] = l
+	case types.LC_LOADFVMLIB:
+		var hdr types.LoadFvmLibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LOADFVMLIB: %v", err)
+		}
+		l := new(LoadFvmlib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in LC_LOADFVMLIB command", hdr.Name}
+		}
+		l.MinorVersion = types.Version(hdr.MinorVersion)
+		l.HeaderAddr = hdr.HeaderAddr
+		f.Loads[i] = l
+	case types.LC_IDFVMLIB:
+		var hdr types.IDFvmLibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_IDFVMLIB: %v", err)
+		}
+		l := new(IDFvmlib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in LC_IDFVMLIB command", hdr.Name}
+		}
+		l.MinorVersion = types.Version(hdr.MinorVersion)
+		l.HeaderAddr = hdr.HeaderAddr
+		f.Loads[i] = l
+	case types.LC_IDENT:
+		var hdr types.IdentCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_IDENT: %v", err)
+		}
+		l := new(Ident)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Length = hdr.Len
+		f.Loads[i] = l
+	case types.LC_FVMFILE:
+		var hdr types.FvmFileCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_FVMFILE: %v", err)
+		}
+		l := new(FvmFile)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in LC_FVMFILE command", hdr.Name}
+		}
+		l.HeaderAddr = hdr.HeaderAddr
+		f.Loads[i] = l
+	case types.LC_PREPAGE:
+		var hdr types.PrePageCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_PREPAGE: %v", err)
+		}
+		l := new(Prepage)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		f.Loads[i] = l
+	case types.LC_DYSYMTAB:
+		var hdr types.DysymtabCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_DYSYMTAB: %v", err)
+		}
+		if f.Symtab == nil {
+			return &FormatError{offset, "dynamic symbol table seen before any ordinary symbol table", nil}
+		} else if hdr.Iundefsym > uint32(len(f.Symtab.Syms)) {
+			return &FormatError{offset, fmt.Sprintf(
+				"undefined symbols index in dynamic symbol table command is greater than symbol table length (%d > %d)",
+				hdr.Iundefsym, len(f.Symtab.Syms)), nil}
+		} else if hdr.Iundefsym+hdr.Nundefsym > uint32(len(f.Symtab.Syms)) {
+			return &FormatError{offset, fmt.Sprintf(
+				"number of undefined symbols after index in dynamic symbol table command is greater than symbol table length (%d > %d)",
+				hdr.Iundefsym+hdr.Nundefsym, len(f.Symtab.Syms)), nil}
+		}
+		dat := make([]byte, hdr.Nindirectsyms*4)
+		if _, err := f.cr.ReadAt(dat, int64(hdr.Indirectsymoff)); err != nil {
+			return fmt.Errorf("failed to read data at Indirectsymoff=%#x; %v", int64(hdr.Indirectsymoff), err)
+		}
+		x := make([]uint32, hdr.Nindirectsyms)
+		if err := binary.Read(bytes.NewReader(dat), bo, x); err != nil {
+			return fmt.Errorf("failed to read Nindirectsyms: %v", err)
+		}
+		st := new(Dysymtab)
+		st.LoadBytes = cmddat
+		st.LoadCmd = cmd
+		st.Len = siz
+		st.DysymtabCmd = hdr
+		st.IndirectSyms = x
+		f.Loads[i] = st
+		f.Dysymtab = st
+	case types.LC_LOAD_DYLIB:
+		var hdr types.DylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LOAD_DYLIB: %v", err)
+		}
+		l := new(Dylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in dynamic library command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_ID_DYLIB:
+		var hdr types.DylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_ID_DYLIB: %v", err)
+		}
+		l := new(DylibID)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in dynamic library ident command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_LOAD_DYLINKER:
+		var hdr types.DylinkerCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LOAD_DYLINKER: %v", err)
+		}
+		l := new(LoadDylinker)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in load dylinker command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		f.Loads[i] = l
+	case types.LC_ID_DYLINKER:
+		var hdr types.DylinkerIDCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_ID_DYLINKER: %v", err)
+		}
+		l := new(DylinkerID)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in load dylinker command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		f.Loads[i] = l
+	case types.LC_PREBOUND_DYLIB:
+		var hdr types.PreboundDylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_PREBOUND_DYLIB: %v", err)
+		}
+		l := new(PreboundDylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in LC_PREBOUND_DYLIB command", hdr.Name}
+		}
+		l.NumModules = hdr.NumModules
+		l.Name = cstring(cmddat[hdr.Name:])
+		if hdr.LinkedModules >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid linked modules in LC_PREBOUND_DYLIB command", hdr.Name}
+		}
+		l.LinkedModules = cstring(cmddat[hdr.LinkedModules:])
+		f.Loads[i] = l
+	case types.LC_ROUTINES:
+		var rt types.RoutinesCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &rt); err != nil {
+			return fmt.Errorf("failed to read LC_ROUTINES: %v", err)
+		}
+		l := new(Routines)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.InitAddress = rt.InitAddress
+		l.InitModule = rt.InitModule
+		f.Loads[i] = l
+	case types.LC_SUB_FRAMEWORK:
+		var sf types.SubFrameworkCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &sf); err != nil {
+			return fmt.Errorf("failed to read LC_SUB_FRAMEWORK: %v", err)
+		}
+		l := new(SubFramework)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if sf.Framework >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid framework in sub-framework command", sf.Framework}
+		}
+		l.Framework = cstring(cmddat[sf.Framework:])
+		f.Loads[i] = l
+	case types.LC_SUB_UMBRELLA:
+		var su types.SubUmbrellaCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &su); err != nil {
+			return fmt.Errorf("failed to read LC_SUB_UMBRELLA: %v", err)
+		}
+		l := new(SubUmbrella)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if su.Umbrella >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid framework in sub-umbrella command", su.Umbrella}
+		}
+		l.Umbrella = cstring(cmddat[su.Umbrella:])
+		f.Loads[i] = l
+	case types.LC_SUB_CLIENT:
+		var sc types.SubClientCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &sc); err != nil {
+			return fmt.Errorf("failed to read LC_SUB_CLIENT: %v", err)
+		}
+		l := new(SubClient)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if sc.Client >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid path in sub client command", sc.Client}
+		}
+		l.Name = cstring(cmddat[sc.Client:])
+		f.Loads[i] = l
+	case types.LC_SUB_LIBRARY:
+		var s types.SubLibraryCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &s); err != nil {
+			return fmt.Errorf("failed to read LC_SUB_LIBRARY: %v", err)
+		}
+		l := new(SubLibrary)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if s.Library >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid framework in sub-library command", s.Library}
+		}
+		l.Library = cstring(cmddat[s.Library:])
+		f.Loads[i] = l
+	case types.LC_TWOLEVEL_HINTS:
+		var t types.TwolevelHintsCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &t); err != nil {
+			return fmt.Errorf("failed to read LC_TWOLEVEL_HINTS: %v", err)
+		}
+		l := new(TwolevelHints)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = t.Offset
+		l.Hints = make([]types.TwolevelHint, t.NumHints)
+		if err := binary.Read(b, bo, &l.Hints); err != nil {
+			return fmt.Errorf("failed to read hints data: %v", err)
+		}
+		f.Loads[i] = l
+
+	case types.LC_PREBIND_CKSUM:
+		var p types.PrebindCksumCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &p); err != nil {
+			return fmt.Errorf("failed to read LC_PREBIND_CKSUM: %v", err)
+		}
+		l := new(PrebindCksum)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.CheckSum = p.CheckSum
+		f.Loads[i] = l
+	case types.LC_LOAD_WEAK_DYLIB:
+		var hdr types.DylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LOAD_WEAK_DYLIB: %v", err)
+		}
+		l := new(WeakDylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in weak dynamic library command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_ROUTINES_64:
+		var r64 types.Routines64Cmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &r64); err != nil {
+			return fmt.Errorf("failed to read LC_ROUTINES_64: %v", err)
+		}
+		l := new(Routines64)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.InitAddress = r64.InitAddress
+		l.InitModule = r64.InitModule
+		f.Loads[i] = l
+	case types.LC_UUID:
+		var u types.UUIDCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &u); err != nil {
+			return fmt.Errorf("failed to read LC_UUID: %v", err)
+		}
+		l := new(UUID)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.UUID = u.UUID
+		l.ID = u.UUID.String()
+		f.Loads[i] = l
+	case types.LC_RPATH:
+		var hdr types.RpathCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_RPATH: %v", err)
+		}
+		l := new(Rpath)
+		if hdr.Path >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid path in rpath command", hdr.Path}
+		}
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Path >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid path in rpath command", hdr.Path}
+		}
+		l.Path = cstring(cmddat[hdr.Path:])
+		f.Loads[i] = l
+	case types.LC_CODE_SIGNATURE:
+		var hdr types.CodeSignatureCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_CODE_SIGNATURE: %v", err)
+		}
+
+		l := new(CodeSignature)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = hdr.Offset
+		l.Size = hdr.Size
+		csdat := make([]byte, hdr.Size)
+		if _, err := f.cr.ReadAt(csdat, int64(hdr.Offset)); err != nil {
+			return fmt.Errorf("failed to read CS data at offset=%#x; %v", int64(hdr.Offset), err)
+		}
+		cs, err := codesign.ParseCodeSignature(csdat)
+		if err != nil {
+			return fmt.Errorf("failed to ParseCodeSignature: %v", err)
+		}
+		l.CodeSignature = *cs
+		for _, w := range cs.Warnings {
+			f.report(Diagnostic{
+				Kind:     DiagCodeSignature,
+				Severity: DiagWarning,
+				Offset:   int64(hdr.Offset),
+				Cmd:      cmd,
+				Message:  w,
+			})
+		}
+		f.Loads[i] = l
+	case types.LC_SEGMENT_SPLIT_INFO:
+		var hdr types.SegmentSplitInfoCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_SEGMENT_SPLIT_INFO: %v", err)
+		}
+
+		l := new(SplitInfo)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = hdr.Offset
+		l.Size = hdr.Size
+		ldat := make([]byte, l.Size)
+		if _, err := f.cr.ReadAt(ldat, int64(l.Offset)); err != nil {
+			return fmt.Errorf("failed to read SplitInfo data at offset=%#x; %v", int64(hdr.Offset), err)
+		}
+		fsr := bytes.NewReader(ldat)
+		if err := binary.Read(fsr, bo, &l.Version); err != nil {
+			return fmt.Errorf("failed to read LC_SEGMENT_SPLIT_INFO Version: %v", err)
+		}
+		var err error
+		if l.Version == types.DYLD_CACHE_ADJ_V2_FORMAT {
+			l.Entries, err = parseSplitInfoV2(fsr)
+		} else {
+			fsr.Seek(0, io.SeekStart)
+			l.Entries, err = parseSplitInfoV1(fsr)
+			for _, e := range l.Entries {
+				l.Offsets = append(l.Offsets, e.FromOffset)
+			}
 		}
+		if err != nil {
+			return fmt.Errorf("failed to parse LC_SEGMENT_SPLIT_INFO data: %v", err)
+		}
+		f.Loads[i] = l
+	case types.LC_REEXPORT_DYLIB:
+		var hdr types.ReExportDylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_REEXPORT_DYLIB: %v", err)
+		}
+		l := new(ReExportDylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in dynamic library command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_LAZY_LOAD_DYLIB:
+		var hdr types.LazyLoadDylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LAZY_LOAD_DYLIB: %v", err)
+		}
+		l := new(LazyLoadDylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in load upwardl dylib command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_ENCRYPTION_INFO:
+		var ei types.EncryptionInfoCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &ei); err != nil {
+			return fmt.Errorf("failed to read LC_ENCRYPTION_INFO: %v", err)
+		}
+
+		l := new(EncryptionInfo)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = ei.Offset
+		l.Size = ei.Size
+		l.CryptID = ei.CryptID
+		f.Loads[i] = l
+	case types.LC_DYLD_INFO:
+		var info types.DyldInfoCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &info); err != nil {
+			return fmt.Errorf("failed to read LC_DYLD_INFO: %v", err)
+		}
+		l := new(DyldInfo)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.RebaseOff = info.RebaseOff
+		l.RebaseSize = info.RebaseSize
+		l.BindOff = info.BindOff
+		l.BindSize = info.BindSize
+		l.WeakBindOff = info.WeakBindOff
+		l.WeakBindSize = info.WeakBindSize
+		l.LazyBindOff = info.LazyBindOff
+		l.LazyBindSize = info.LazyBindSize
+		l.ExportOff = info.ExportOff
+		l.ExportSize = info.ExportSize
+		f.Loads[i] = l
+	case types.LC_DYLD_INFO_ONLY:
+		var info types.DyldInfoOnlyCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &info); err != nil {
+			return fmt.Errorf("failed to read LC_DYLD_INFO_ONLY: %v", err)
+		}
+		l := new(DyldInfoOnly)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.RebaseOff = info.RebaseOff
+		l.RebaseSize = info.RebaseSize
+		l.BindOff = info.BindOff
+		l.BindSize = info.BindSize
+		l.WeakBindOff = info.WeakBindOff
+		l.WeakBindSize = info.WeakBindSize
+		l.LazyBindOff = info.LazyBindOff
+		l.LazyBindSize = info.LazyBindSize
+		l.ExportOff = info.ExportOff
+		l.ExportSize = info.ExportSize
+		f.Loads[i] = l
+	case types.LC_LOAD_UPWARD_DYLIB:
+		var hdr types.UpwardDylibCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_LOAD_UPWARD_DYLIB: %v", err)
+		}
+		l := new(UpwardDylib)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in load upwardl dylib command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		l.Time = hdr.Time
+		l.CurrentVersion = hdr.CurrentVersion.String()
+		l.CompatVersion = hdr.CompatVersion.String()
+		f.Loads[i] = l
+	case types.LC_VERSION_MIN_MACOSX:
+		var verMin types.VersionMinMacOSCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &verMin); err != nil {
+			return fmt.Errorf("failed to read LC_VERSION_MIN_MACOSX: %v", err)
+		}
+		l := new(VersionMinMacOSX)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Version = verMin.Version.String()
+		l.Sdk = verMin.Sdk.String()
+		f.Loads[i] = l
+	case types.LC_VERSION_MIN_IPHONEOS:
+		var verMin types.VersionMinIPhoneOSCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &verMin); err != nil {
+			return fmt.Errorf("failed to read LC_VERSION_MIN_IPHONEOS: %v", err)
+		}
+		l := new(VersionMiniPhoneOS)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Version = verMin.Version.String()
+		l.Sdk = verMin.Sdk.String()
+		f.Loads[i] = l
+	case types.LC_FUNCTION_STARTS:
+		var led types.LinkEditDataCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_FUNCTION_STARTS: %v", err)
+		}
+
+		l := new(FunctionStarts)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_DYLD_ENVIRONMENT:
+		var hdr types.DyldEnvironmentCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_DYLD_ENVIRONMENT: %v", err)
+		}
+		l := new(DyldEnvironment)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Name >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in dyld environment command", hdr.Name}
+		}
+		l.Name = cstring(cmddat[hdr.Name:])
+		f.Loads[i] = l
+	case types.LC_MAIN:
+		var hdr types.EntryPointCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_MAIN: %v", err)
+		}
+		l := new(EntryPoint)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.EntryOffset = hdr.Offset
+		l.StackSize = hdr.StackSize
+		f.Loads[i] = l
+	case types.LC_DATA_IN_CODE:
+		var led types.LinkEditDataCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_DATA_IN_CODE: %v", err)
+		}
+		l := new(DataInCode)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		ldat := make([]byte, l.Size)
+		if _, err := f.cr.ReadAt(ldat, int64(l.Offset)); err != nil {
+			return fmt.Errorf("failed to read DataInCode data at offset=%#x; %v", int64(led.Offset), err)
+		}
+		l.Entries = make([]types.DataInCodeEntry, len(ldat)/binary.Size(types.DataInCodeEntry{}))
+		if err := binary.Read(bytes.NewReader(ldat), bo, &l.Entries); err != nil {
+			return fmt.Errorf("failed to read LC_DATA_IN_CODE entries: %v", err)
+		}
+		f.Loads[i] = l
+	case types.LC_SOURCE_VERSION:
+		var sv types.SourceVersionCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &sv); err != nil {
+			return fmt.Errorf("failed to read LC_SOURCE_VERSION: %v", err)
+		}
+		l := new(SourceVersion)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Version = sv.Version.String()
+		f.Loads[i] = l
+	case types.LC_DYLIB_CODE_SIGN_DRS:
+		var led types.LinkEditDataCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_DYLIB_CODE_SIGN_DRS: %v", err)
+		}
+
+		l := new(DylibCodeSignDrs)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_ENCRYPTION_INFO_64:
+		var ei types.EncryptionInfo64Cmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &ei); err != nil {
+			return fmt.Errorf("failed to read LC_ENCRYPTION_INFO_64: %v", err)
+		}
+		l := new(EncryptionInfo64)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = ei.Offset
+		l.Size = ei.Size
+		l.CryptID = ei.CryptID
+		f.Loads[i] = l
+	case types.LC_LINKER_OPTION:
+		var lo types.LinkerOptionCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &lo); err != nil {
+			return fmt.Errorf("failed to read LC_LINKER_OPTION: %v", err)
+		}
+		l := new(LinkerOption)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		for i := 0; i < int(lo.Count); i++ {
+			o, err := bufio.NewReader(b).ReadString('\x00')
+			if err != nil {
+				break // FIXME: should this error?
+			}
+			l.Options = append(l.Options, o)
+		}
+		f.Loads[i] = l
+	case types.LC_LINKER_OPTIMIZATION_HINT:
+		var led types.LinkEditDataCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_LINKER_OPTIMIZATION_HINT: %v", err)
+		}
+
+		l := new(LinkerOptimizationHint)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		// the hints are only informative so bad ones do not fail the parse
+		ldat := make([]byte, l.Size)
+		if _, err := f.cr.ReadAt(ldat, int64(l.Offset)); err != nil {
+			f.warn(DiagLinkerOptimizationHint, int64(l.Offset), "failed to read LC_LINKER_OPTIMIZATION_HINT data: %v", err)
+		} else if hints, err := parseLinkerOptimizationHints(ldat); err != nil {
+			f.warn(DiagLinkerOptimizationHint, int64(l.Offset), "failed to parse LC_LINKER_OPTIMIZATION_HINT data: %v", err)
+		} else {
+			l.Hints = hints
+		}
+		f.Loads[i] = l
+	case types.LC_VERSION_MIN_TVOS:
+		var verMin types.VersionMinMacOSCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &verMin); err != nil {
+			return fmt.Errorf("failed to read LC_VERSION_MIN_TVOS: %v", err)
+		}
+		l := new(VersionMinTvOS)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Version = verMin.Version.String()
+		l.Sdk = verMin.Sdk.String()
+		f.Loads[i] = l
+	case types.LC_VERSION_MIN_WATCHOS:
+		var verMin types.VersionMinWatchOSCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &verMin); err != nil {
+			return fmt.Errorf("failed to read LC_VERSION_MIN_WATCHOS: %v", err)
+		}
+		l := new(VersionMinWatchOS)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Version = verMin.Version.String()
+		l.Sdk = verMin.Sdk.String()
+		f.Loads[i] = l
+	case types.LC_NOTE:
+		var n types.NoteCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &n); err != nil {
+			return fmt.Errorf("failed to read LC_NOTE: %v", err)
+		}
+		l := new(Note)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.DataOwner = strings.TrimRight(string(n.DataOwner[:]), "\x00")
+		l.Offset = n.Offset
+		l.Size = n.Size
+		f.Loads[i] = l
+	case types.LC_BUILD_VERSION:
+		var build types.BuildVersionCmd
+		var buildTool types.BuildToolVersion
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &build); err != nil {
+			return fmt.Errorf("failed to read LC_BUILD_VERSION: %v", err)
+		}
+		l := new(BuildVersion)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Platform = build.Platform.String()
+		l.Minos = build.Minos.String()
+		l.Sdk = build.Sdk.String()
+		l.NumTools = build.NumTools
+		// TODO: handle more than one tool case
+		if build.NumTools > 0 {
+			if err := binary.Read(b, bo, &buildTool); err != nil {
+				return fmt.Errorf("failed to read LC_BUILD_VERSION buildTool: %v", err)
+			}
+			l.Tool = buildTool.Tool.String()
+			l.ToolVersion = buildTool.Version.String()
+		}
+		f.Loads[i] = l
+	case types.LC_DYLD_EXPORTS_TRIE:
+		var led types.LinkEditDataCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_DYLD_EXPORTS_TRIE: %v", err)
+		}
+
+		l := new(DyldExportsTrie)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_DYLD_CHAINED_FIXUPS:
+		var led types.DyldChainedFixupsCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_DYLD_CHAINED_FIXUPS: %v", err)
+		}
+
+		l := new(DyldChainedFixups)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_FILESET_ENTRY:
+		var hdr types.FilesetEntryCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_FILESET_ENTRY: %v", err)
+		}
+		l := new(FilesetEntry)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.EntryID >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid name in load fileset entry command", hdr.EntryID}
+		}
+		l.EntryID = cstring(cmddat[hdr.EntryID:])
+		l.Offset = hdr.Offset
+		l.Addr = hdr.Addr
+		f.Loads[i] = l
+	case types.LC_ATOM_INFO:
+		var led types.AtomInfoCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_ATOM_INFO: %v", err)
+		}
+		l := new(AtomInfo)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_FUNCTION_VARIANTS:
+		var led types.FunctionVariantsCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_FUNCTION_VARIANTS: %v", err)
+		}
+		l := new(FunctionVariants)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_FUNCTION_VARIANT_FIXUPS:
+		var led types.FunctionVariantFixupsCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &led); err != nil {
+			return fmt.Errorf("failed to read LC_FUNCTION_VARIANT_FIXUPS: %v", err)
+		}
+		l := new(FunctionVariantFixups)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		l.Offset = led.Offset
+		l.Size = led.Size
+		f.Loads[i] = l
+	case types.LC_TARGET_TRIPLE:
+		var hdr types.TargetTripleCmd
+		b := bytes.NewReader(cmddat)
+		if err := binary.Read(b, bo, &hdr); err != nil {
+			return fmt.Errorf("failed to read LC_TARGET_TRIPLE: %v", err)
+		}
+		l := new(TargetTriple)
+		l.LoadBytes = cmddat
+		l.LoadCmd = cmd
+		l.Len = siz
+		if hdr.Triple >= uint32(len(cmddat)) {
+			return &FormatError{offset, "invalid triple in target triple command", hdr.Triple}
+		}
+		l.TargetTripleCmd.Triple = hdr.Triple
+		l.Triple = cstring(cmddat[hdr.Triple:])
+		f.Loads[i] = l
+	}
+	if s != nil {
+		// s.sr = io.NewSectionReader(r, int64(s.Offset), int64(s.Filesz))
+		s.ReaderAt = f.sr
 	}
-	return f, nil
+	return nil
 }
 
 func (f *File) parseSymtab(symdat, strtab, cmddat []byte, hdr *types.SymtabCmd, offset int64) (*Symtab, error) {
@@ -1419,6 +1624,254 @@ func (f *File) parseSymtab(symdat, strtab, cmddat []byte, hdr *types.SymtabCmd,
 	return st, nil
 }
 
+// parseLinkerOptimizationHints parses the uleb128 stream of <kind> <count> <address>+
+// hints; the stream is zero padded to pointer alignment.
+func parseLinkerOptimizationHints(dat []byte) ([]LOH, error) {
+	var hints []LOH
+	r := bytes.NewReader(dat)
+	for r.Len() > 0 {
+		kind, err := trie.ReadUleb128(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hint kind: %v", err)
+		}
+		if kind == 0 {
+			break
+		}
+		count, err := trie.ReadUleb128(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s hint argument count: %v", types.LOHKind(kind), err)
+		}
+		if count > uint64(r.Len()) {
+			return nil, fmt.Errorf("invalid %s hint argument count %d", types.LOHKind(kind), count)
+		}
+		loh := LOH{Kind: types.LOHKind(kind), Addresses: make([]uint64, count)}
+		for i := range loh.Addresses {
+			loh.Addresses[i], err = trie.ReadUleb128(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s hint address: %v", loh.Kind, err)
+			}
+		}
+		hints = append(hints, loh)
+	}
+	return hints, nil
+}
+
+// parseSplitInfoV1 parses the original split seg info format:
+// a list of <kind> <uleb128 delta>+ 0 runs terminated by a zero kind.
+// The deltas of each run accumulate from the start of __TEXT.
+func parseSplitInfoV1(r *bytes.Reader) ([]SplitInfoEntry, error) {
+	var entries []SplitInfoEntry
+	for {
+		kind, err := r.ReadByte()
+		if err == io.EOF || kind == 0 {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var offset uint64
+		for {
+			delta, err := trie.ReadUleb128(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read v1 kind %#x offset: %v", kind, err)
+			}
+			if delta == 0 {
+				break
+			}
+			offset += delta
+			entries = append(entries, SplitInfoEntry{
+				Kind:       uint64(kind),
+				FromOffset: offset,
+				v1:         true,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// parseSplitInfoV2 parses the DYLD_CACHE_ADJ_V2_FORMAT split seg info format:
+//
+//	Whole         :== <count> FromToSection+
+//	FromToSection :== <from-sect-index> <to-sect-index> <count> ToOffset+
+//	ToOffset      :== <to-sect-offset-delta> <count> FromOffset+
+//	FromOffset    :== <kind> <count> <from-sect-offset-delta>+
+func parseSplitInfoV2(r *bytes.Reader) ([]SplitInfoEntry, error) {
+	var entries []SplitInfoEntry
+
+	sectionCount, err := readSplitInfoCount(r, "section pair")
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < sectionCount; i++ {
+		fromSection, err := trie.ReadUleb128(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read v2 from section of pair %d: %v", i, err)
+		}
+		toSection, err := trie.ReadUleb128(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read v2 to section of pair %d: %v", i, err)
+		}
+		toOffsetCount, err := readSplitInfoCount(r, "to offset")
+		if err != nil {
+			return nil, err
+		}
+		var toOffset uint64
+		for j := uint64(0); j < toOffsetCount; j++ {
+			delta, err := trie.ReadUleb128(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read v2 to offset delta: %v", err)
+			}
+			toOffset += delta
+			fromOffsetCount, err := readSplitInfoCount(r, "from offset")
+			if err != nil {
+				return nil, err
+			}
+			for k := uint64(0); k < fromOffsetCount; k++ {
+				kind, err := trie.ReadUleb128(r)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read v2 kind: %v", err)
+				}
+				deltaCount, err := readSplitInfoCount(r, "from offset delta")
+				if err != nil {
+					return nil, err
+				}
+				var fromOffset uint64
+				for l := uint64(0); l < deltaCount; l++ {
+					delta, err := trie.ReadUleb128(r)
+					if err != nil {
+						return nil, fmt.Errorf("failed to read v2 from offset delta: %v", err)
+					}
+					fromOffset += delta
+					entries = append(entries, SplitInfoEntry{
+						Kind:        kind,
+						FromSection: fromSection,
+						ToSection:   toSection,
+						FromOffset:  fromOffset,
+						ToOffset:    toOffset,
+					})
+				}
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// readSplitInfoCount reads a v2 count, every counted item takes at least one
+// byte so counts larger than the remaining data are rejected.
+func readSplitInfoCount(r *bytes.Reader, what string) (uint64, error) {
+	count, err := trie.ReadUleb128(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read v2 %s count: %v", what, err)
+	}
+	if count > uint64(r.Len()) {
+		return 0, fmt.Errorf("v2 %s count %d exceeds the remaining %d bytes", what, count, r.Len())
+	}
+	return count, nil
+}
+
+// parseThreadStates reads the flavor/count/state tuples that follow the
+// cmd and cmdsize of a LC_THREAD or LC_UNIXTHREAD command.
+func parseThreadStates(cpu types.CPU, bo binary.ByteOrder, dat []byte) (ThreadStates, error) {
+	var states ThreadStates
+	r := bytes.NewReader(dat)
+	for r.Len() > 0 {
+		var hdr types.ThreadStateHdr
+		if err := binary.Read(r, bo, &hdr); err != nil {
+			return nil, fmt.Errorf("failed to read thread state header: %v", err)
+		}
+		if uint64(hdr.Count)*4 > uint64(r.Len()) {
+			return nil, fmt.Errorf("thread state %s count %d extends past end of command", hdr.Flavor.String(cpu), hdr.Count)
+		}
+		ts := ThreadState{
+			Flavor: hdr.Flavor,
+			Count:  hdr.Count,
+			Data:   make([]byte, hdr.Count*4),
+			cpu:    cpu,
+		}
+		if _, err := r.Read(ts.Data); err != nil {
+			return nil, fmt.Errorf("failed to read thread state %s: %v", hdr.Flavor.String(cpu), err)
+		}
+		regs, err := decodeThreadState(cpu, bo, hdr.Flavor, ts.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode thread state %s: %v", hdr.Flavor.String(cpu), err)
+		}
+		ts.Regs = regs
+		states = append(states, ts)
+	}
+	return states, nil
+}
+
+// decodeThreadState returns the register struct for a thread state flavor,
+// or nil if the flavor is not supported or the state is too short.
+func decodeThreadState(cpu types.CPU, bo binary.ByteOrder, flavor types.ThreadFlavor, dat []byte) (interface{}, error) {
+	var regs interface{}
+	switch cpu {
+	case types.CPU386, types.CPUAmd64:
+		switch flavor {
+		case types.X86_THREAD_STATE32:
+			regs = new(Regs386)
+		case types.X86_FLOAT_STATE32:
+			regs = new(FloatState386)
+		case types.X86_EXCEPTION_STATE32:
+			regs = new(ExceptionState386)
+		case types.X86_THREAD_STATE64:
+			regs = new(RegsAMD64)
+		case types.X86_FLOAT_STATE64:
+			regs = new(FloatStateAMD64)
+		case types.X86_EXCEPTION_STATE64:
+			regs = new(ExceptionStateAMD64)
+		case types.X86_DEBUG_STATE32:
+			regs = new(DebugState386)
+		case types.X86_DEBUG_STATE64:
+			regs = new(DebugStateAMD64)
+		case types.X86_THREAD_STATE, types.X86_FLOAT_STATE, types.X86_EXCEPTION_STATE, types.X86_DEBUG_STATE:
+			// the generic x86 states are prefixed with the flavor of the actual state
+			return decodeUnifiedThreadState(cpu, bo, dat)
+		}
+	case types.CPUArm, types.CPUArm64, types.CPUArm6432:
+		switch flavor {
+		case types.ARM_THREAD_STATE:
+			if cpu != types.CPUArm {
+				// arm64's arm_unified_thread_state is prefixed with the flavor of the 32 or 64-bit state
+				return decodeUnifiedThreadState(cpu, bo, dat)
+			}
+			regs = new(RegsARM)
+		case types.ARM_THREAD_STATE32:
+			regs = new(RegsARM)
+		case types.ARM_VFP_STATE:
+			regs = new(FloatStateARM)
+		case types.ARM_EXCEPTION_STATE:
+			regs = new(ExceptionStateARM)
+		case types.ARM_THREAD_STATE64:
+			regs = new(RegsARM64)
+		case types.ARM_EXCEPTION_STATE64:
+			regs = new(ExceptionStateARM64)
+		case types.ARM_EXCEPTION_STATE64_V2:
+			regs = new(ExceptionStateARM64V2)
+		case types.ARM_NEON_STATE:
+			regs = new(NeonStateARM)
+		case types.ARM_NEON_STATE64:
+			regs = new(NeonStateARM64)
+		}
+	}
+	if regs == nil || len(dat) < binary.Size(regs) {
+		return nil, nil
+	}
+	if err := binary.Read(bytes.NewReader(dat), bo, regs); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+// decodeUnifiedThreadState decodes a state prefixed with the flavor and count of the actual state.
+func decodeUnifiedThreadState(cpu types.CPU, bo binary.ByteOrder, dat []byte) (interface{}, error) {
+	var hdr types.ThreadStateHdr
+	if err := binary.Read(bytes.NewReader(dat), bo, &hdr); err != nil {
+		return nil, err
+	}
+	return decodeThreadState(cpu, bo, hdr.Flavor, dat[binary.Size(hdr):])
+}
+
 func (f *File) pushSection(sh *Section, r io.ReaderAt) error {
 	f.Sections = append(f.Sections, sh)
 	sh.sr = io.NewSectionReader(r, int64(sh.Offset), int64(sh.Size))
@@ -1467,7 +1920,7 @@ func (f *File) pushSection(sh *Section, r io.ReaderAt) error {
 					rel.Extern = ri.Symnum&(1<<4) != 0
 					rel.Type = uint8(ri.Symnum & (1<<4 - 1))
 				default:
-					panic("unreachable")
+					return fmt.Errorf("unsupported byte order %v for relocation %d of section %s.%s", bo, i, sh.Seg, sh.Name)
 				}
 			}
 		}
@@ -1584,6 +2037,14 @@ func (f *File) GetPointer(offset uint64) (uint64, error) {
 	if err := binary.Read(f.cr, binary.LittleEndian, &ptr); err != nil {
 		return 0, fmt.Errorf("failed to read pointer at offset %#x: %v", offset, err)
 	}
+	if f.HasFixups() {
+		// binds are left as the raw chained pointer for GetBindName
+		if r, err := f.ChainedFixupResolver(); err == nil {
+			if rf, ok := r.Resolve(offset); ok && !rf.Bind {
+				return rf.Target, nil
+			}
+		}
+	}
 	return f.vma.Convert(ptr), nil
 }
 
@@ -1772,6 +2233,37 @@ func (f *File) UUID() *UUID {
 	return nil
 }
 
+// GetEntryPoint returns the VM address of the entry point of the file.
+// LC_MAIN is used if present, then the program counter of LC_UNIXTHREAD
+// and finally the initialization routine of LC_ROUTINES(_64) for dylibs.
+func (f *File) GetEntryPoint() (uint64, error) {
+	for _, l := range f.Loads {
+		if ep, ok := l.(*EntryPoint); ok {
+			text := f.Segment("__TEXT")
+			if text == nil {
+				return 0, fmt.Errorf("failed to find __TEXT segment for LC_MAIN entry offset %#x", ep.EntryOffset)
+			}
+			return text.Addr + ep.EntryOffset - text.Offset, nil
+		}
+	}
+	for _, l := range f.Loads {
+		if ut, ok := l.(*UnixThread); ok {
+			if pc, ok := ut.States.PC(); ok {
+				return pc, nil
+			}
+		}
+	}
+	for _, l := range f.Loads {
+		switch r := l.(type) {
+		case *Routines:
+			return uint64(r.InitAddress), nil
+		case *Routines64:
+			return r.InitAddress, nil
+		}
+	}
+	return 0, fmt.Errorf("no LC_MAIN, LC_UNIXTHREAD or LC_ROUTINES found")
+}
+
 // DylibID returns the dylib ID load command, or nil if no dylib ID exists.
 func (f *File) DylibID() *DylibID {
 	for _, l := range f.Loads {
@@ -1864,6 +2356,192 @@ func (f *File) DataInCode() *DataInCode {
 	return nil
 }
 
+// FunctionVariants returns the LC_FUNCTION_VARIANTS, or nil if none exists.
+func (f *File) FunctionVariants() *FunctionVariants {
+	for _, l := range f.Loads {
+		if s, ok := l.(*FunctionVariants); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// GetFunctionVariants returns the runtime tables stored in LC_FUNCTION_VARIANTS.
+func (f *File) GetFunctionVariants() ([]types.FunctionVariantsTable, error) {
+	fv := f.FunctionVariants()
+	if fv == nil {
+		return nil, fmt.Errorf("macho does not contain LC_FUNCTION_VARIANTS")
+	}
+	dat := make([]byte, fv.Size)
+	if _, err := f.cr.ReadAt(dat, int64(fv.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read LC_FUNCTION_VARIANTS data at offset=%#x; %v", fv.Offset, err)
+	}
+	if len(dat) < 4 {
+		return nil, fmt.Errorf("LC_FUNCTION_VARIANTS data is too small: %d bytes", len(dat))
+	}
+	// uint32_t tableCount; uint32_t tableOffsets[tableCount]; tables...
+	count := f.ByteOrder.Uint32(dat)
+	if uint64(count)*4+4 > uint64(len(dat)) {
+		return nil, fmt.Errorf("invalid LC_FUNCTION_VARIANTS table count %d", count)
+	}
+	tables := make([]types.FunctionVariantsTable, count)
+	for i := range tables {
+		off := f.ByteOrder.Uint32(dat[4+i*4:])
+		if uint64(off)+8 > uint64(len(dat)) {
+			return nil, fmt.Errorf("invalid LC_FUNCTION_VARIANTS table %d offset %#x", i, off)
+		}
+		r := bytes.NewReader(dat[off:])
+		var hdr struct {
+			Kind  types.FunctionVariantsKind
+			Count uint32
+		}
+		if err := binary.Read(r, f.ByteOrder, &hdr); err != nil {
+			return nil, fmt.Errorf("failed to read LC_FUNCTION_VARIANTS table %d: %v", i, err)
+		}
+		if uint64(hdr.Count)*uint64(binary.Size(types.FunctionVariantsEntry{})) > uint64(len(dat))-uint64(off)-8 {
+			return nil, fmt.Errorf("invalid LC_FUNCTION_VARIANTS table %d entry count %d", i, hdr.Count)
+		}
+		tables[i].Kind = hdr.Kind
+		tables[i].Entries = make([]types.FunctionVariantsEntry, hdr.Count)
+		if err := binary.Read(r, f.ByteOrder, tables[i].Entries); err != nil {
+			return nil, fmt.Errorf("failed to read LC_FUNCTION_VARIANTS table %d entries: %v", i, err)
+		}
+	}
+	return tables, nil
+}
+
+// FunctionVariantFixups returns the LC_FUNCTION_VARIANT_FIXUPS, or nil if none exists.
+func (f *File) FunctionVariantFixups() *FunctionVariantFixups {
+	for _, l := range f.Loads {
+		if s, ok := l.(*FunctionVariantFixups); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// GetFunctionVariantFixups returns the fixups stored in LC_FUNCTION_VARIANT_FIXUPS.
+func (f *File) GetFunctionVariantFixups() ([]types.FunctionVariantFixup, error) {
+	fvf := f.FunctionVariantFixups()
+	if fvf == nil {
+		return nil, fmt.Errorf("macho does not contain LC_FUNCTION_VARIANT_FIXUPS")
+	}
+	dat := make([]byte, fvf.Size)
+	if _, err := f.cr.ReadAt(dat, int64(fvf.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read LC_FUNCTION_VARIANT_FIXUPS data at offset=%#x; %v", fvf.Offset, err)
+	}
+	fixups := make([]types.FunctionVariantFixup, len(dat)/binary.Size(types.FunctionVariantFixup{}))
+	if err := binary.Read(bytes.NewReader(dat), f.ByteOrder, fixups); err != nil {
+		return nil, fmt.Errorf("failed to read LC_FUNCTION_VARIANT_FIXUPS entries: %v", err)
+	}
+	return fixups, nil
+}
+
+// TargetTriple returns the LC_TARGET_TRIPLE, or nil if none exists.
+func (f *File) TargetTriple() *TargetTriple {
+	for _, l := range f.Loads {
+		if s, ok := l.(*TargetTriple); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// LinkerOptimizationHint returns the LC_LINKER_OPTIMIZATION_HINT, or nil if none exists.
+func (f *File) LinkerOptimizationHint() *LinkerOptimizationHint {
+	for _, l := range f.Loads {
+		if s, ok := l.(*LinkerOptimizationHint); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// GetLOHsForFunction returns the linker optimization hints whose instructions are all within fn.
+// The functions of a MH_OBJECT come from GetObjectFunctions.
+func (f *File) GetLOHsForFunction(fn types.Function) []LOH {
+	var hints []LOH
+	if loh := f.LinkerOptimizationHint(); loh != nil {
+		for _, h := range loh.Hints {
+			if h.Within(fn.StartAddr, fn.EndAddr) {
+				hints = append(hints, h)
+			}
+		}
+	}
+	return hints
+}
+
+// GetFunctionLOHs returns the linker optimization hints grouped by the start
+// address of the function containing their first instruction. The functions
+// come from GetFunctions, or from GetObjectFunctions for a MH_OBJECT without function starts.
+func (f *File) GetFunctionLOHs() map[uint64][]LOH {
+	loh := f.LinkerOptimizationHint()
+	if loh == nil {
+		return nil
+	}
+	funcs := f.GetFunctions()
+	if len(funcs) == 0 && f.Type == types.MH_OBJECT {
+		funcs = f.GetObjectFunctions()
+	}
+	hints := make(map[uint64][]LOH)
+	for _, h := range loh.Hints {
+		if len(h.Addresses) == 0 {
+			continue
+		}
+		i := sort.Search(len(funcs), func(i int) bool { return funcs[i].EndAddr > h.Addresses[0] })
+		if i < len(funcs) && funcs[i].StartAddr <= h.Addresses[0] {
+			hints[funcs[i].StartAddr] = append(hints[funcs[i].StartAddr], h)
+		}
+	}
+	return hints
+}
+
+// GetObjectFunctions returns the functions of a MH_OBJECT, which normally has no
+// LC_FUNCTION_STARTS, from the symbols defined in its instruction sections.
+// A function ends at the next symbol of its section or at the end of the section.
+func (f *File) GetObjectFunctions() []types.Function {
+	if f.Symtab == nil {
+		return nil
+	}
+	bySect := make(map[uint8][]Symbol)
+	for _, sym := range f.Symtab.Syms {
+		if sym.Type.IsDebugSym() || sym.Type&types.N_TYPE != types.N_SECT || sym.Sect == 0 || int(sym.Sect) > len(f.Sections) {
+			continue
+		}
+		if flags := f.Sections[sym.Sect-1].Flags; !flags.IsPureInstructions() && !flags.IsSomeInstructions() {
+			continue
+		}
+		bySect[sym.Sect] = append(bySect[sym.Sect], sym)
+	}
+
+	var funcs []types.Function
+	for sect, syms := range bySect {
+		// prefer external names over local labels (e.g. ltmp0) at the same address
+		sort.SliceStable(syms, func(i, j int) bool {
+			if syms[i].Value != syms[j].Value {
+				return syms[i].Value < syms[j].Value
+			}
+			return syms[i].Type.IsExternalSym() && !syms[j].Type.IsExternalSym()
+		})
+		sec := f.Sections[sect-1]
+		for i, sym := range syms {
+			if i > 0 && syms[i-1].Value == sym.Value {
+				continue
+			}
+			end := sec.Addr + sec.Size
+			for _, next := range syms[i+1:] {
+				if next.Value != sym.Value {
+					end = next.Value
+					break
+				}
+			}
+			funcs = append(funcs, types.Function{Name: sym.Name, StartAddr: sym.Value, EndAddr: end})
+		}
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].StartAddr < funcs[j].StartAddr })
+	return funcs
+}
+
 // FunctionStarts returns the function starts array, or nil if none exists.
 func (f *File) FunctionStarts() *FunctionStarts {
 	for _, l := range f.Loads {
@@ -2076,17 +2754,70 @@ func (f *File) DyldChainedFixups() (*fixupchains.DyldChainedFixups, error) {
 	return nil, fmt.Errorf("macho does not contain LC_DYLD_CHAINED_FIXUPS")
 }
 
+// ChainedFixupResolver returns a resolver of the dyld chained fixups keyed by file offset.
+func (f *File) ChainedFixupResolver() (*fixupchains.Resolver, error) {
+	f.fixupsOnce.Do(func() {
+		if f.dcf == nil {
+			f.dcf, f.fixupsErr = f.DyldChainedFixups()
+			if f.fixupsErr != nil {
+				return
+			}
+		}
+		f.fixups = fixupchains.NewResolver(f.dcf, f.preferredLoadAddress())
+	})
+	return f.fixups, f.fixupsErr
+}
+
+// ResolveChainedFixup returns the rebased target or bound symbol and addend
+// dyld writes at the chained fixup at a given virtual address
+func (f *File) ResolveChainedFixup(addr uint64) (*fixupchains.ResolvedFixup, error) {
+	r, err := f.ChainedFixupResolver()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dyld chained fixups: %v", err)
+	}
+	offset, err := f.vma.GetOffset(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offset for address %#x: %v", addr, err)
+	}
+	rf, ok := r.Resolve(offset)
+	if !ok {
+		return nil, fmt.Errorf("no chained fixup at address %#x", addr)
+	}
+	return &rf, nil
+}
+
+// dwarfSectionSuffixes maps the suffixes of the DWARF section names that do not
+// fit in the 16 byte Mach-O section name (e.g. __debug_str_offs) to their full DWARF names.
+var dwarfSectionSuffixes = map[string]string{
+	"str_offs": "str_offsets",
+}
+
+// dwarfSectionSuffix returns the DWARF name suffix of a __debug_* or __zdebug_* section
+// (e.g. "info" for __debug_info and "str_offsets" for __debug_str_offs), or "" if it is not a DWARF section.
+func dwarfSectionSuffix(name string) string {
+	var suffix string
+	switch {
+	case strings.HasPrefix(name, "__debug_"):
+		suffix = name[8:]
+	case strings.HasPrefix(name, "__zdebug_"):
+		suffix = name[9:]
+	default:
+		return ""
+	}
+	if full, ok := dwarfSectionSuffixes[suffix]; ok {
+		return full
+	}
+	return suffix
+}
+
 // DWARF returns the DWARF debug information for the Mach-O file.
+//
+// The DWARF 5 __debug_str_offs, __debug_line_str, __debug_addr and __debug_rnglists
+// sections are added under their full DWARF names so strx, line_strp, addrx and
+// rnglistx forms resolve; go-dwarf ignores the other DWARF 5 sections.
 func (f *File) DWARF() (*dwarf.Data, error) {
 	dwarfSuffix := func(s *Section) string {
-		switch {
-		case strings.HasPrefix(s.Name, "__debug_"):
-			return s.Name[8:]
-		case strings.HasPrefix(s.Name, "__zdebug_"):
-			return s.Name[9:]
-		default:
-			return ""
-		}
+		return dwarfSectionSuffix(s.Name)
 	}
 	appleSuffix := func(s *Section) string {
 		switch {
@@ -2101,6 +2832,15 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 		if err != nil && uint64(len(b)) < s.Size {
 			return nil, err
 		}
+		if f.Type == types.MH_OBJECT && len(s.Relocs) > 0 {
+			// unlinked objects need their relocations applied to get the right addresses
+			// (relocations refer to the section as stored, so they go before decompression)
+			if rb, err := f.GetRelocatedSectionData(s, nil); err != nil {
+				f.warn(DiagRelocation, int64(s.Offset), "using the unrelocated data of section %s.%s: %v", s.Seg, s.Name, err)
+			} else {
+				b = rb
+			}
+		}
 
 		if len(b) >= 12 && string(b[:4]) == "ZLIB" {
 			dlen := binary.BigEndian.Uint64(b[4:12])
@@ -2186,7 +2926,7 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 			return nil, err
 		}
 		dat[suffix] = b
-		// TODO: finish implementing this.
+		// see File.AccelTables to query the tables by name
 		if err := d.AddHashes(suffix, b); err != nil {
 			return nil, err
 		}
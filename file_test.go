@@ -6,17 +6,22 @@ package macho
 
 import (
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/blacktop/go-dwarf"
 	"github.com/blacktop/go-macho/internal/obscuretestdata"
+	"github.com/blacktop/go-macho/pkg/fixupchains"
+	"github.com/blacktop/go-macho/pkg/unwind"
 	"github.com/blacktop/go-macho/types"
 )
 
@@ -390,6 +395,51 @@ func TestOpenFat(t *testing.T) {
 	}
 }
 
+func TestOpenFat64(t *testing.T) {
+	b, err := obscuretestdata.ReadFile("internal/testdata/fat-gcc-386-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the fat_header and fat_arch structs as fat_arch_64 in place;
+	// the slices start on a page boundary so the larger header still fits.
+	narch := binary.BigEndian.Uint32(b[4:])
+	arches := make([]byte, narch*fatArch64HeaderSize)
+	for i := uint32(0); i < narch; i++ {
+		src := b[8+i*fatArchHeaderSize:]
+		dst := arches[i*fatArch64HeaderSize:]
+		binary.BigEndian.PutUint32(dst[0:], binary.BigEndian.Uint32(src[0:]))           // cputype
+		binary.BigEndian.PutUint32(dst[4:], binary.BigEndian.Uint32(src[4:]))           // cpusubtype
+		binary.BigEndian.PutUint64(dst[8:], uint64(binary.BigEndian.Uint32(src[8:])))   // offset
+		binary.BigEndian.PutUint64(dst[16:], uint64(binary.BigEndian.Uint32(src[12:]))) // size
+		binary.BigEndian.PutUint32(dst[24:], binary.BigEndian.Uint32(src[16:]))         // align
+		binary.BigEndian.PutUint32(dst[28:], 0)                                         // reserved
+	}
+	binary.BigEndian.PutUint32(b[0:], uint32(types.MagicFat64))
+	copy(b[8:], arches)
+
+	ff, err := NewFatFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ff.Magic != types.MagicFat64 {
+		t.Errorf("OpenFat: got magic number %#x, want %#x", ff.Magic, types.MagicFat64)
+	}
+	if len(ff.Arches) != 2 {
+		t.Fatalf("OpenFat: got %d architectures, want 2", len(ff.Arches))
+	}
+
+	for i := range ff.Arches {
+		arch := &ff.Arches[i]
+		ftArch := &fileTests[i]
+
+		if !reflect.DeepEqual(arch.FileHeader, ftArch.hdr) {
+			t.Errorf("OpenFat header:\n\tgot %#v\n\twant %#v\n", arch.FileHeader, ftArch.hdr)
+		}
+	}
+}
+
 func TestOpenFatFailure(t *testing.T) {
 	filename := "file.go" // not a Mach-O file
 	if _, err := OpenFat(filename); err == nil {
@@ -406,6 +456,1870 @@ func TestOpenFatFailure(t *testing.T) {
 	}
 }
 
+func TestOpenArchive(t *testing.T) {
+	obj, err := obscuretestdata.ReadFile("internal/testdata/clang-amd64-darwin.obj.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const longName = "a_very_long_object_file_name.o"
+	member := func(name string, data []byte) []byte {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", "#1/"+fmt.Sprint(len(name)), 0, 0, 0, 0644, len(name)+len(data))
+		b.WriteString(name)
+		b.Write(data)
+		if b.Len()&1 != 0 {
+			b.WriteByte('\n')
+		}
+		return b.Bytes()
+	}
+
+	// the object member follows the magic and the symdef member
+	symdef := make([]byte, 4+8+4+8)
+	binary.LittleEndian.PutUint32(symdef[0:], 8)
+	binary.LittleEndian.PutUint32(symdef[4:], 0) // ran_strx
+	binary.LittleEndian.PutUint32(symdef[12:], 8)
+	copy(symdef[16:], "_main\x00\x00\x00")
+	symdefMember := member(SymdefSortedName+"\x00\x00\x00\x00", symdef)
+	binary.LittleEndian.PutUint32(symdef[8:], uint32(len(archiveMagic)+len(symdefMember))) // ran_off
+	symdefMember = member(SymdefSortedName+"\x00\x00\x00\x00", symdef)
+
+	var b bytes.Buffer
+	b.WriteString(archiveMagic)
+	b.Write(symdefMember)
+	b.Write(member(longName, obj))
+
+	a, err := NewArchive(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Symdef != SymdefSortedName {
+		t.Errorf("NewArchive: got symdef %q, want %q", a.Symdef, SymdefSortedName)
+	}
+	if len(a.Members) != 1 {
+		t.Fatalf("NewArchive: got %d members, want 1", len(a.Members))
+	}
+	if a.Members[0].Name != longName {
+		t.Errorf("NewArchive: got member name %q, want %q", a.Members[0].Name, longName)
+	}
+	if m := a.SymbolMember("_main"); m != a.Members[0] {
+		t.Errorf("NewArchive: symbol _main resolved to %v, want %v", m, a.Members[0])
+	}
+
+	f, err := a.Members[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.FileHeader, fileTests[6].hdr) {
+		t.Errorf("NewArchive member header:\n\tgot %#v\n\twant %#v\n", f.FileHeader, fileTests[6].hdr)
+	}
+
+	if _, err := NewArchive(bytes.NewReader(obj)); err != ErrNotArchive {
+		t.Errorf("NewArchive: got %v, want ErrNotArchive", err)
+	}
+
+	// malformed member sizes must fail instead of looping on the same header
+	for _, size := range []int{-60, 100} {
+		var bad bytes.Buffer
+		bad.WriteString(archiveMagic)
+		fmt.Fprintf(&bad, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", "a.o/", 0, 0, 0, 0644, size)
+		bad.WriteString("short")
+		var fe *FormatError
+		if _, err := NewArchive(bytes.NewReader(bad.Bytes())); !errors.As(err, &fe) {
+			t.Errorf("NewArchive(size %d): got %v, want a FormatError", size, err)
+		}
+	}
+}
+
+func TestGetEntryPoint(t *testing.T) {
+	tests := []struct {
+		file  string
+		entry uint64
+		regs  interface{}
+	}{
+		{"internal/testdata/gcc-386-darwin-exec.base64", 0x1f68, &Regs386{}},              // LC_UNIXTHREAD
+		{"internal/testdata/gcc-amd64-darwin-exec.base64", 0x100000f14, &RegsAMD64{}},     // LC_UNIXTHREAD
+		{"internal/testdata/clang-amd64-darwin-exec-with-rpath.base64", 0x100000f60, nil}, // LC_MAIN
+	}
+	for _, tt := range tests {
+		f, err := openObscured(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := f.GetEntryPoint()
+		if err != nil {
+			t.Fatalf("GetEntryPoint() error = %v", err)
+		}
+		if entry != tt.entry {
+			t.Errorf("%s: GetEntryPoint() = %#x, want %#x", tt.file, entry, tt.entry)
+		}
+		if tt.regs == nil {
+			continue
+		}
+		for _, l := range f.Loads {
+			if ut, ok := l.(*UnixThread); ok {
+				if len(ut.States) != 1 || reflect.TypeOf(ut.States[0].Regs) != reflect.TypeOf(tt.regs) {
+					t.Errorf("%s: got thread states %v, want one %T", tt.file, ut.States, tt.regs)
+				}
+			}
+		}
+	}
+}
+
+func TestUnifiedThreadState(t *testing.T) {
+	// an arm64 ARM_THREAD_STATE is an arm_unified_thread_state wrapping a ARM_THREAD_STATE64
+	regs := RegsARM64{SP: 0x16fdff000, PC: 0x100003f00}
+	var state bytes.Buffer
+	binary.Write(&state, binary.LittleEndian, types.ThreadStateHdr{Flavor: types.ARM_THREAD_STATE64, Count: uint32(binary.Size(regs) / 4)})
+	binary.Write(&state, binary.LittleEndian, regs)
+
+	var cmd bytes.Buffer
+	binary.Write(&cmd, binary.LittleEndian, []uint32{uint32(types.LC_THREAD), uint32(16 + state.Len())})
+	binary.Write(&cmd, binary.LittleEndian, types.ThreadStateHdr{Flavor: types.ARM_THREAD_STATE, Count: uint32(state.Len() / 4)})
+	cmd.Write(state.Bytes())
+
+	states, err := parseThreadStates(types.CPUArm64, binary.LittleEndian, cmd.Bytes()[8:])
+	if err != nil {
+		t.Fatalf("parseThreadStates() error = %v", err)
+	}
+	if len(states) != 1 {
+		t.Fatalf("parseThreadStates() = %v, want one state", states)
+	}
+	if got, ok := states[0].Regs.(*RegsARM64); !ok || *got != regs {
+		t.Errorf("parseThreadStates() regs = %#v, want %#v", states[0].Regs, regs)
+	}
+	if pc, ok := states.PC(); !ok || pc != 0x100003f00 {
+		t.Errorf("PC() = %#x, %t, want 0x100003f00", pc, ok)
+	}
+
+	// a 32-bit arm ARM_THREAD_STATE is the registers themselves
+	regs32 := RegsARM{PC: 0x8000}
+	var cmd32 bytes.Buffer
+	binary.Write(&cmd32, binary.LittleEndian, types.ThreadStateHdr{Flavor: types.ARM_THREAD_STATE, Count: uint32(binary.Size(regs32) / 4)})
+	binary.Write(&cmd32, binary.LittleEndian, regs32)
+	if states, err := parseThreadStates(types.CPUArm, binary.LittleEndian, cmd32.Bytes()); err != nil || len(states) != 1 {
+		t.Fatalf("parseThreadStates() = %v, %v", states, err)
+	} else if got, ok := states[0].Regs.(*RegsARM); !ok || got.PC != 0x8000 {
+		t.Errorf("parseThreadStates() regs = %#v, want PC 0x8000", states[0].Regs)
+	}
+
+	// LC_THREAD keeps the raw flavor and words
+	f := &File{}
+	f.ByteOrder = binary.LittleEndian
+	f.CPU = types.CPUArm64
+	f.Loads = make([]Load, 1)
+	if err := f.parseLoadCmd(0, types.LC_THREAD, uint32(cmd.Len()), cmd.Bytes(), int64(cmd.Len()), nil); err != nil {
+		t.Fatalf("parseLoadCmd() error = %v", err)
+	}
+	th, ok := f.Loads[0].(*Thread)
+	if !ok {
+		t.Fatalf("parseLoadCmd() = %T, want *Thread", f.Loads[0])
+	}
+	if th.Type != uint32(types.ARM_THREAD_STATE) || len(th.Data) != 1+state.Len()/4 || th.Data[0] != uint32(state.Len()/4) {
+		t.Errorf("Thread Type = %d, Data = %d words", th.Type, len(th.Data))
+	}
+	if pc, ok := th.States.PC(); !ok || pc != 0x100003f00 {
+		t.Errorf("Thread PC() = %#x, %t, want 0x100003f00", pc, ok)
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	ra, err := readerAtFromObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decs := NewDecoders()
+	decs.RegisterSection("__TEXT", "__cstring", func(f *File, sec *Section) (interface{}, error) {
+		dat, err := sec.Data()
+		if err != nil {
+			return nil, err
+		}
+		return cstring(dat), nil
+	})
+	f, err := NewFile(ra, FileConfig{Decoders: decs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := f.Section("__TEXT", "__cstring")
+	if sec == nil {
+		t.Fatal("Section() error = section __TEXT.__cstring not found")
+	}
+	if got, want := sec.Decoded, "hello, world"; got != want {
+		t.Errorf("Section(__TEXT, __cstring).Decoded = %q, want %q", got, want)
+	}
+	if sec := f.Section("__TEXT", "__text"); sec.Decoded != nil {
+		t.Errorf("Section(__TEXT, __text).Decoded = %v, want nil", sec.Decoded)
+	}
+}
+
+// vendorCmd is the typed result of the test load command decoder.
+type vendorCmd struct {
+	LoadCmdBytes
+	Value uint32
+}
+
+func TestLoadCmdDecoders(t *testing.T) {
+	const lcVendor = types.LoadCmd(0x7e)
+	cmd := leCmd(lcVendor, 0xdeadbeef)
+	binary.LittleEndian.PutUint32(cmd[4:], uint32(len(cmd)))
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, types.FileHeader{Magic: types.Magic64, CPU: types.CPUArm64, Type: types.MH_EXECUTE, NCommands: 1, SizeCommands: uint32(len(cmd))})
+	buf.Write(cmd)
+	blob := buf.Bytes()
+
+	// without a decoder the command is kept as bytes and reported
+	f, err := NewFile(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if _, ok := f.Loads[0].(LoadCmdBytes); !ok {
+		t.Errorf("Loads[0] = %T, want LoadCmdBytes", f.Loads[0])
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagUnknownLoadCmd || diags[0].Cmd != lcVendor || diags[0].Message != "unknown load command LoadCmd(126)" {
+		t.Errorf("Diagnostics() = %v, want an unknown load command", diags)
+	}
+
+	decode := func(value uint32) LoadCmdDecoder {
+		return func(f *File, c types.LoadCmd, dat []byte) (Load, error) {
+			if c != lcVendor || !bytes.Equal(dat, cmd) {
+				t.Errorf("LoadCmdDecoder(%s, % x), want %s, % x", c, dat, lcVendor, cmd)
+			}
+			return &vendorCmd{LoadCmdBytes{c, LoadBytes(dat)}, value}, nil
+		}
+	}
+	decs := NewDecoders()
+	decs.RegisterLoadCmd(lcVendor, decode(f.ByteOrder.Uint32(cmd[8:])))
+	if f, err = NewFile(bytes.NewReader(blob), FileConfig{Decoders: decs}); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if l, ok := f.Loads[0].(*vendorCmd); !ok || l.Value != 0xdeadbeef {
+		t.Errorf("Loads[0] = %#v, want the decoded command", f.Loads[0])
+	}
+	if diags := f.Diagnostics(); len(diags) != 0 {
+		t.Errorf("Diagnostics() = %v, want none", diags)
+	}
+
+	// the global registry is used after the one in FileConfig
+	RegisterLoadCmdDecoder(lcVendor, decode(1))
+	t.Cleanup(func() {
+		DefaultDecoders.mu.Lock()
+		delete(DefaultDecoders.loadCmds, lcVendor)
+		DefaultDecoders.mu.Unlock()
+	})
+	if f, err = NewFile(bytes.NewReader(blob)); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if l, ok := f.Loads[0].(*vendorCmd); !ok || l.Value != 1 {
+		t.Errorf("Loads[0] = %#v, want the command decoded by DefaultDecoders", f.Loads[0])
+	}
+	if f, err = NewFile(bytes.NewReader(blob), FileConfig{Decoders: decs}); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if l, ok := f.Loads[0].(*vendorCmd); !ok || l.Value != 0xdeadbeef {
+		t.Errorf("Loads[0] = %#v, want the command decoded by FileConfig.Decoders", f.Loads[0])
+	}
+
+	// decoder errors fail NewFile, or are reported in lenient mode
+	errDecode := errors.New("bad vendor command")
+	decs.RegisterLoadCmd(lcVendor, func(*File, types.LoadCmd, []byte) (Load, error) { return nil, errDecode })
+	if _, err := NewFile(bytes.NewReader(blob), FileConfig{Decoders: decs}); err == nil {
+		t.Error("NewFile() should fail when a load command decoder fails")
+	}
+	if f, err = NewFile(bytes.NewReader(blob), FileConfig{Decoders: decs, Lenient: true}); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if _, ok := f.Loads[0].(LoadCmdBytes); !ok {
+		t.Errorf("Loads[0] = %T, want LoadCmdBytes", f.Loads[0])
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagMalformedLoadCmd || diags[0].Cmd != lcVendor || !errors.Is(diags[0].Err, errDecode) {
+		t.Errorf("Diagnostics() = %v, want the decoder error", diags)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	b, err := obscuretestdata.ReadFile("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// find the file offset of the LC_SYMTAB load command
+	idx, off := -1, int64(types.FileHeaderSize64)
+	for i, l := range f.Loads {
+		if l.Command() == types.LC_SYMTAB {
+			idx = i
+			break
+		}
+		off += int64(len(l.Raw()))
+	}
+	if idx < 0 {
+		t.Fatal("LC_SYMTAB not found")
+	}
+
+	unknown := append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(unknown[off:], 0x7e)
+	var diags DiagnosticList
+	f, err = NewFile(bytes.NewReader(unknown), FileConfig{Diagnostics: &diags, Lenient: true})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if len(diags) == 0 || diags[0].Kind != DiagUnknownLoadCmd || diags[0].Offset != off {
+		t.Errorf("NewFile() diagnostics = %v, want unknown load command at %#x", diags, off)
+	}
+
+	malformed := append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(malformed[off+8:], 0xfffffff0) // symoff
+	if _, err := NewFile(bytes.NewReader(malformed)); err == nil {
+		t.Error("NewFile() error = nil, want malformed LC_SYMTAB error")
+	}
+	f, err = NewFile(bytes.NewReader(malformed), FileConfig{Lenient: true})
+	if err != nil {
+		t.Fatalf("NewFile(lenient) error = %v", err)
+	}
+	var found bool
+	for _, d := range f.Diagnostics() {
+		if d.Kind == DiagMalformedLoadCmd && d.Cmd == types.LC_SYMTAB && d.Severity == DiagError {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Diagnostics() = %v, want malformed LC_SYMTAB", f.Diagnostics())
+	}
+	if _, ok := f.Loads[idx].(LoadCmdBytes); !ok {
+		t.Errorf("Loads[%d] = %T, want LoadCmdBytes", idx, f.Loads[idx])
+	}
+	if f.Segment("__TEXT") == nil {
+		t.Error("Segment(__TEXT) = nil, want partially parsed file")
+	}
+
+	// a segment whose second section header is missing keeps none of its sections
+	var cmds bytes.Buffer
+	binary.Write(&cmds, binary.LittleEndian, types.Segment64{LoadCmd: types.LC_SEGMENT_64, Len: 72 + 80, Name: [16]byte{'_', '_', 'T', 'E', 'X', 'T'}, Nsect: 2})
+	binary.Write(&cmds, binary.LittleEndian, types.Section64{Name: [16]byte{'_', '_', 't', 'e', 'x', 't'}, Seg: [16]byte{'_', '_', 'T', 'E', 'X', 'T'}})
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, types.FileHeader{Magic: types.Magic64, CPU: types.CPUArm64, Type: types.MH_EXECUTE, NCommands: 1, SizeCommands: uint32(cmds.Len())})
+	buf.Write(cmds.Bytes())
+	if f, err = NewFile(bytes.NewReader(buf.Bytes()), FileConfig{Lenient: true}); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if len(f.Sections) != 0 || f.Segment("__TEXT") != nil {
+		t.Errorf("NewFile() sections = %v, want the partial segment dropped", f.Sections)
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagMalformedLoadCmd || diags[0].Cmd != types.LC_SEGMENT_64 {
+		t.Errorf("Diagnostics() = %v, want a malformed LC_SEGMENT_64", diags)
+	}
+}
+
+func TestGetIndirectSymbols(t *testing.T) {
+	f, err := openObscured("internal/testdata/clang-amd64-darwin-exec-with-rpath.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isyms, err := f.GetIndirectSymbols()
+	if err != nil {
+		t.Fatalf("GetIndirectSymbols() error = %v", err)
+	}
+	want := []struct {
+		addr uint64
+		sect string
+		name string
+		lib  string
+	}{
+		{0x100000f8a, "__stubs", "_printf", "libSystem.B.dylib"},
+		{0x100001000, "__nl_symbol_ptr", "dyld_stub_binder", "libSystem.B.dylib"},
+		{0x100001008, "__nl_symbol_ptr", "ABSOLUTE", ""},
+		{0x100001010, "__la_symbol_ptr", "_printf", "libSystem.B.dylib"},
+	}
+	if len(isyms) != len(want) {
+		t.Fatalf("GetIndirectSymbols() = %v, want %d symbols", isyms, len(want))
+	}
+	for i, w := range want {
+		if isyms[i].Addr != w.addr || isyms[i].Section.Name != w.sect || isyms[i].Name() != w.name || isyms[i].Library != w.lib {
+			t.Errorf("GetIndirectSymbols()[%d] = %s (%s), want %#x %s (%s) from %q", i, isyms[i], isyms[i].Section.Name, w.addr, w.name, w.sect, w.lib)
+		}
+	}
+	isym, err := f.GetIndirectSymbolAtAddress(0x100000f8a)
+	if err != nil {
+		t.Fatalf("GetIndirectSymbolAtAddress() error = %v", err)
+	}
+	if isym.Name() != "_printf" {
+		t.Errorf("GetIndirectSymbolAtAddress() = %s, want _printf", isym)
+	}
+}
+
+func TestGetStubs(t *testing.T) {
+	tests := []struct {
+		file  string
+		stubs map[uint64]string
+	}{
+		{"internal/testdata/gcc-amd64-darwin-exec.base64", map[uint64]string{0x100000f81: "_exit", 0x100000f87: "_puts"}},
+		{"internal/testdata/clang-amd64-darwin-exec-with-rpath.base64", map[uint64]string{0x100000f8a: "_printf"}},
+	}
+	for _, tt := range tests {
+		f, err := openObscured(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stubs, err := f.GetStubs()
+		if err != nil {
+			t.Fatalf("GetStubs() error = %v", err)
+		}
+		if !reflect.DeepEqual(stubs, tt.stubs) {
+			t.Errorf("%s: GetStubs() = %v, want %v", tt.file, stubs, tt.stubs)
+		}
+	}
+
+	arm64 := []struct {
+		name string
+		addr uint64
+		ins  []uint32
+		slot uint64
+	}{
+		{"arm64", 0x100004000, []uint32{0xf0fffff0, 0xf9400e10, 0xd61f0200}, 0x100003018},              // adrp x16, -1 page; ldr x16, [x16, #0x18]; br x16
+		{"arm64e", 0x100007f00, []uint32{0xb0000011, 0x91004231, 0xf9400230, 0xd71f0a11}, 0x100008010}, // adrp x17; add x17, x17, #0x10; ldr x16, [x17]; braa x16, x17
+	}
+	for _, tt := range arm64 {
+		dat := make([]byte, 4*len(tt.ins))
+		for i, ins := range tt.ins {
+			binary.LittleEndian.PutUint32(dat[4*i:], ins)
+		}
+		slot, ok := decodeStubArm64(tt.addr, dat, binary.LittleEndian)
+		if !ok || slot != tt.slot {
+			t.Errorf("decodeStubArm64(%s) = %#x, %t, want %#x", tt.name, slot, ok, tt.slot)
+		}
+	}
+}
+
+func TestSymbolIndex(t *testing.T) {
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := f.SymbolIndex()
+
+	if syms := idx.Lookup("_main"); len(syms) != 1 || syms[0].Addr != 0x100000f6a || syms[0].Size != 0x17 {
+		t.Errorf("Lookup(_main) = %v", syms)
+	}
+	if syms := idx.Lookup("_MAIN"); len(syms) != 0 {
+		t.Errorf("Lookup(_MAIN) = %v, want no symbols", syms)
+	}
+	// FindSymbolAddress does not use the index and stays case-insensitive
+	if addr, err := f.FindSymbolAddress("_MAIN"); err != nil || addr != 0x100000f6a {
+		t.Errorf("FindSymbolAddress(_MAIN) = %#x, %v, want 0x100000f6a", addr, err)
+	}
+
+	var names []string
+	for _, sym := range idx.Prefix("_N") {
+		names = append(names, sym.Name)
+	}
+	if want := []string{"_NXArgc", "_NXArgv"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Prefix(_N) = %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		addr uint64
+		want string
+	}{
+		{0x100000f14, "start"},
+		{0x100000f30, "start+0x1c"},
+		{0x100000f70, "_main+0x6"},
+		{0x100001014, "_NXArgv+0x4"},
+		{0x100000000, "0x100000000"},
+	}
+	for _, tt := range tests {
+		if got := idx.Symbolicate(tt.addr); got != tt.want {
+			t.Errorf("Symbolicate(%#x) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+
+	syms, err := f.FindAddressSymbols(0x100001008)
+	if err != nil || len(syms) != 1 || syms[0].Name != "_environ" {
+		t.Errorf("FindAddressSymbols(0x100001008) = %v, %v", syms, err)
+	}
+}
+
+func TestDebugMap(t *testing.T) {
+	f := &File{Symtab: &Symtab{Syms: []Symbol{
+		{Name: "", Type: types.N_SO},
+		{Name: "/tmp/src/", Type: types.N_SO, Sect: 1, Value: 0x100000f50},
+		{Name: "main.c", Type: types.N_SO, Sect: 1, Value: 0x100000f50},
+		{Name: "/tmp/build/main.o", Type: types.N_OSO, Value: 1600000000},
+		{Name: "", Type: types.N_BNSYM, Sect: 1, Value: 0x100000f50},
+		{Name: "_main", Type: types.N_FUN, Sect: 1, Value: 0x100000f50},
+		{Name: "", Type: types.N_FUN, Value: 0x20},
+		{Name: "", Type: types.N_ENSYM, Sect: 1, Value: 0x100000f50},
+		{Name: "_counter", Type: types.N_STSYM, Sect: 2, Value: 0x100001000},
+		{Name: "_global", Type: types.N_GSYM},
+		{Name: "", Type: types.N_SO, Sect: 1},
+		{Name: "_global", Type: types.N_SECT | types.N_EXT, Sect: 2, Value: 0x100001008},
+	}}}
+
+	m, err := f.DebugMap()
+	if err != nil {
+		t.Fatalf("DebugMap() error = %v", err)
+	}
+	if len(m.Objects) != 1 {
+		t.Fatalf("DebugMap() objects = %d, want 1", len(m.Objects))
+	}
+	obj := m.Objects[0]
+	if obj.Path != "/tmp/build/main.o" || obj.ModTime.Unix() != 1600000000 || obj.SourceDir != "/tmp/src/" || obj.SourceFile != "main.c" {
+		t.Errorf("DebugMap() object = %+v", obj)
+	}
+	want := []DebugMapSymbol{
+		{Name: "_main", Type: types.N_FUN, Sect: 1, BinaryAddr: 0x100000f50, Size: 0x20},
+		{Name: "_counter", Type: types.N_STSYM, Sect: 2, BinaryAddr: 0x100001000},
+		{Name: "_global", Type: types.N_GSYM, BinaryAddr: 0x100001008},
+	}
+	if !reflect.DeepEqual(obj.Symbols, want) {
+		t.Errorf("DebugMap() symbols = %+v, want %+v", obj.Symbols, want)
+	}
+
+	if o, sym := m.FindSymbol(0x100000f60); o != obj || sym == nil || sym.Name != "_main" {
+		t.Errorf("FindSymbol(0x100000f60) = %v, %v", o, sym)
+	}
+
+	objFile := &File{Symtab: &Symtab{Syms: []Symbol{
+		{Name: "_main", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0x0},
+		{Name: "_counter", Type: types.N_SECT, Sect: 2, Value: 0x40},
+	}}}
+	if missing := obj.ResolveObjectAddrs(objFile); missing != 1 {
+		t.Errorf("ResolveObjectAddrs() missing = %d, want 1", missing)
+	}
+	if !obj.Symbols[1].Resolved || obj.Symbols[1].ObjectAddr != 0x40 || obj.Symbols[2].Resolved {
+		t.Errorf("ResolveObjectAddrs() symbols = %+v", obj.Symbols)
+	}
+}
+
+func TestOSODWARF(t *testing.T) {
+	f := &File{Symtab: &Symtab{Syms: []Symbol{
+		{Name: "/tmp/src/", Type: types.N_SO, Sect: 1},
+		{Name: "hello.c", Type: types.N_SO, Sect: 1},
+		{Name: "hello.o", Type: types.N_OSO},
+		{Name: "_main", Type: types.N_FUN, Sect: 1, Value: 0x100001000},
+		{Name: "", Type: types.N_FUN, Value: 0x17},
+		{Name: "", Type: types.N_SO, Sect: 1},
+	}}}
+
+	if _, err := f.OSODWARF(t.TempDir()); err == nil {
+		t.Fatal("OSODWARF() should fail without the object files")
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagDebugMap {
+		t.Errorf("OSODWARF() diagnostics = %v, want a missing object file", diags)
+	}
+
+	// use the DWARF of a dSYM as the object file, with _main linked at a different address
+	m, err := f.DebugMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := openObscured("internal/testdata/gcc-amd64-darwin-exec-debug.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Symtab = &Symtab{Syms: []Symbol{{Name: "_main", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0x100000f6a}}}
+	d := &OSODWARF{Map: m}
+	if err := d.addObject(&OSOObject{DebugMapObject: m.Objects[0], File: obj}); err != nil {
+		t.Fatal(err)
+	}
+	if missing := m.Objects[0].ResolveObjectAddrs(obj); missing != 0 {
+		t.Fatalf("ResolveObjectAddrs() missing = %d", missing)
+	}
+	d.buildRanges()
+
+	if _, objAddr, ok := d.Lookup(0x100001004); !ok || objAddr != 0x100000f6e {
+		t.Errorf("Lookup(0x100001004) = %#x, %t, want 0x100000f6e", objAddr, ok)
+	}
+	if _, _, ok := d.Lookup(0x100001017); ok {
+		t.Error("Lookup(0x100001017) should be past the end of _main")
+	}
+	le, err := d.LineForAddr(0x100001004)
+	if err != nil {
+		t.Fatalf("LineForAddr() error = %v", err)
+	}
+	if le.Line != 4 || le.Address != 0x100001004 || !strings.HasSuffix(le.File.Name, "hello.c") {
+		t.Errorf("LineForAddr(0x100001004) = %#x %s:%d, want 0x100001004 hello.c:4", le.Address, le.File.Name, le.Line)
+	}
+	_, fn, err := d.FunctionForAddr(0x100001010)
+	if err != nil {
+		t.Fatalf("FunctionForAddr() error = %v", err)
+	}
+	if name, _ := fn.Val(dwarf.AttrName).(string); name != "main" {
+		t.Errorf("FunctionForAddr(0x100001010) = %s, want main", name)
+	}
+}
+
+func TestDSYMIndex(t *testing.T) {
+	dir := t.TempDir()
+	bundles := map[string]string{
+		"hello.dSYM": "internal/testdata/gcc-amd64-darwin-exec-debug.base64",
+		"fat.dSYM":   "internal/testdata/fat-gcc-386-amd64-darwin-exec.base64",
+	}
+	for bundle, file := range bundles {
+		dat, err := obscuretestdata.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dwarfDir := filepath.Join(dir, "sub", bundle, "Contents", "Resources", "DWARF")
+		if err := os.MkdirAll(dwarfDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dwarfDir, strings.TrimSuffix(bundle, ".dSYM")), dat, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := NewDSYMIndex(dir)
+	if err != nil {
+		t.Fatalf("NewDSYMIndex() error = %v", err)
+	}
+	if uuids := idx.UUIDs(); len(uuids) != 3 {
+		t.Errorf("NewDSYMIndex() UUIDs = %v, want 3", uuids)
+	}
+
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := idx.Find(f)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if filepath.Base(d.Path) != "fat" || d.File.CPU != types.CPUAmd64 || d.UUID != f.UUID().UUID {
+		t.Errorf("Find() = %s %s %s", d.Path, d.File.CPU, d.UUID)
+	}
+	d.Close()
+
+	ff, err := openFatObscured("internal/testdata/fat-gcc-386-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsyms, err := idx.FindFat(ff)
+	if err != nil {
+		t.Fatalf("FindFat() error = %v", err)
+	}
+	for i, d := range dsyms {
+		if d == nil || d.File.CPU != ff.Arches[i].CPU {
+			t.Errorf("FindFat() slice %d = %v", i, d)
+			continue
+		}
+		d.Close()
+	}
+
+	dbg, err := openObscured("internal/testdata/gcc-amd64-darwin-exec-debug.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dw, err := dbg.FindDSYMDWARF(dir)
+	if err != nil {
+		t.Fatalf("FindDSYMDWARF() error = %v", err)
+	}
+	if cu, err := dw.Reader().Next(); err != nil || cu == nil || cu.Val(dwarf.AttrName) != "hello.c" {
+		t.Errorf("FindDSYMDWARF() compile unit = %v, %v", cu, err)
+	}
+}
+
+func TestDWARFSectionSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"__debug_info", "info"},
+		{"__zdebug_info", "info"},
+		{"__debug_str_offs", "str_offsets"},
+		{"__debug_line_str", "line_str"},
+		{"__debug_addr", "addr"},
+		{"__debug_rnglists", "rnglists"},
+		{"__debug_loclists", "loclists"},
+		{"__debug_names", "names"},
+		{"__apple_names", ""},
+		{"__text", ""},
+	}
+	for _, tt := range tests {
+		if got := dwarfSectionSuffix(tt.name); got != tt.want {
+			t.Errorf("dwarfSectionSuffix(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+type testSection struct {
+	name string
+	dat  []byte
+}
+
+// newSectionsFile returns a little endian File with the given __DWARF sections.
+func newSectionsFile(sections []testSection) *File {
+	var blob []byte
+	f := &File{}
+	f.ByteOrder = binary.LittleEndian
+	for _, sec := range sections {
+		f.Sections = append(f.Sections, &Section{
+			SectionHeader: SectionHeader{Name: sec.name, Seg: "__DWARF", Size: uint64(len(sec.dat)), Offset: uint32(len(blob))},
+		})
+		blob = append(blob, sec.dat...)
+	}
+	for _, sec := range f.Sections {
+		sec.ReaderAt = bytes.NewReader(blob)
+	}
+	return f
+}
+
+func TestDWARF5(t *testing.T) {
+	// a DWARF 5 compile unit with a string in each of the strx forms and its comp dir a DW_FORM_line_strp
+	f := newSectionsFile([]testSection{
+		{"__debug_abbrev", []byte{
+			0x01, 0x11, 0x00, // abbrev 1: DW_TAG_compile_unit, no children
+			0x03, 0x25, // DW_AT_name, DW_FORM_strx1
+			0x72, 0x17, // DW_AT_str_offsets_base, DW_FORM_sec_offset
+			0x1b, 0x1f, // DW_AT_comp_dir, DW_FORM_line_strp
+			0x25, 0x1a, // DW_AT_producer, DW_FORM_strx
+			0x5a, 0x26, // DW_AT_description, DW_FORM_strx2
+			0xef, 0x7f, 0x27, // DW_AT_APPLE_sdk, DW_FORM_strx3
+			0x82, 0x7c, 0x28, // DW_AT_LLVM_sysroot, DW_FORM_strx4
+			0x00, 0x00, 0x00,
+		}},
+		{"__debug_info", []byte{
+			0x1c, 0x00, 0x00, 0x00, // unit_length
+			0x05, 0x00, // version
+			0x01,                   // DW_UT_compile
+			0x08,                   // address_size
+			0x00, 0x00, 0x00, 0x00, // debug_abbrev_offset
+			0x01,                   // abbrev 1
+			0x00,                   // DW_AT_name: str_offsets[0]
+			0x08, 0x00, 0x00, 0x00, // DW_AT_str_offsets_base
+			0x00, 0x00, 0x00, 0x00, // DW_AT_comp_dir: line_str+0
+			0x01,       // DW_AT_producer: str_offsets[1]
+			0x02, 0x00, // DW_AT_description: str_offsets[2]
+			0x03, 0x00, 0x00, // DW_AT_APPLE_sdk: str_offsets[3]
+			0x04, 0x00, 0x00, 0x00, // DW_AT_LLVM_sysroot: str_offsets[4]
+		}},
+		{"__debug_str", []byte("hello.c\x00clang\x00desc\x00MacOSX.sdk\x00/\x00")},
+		{"__debug_str_offs", []byte{
+			0x18, 0x00, 0x00, 0x00, // unit_length
+			0x05, 0x00, 0x00, 0x00, // version, padding
+			0x00, 0x00, 0x00, 0x00, // str+0
+			0x08, 0x00, 0x00, 0x00, // str+8
+			0x0e, 0x00, 0x00, 0x00, // str+14
+			0x13, 0x00, 0x00, 0x00, // str+19
+			0x1e, 0x00, 0x00, 0x00, // str+30
+		}},
+		{"__debug_line_str", []byte("/tmp/src\x00")},
+	})
+
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatalf("DWARF() error = %v", err)
+	}
+	cu, err := d.Reader().Next()
+	if err != nil {
+		t.Fatalf("Reader().Next() error = %v", err)
+	}
+	for _, tt := range []struct {
+		attr dwarf.Attr
+		want string
+	}{
+		{dwarf.AttrName, "hello.c"},
+		{dwarf.AttrCompDir, "/tmp/src"},
+		{dwarf.AttrProducer, "clang"},
+		{dwarf.AttrDescription, "desc"},
+		{dwarf.Attr(0x3fef), "MacOSX.sdk"},
+		{dwarf.Attr(0x3e02), "/"},
+	} {
+		if got, _ := cu.Val(tt.attr).(string); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.attr, got, tt.want)
+		}
+	}
+}
+
+func TestDWARFObjectRelocations(t *testing.T) {
+	// the __debug_str of an object file can be both relocated and compressed
+	var zstr bytes.Buffer
+	zstr.WriteString("ZLIB")
+	binary.Write(&zstr, binary.BigEndian, uint64(len("hello.c\x00")))
+	zw := zlib.NewWriter(&zstr)
+	zw.Write([]byte("hello.c\x00"))
+	zw.Close()
+
+	f := newSectionsFile([]testSection{
+		{"__debug_abbrev", []byte{
+			0x01, 0x11, 0x00, // abbrev 1: DW_TAG_compile_unit, no children
+			0x03, 0x0e, // DW_AT_name, DW_FORM_strp
+			0x00, 0x00, 0x00,
+		}},
+		{"__debug_info", []byte{
+			0x0c, 0x00, 0x00, 0x00, // unit_length
+			0x04, 0x00, // version
+			0x00, 0x00, 0x00, 0x00, // debug_abbrev_offset
+			0x08,                   // address_size
+			0x01,                   // abbrev 1
+			0x00, 0x00, 0x00, 0x00, // DW_AT_name: str+0
+		}},
+		{"__debug_str", zstr.Bytes()},
+	})
+	f.Type = types.MH_OBJECT
+	f.CPU = types.CPUArm64
+	// a section relocation of the strp, a relocation in the compressed strings and an unsupported relocation
+	f.Sections[1].Relocs = []Reloc{{Addr: 12, Value: 3, Type: uint8(types.ARM64_RELOC_UNSIGNED), Len: 2}}
+	f.Sections[2].Relocs = []Reloc{{Addr: 12, Value: 3, Type: uint8(types.ARM64_RELOC_UNSIGNED), Len: 2}}
+	f.Sections[0].Relocs = []Reloc{{Addr: 0, Value: 1, Type: uint8(types.ARM64_RELOC_GOT_LOAD_PAGE21), Len: 2}}
+
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatalf("DWARF() error = %v", err)
+	}
+	cu, err := d.Reader().Next()
+	if err != nil {
+		t.Fatalf("Reader().Next() error = %v", err)
+	}
+	if name, _ := cu.Val(dwarf.AttrName).(string); name != "hello.c" {
+		t.Errorf("DW_AT_name = %q, want hello.c", name)
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagRelocation {
+		t.Errorf("Diagnostics() = %v, want an unsupported relocation", diags)
+	}
+}
+
+func TestAccelTables(t *testing.T) {
+	le := binary.LittleEndian
+	u32 := func(b []byte, v ...uint32) []byte {
+		for _, x := range v {
+			b = le.AppendUint32(b, x)
+		}
+		return b
+	}
+	str := []byte("\x00main\x00foo\x00")
+
+	// __apple_names: main is a function, foo a variable
+	names := u32(nil, 0x48415348)                       // magic
+	names = append(names, 1, 0, 0, 0)                   // version, hash function
+	names = u32(names, 1, 2, 16)                        // buckets, hashes, header data length
+	names = u32(names, 0, 2)                            // DIE offset base, atoms
+	names = append(names, 1, 0, 0x06, 0, 3, 0, 0x05, 0) // DW_ATOM_die_offset/data4, DW_ATOM_die_tag/data2
+	names = u32(names, 0, djbHash("main"), djbHash("foo"), 56, 74)
+	names = append(u32(names, 1, 1, 0x2a), 0x2e, 0x00)
+	names = u32(names, 0)
+	names = append(u32(names, 6, 1, 0x40), 0x34, 0x00)
+	names = u32(names, 0)
+
+	// __debug_names: main in the compile unit at 0x100
+	debugNames := u32(nil, 65)
+	debugNames = append(debugNames, 5, 0, 0, 0)       // version, padding
+	debugNames = u32(debugNames, 1, 0, 0, 1, 1, 7, 0) // CUs, local TUs, foreign TUs, buckets, names, abbrev size, augmentation size
+	debugNames = u32(debugNames, 0x100, 1, caseFoldedDJBHash("main"), 1, 0)
+	debugNames = append(debugNames, 1, 0x2e, 3, 0x13, 0, 0, 0) // abbrev 1: DW_TAG_subprogram, DW_IDX_die_offset/ref4
+	debugNames = append(u32(append(debugNames, 1), 0x2a), 0)   // entry pool
+
+	f := newSectionsFile([]testSection{
+		{"__debug_str", str},
+		{"__apple_names", names},
+		{"__debug_names", debugNames},
+	})
+	a, err := f.AccelTables()
+	if err != nil {
+		t.Fatalf("AccelTables() error = %v", err)
+	}
+
+	if offs, err := a.LookupFunction("main"); err != nil || !reflect.DeepEqual(offs, []dwarf.Offset{0x2a}) {
+		t.Errorf("LookupFunction(main) = %v, %v", offs, err)
+	}
+	if offs, err := a.LookupFunction("foo"); err != nil || len(offs) != 0 {
+		t.Errorf("LookupFunction(foo) = %v, %v, want no functions", offs, err)
+	}
+	if entries, err := a.Names.Lookup("foo"); err != nil || !reflect.DeepEqual(entries, []AccelEntry{{Offset: 0x40, Tag: dwarf.TagVariable}}) {
+		t.Errorf("Names.Lookup(foo) = %v, %v", entries, err)
+	}
+	if entries, err := a.Names.Lookup("bar"); err != nil || len(entries) != 0 {
+		t.Errorf("Names.Lookup(bar) = %v, %v", entries, err)
+	}
+	if entries, err := a.DebugNames.Lookup("main"); err != nil || !reflect.DeepEqual(entries, []AccelEntry{{Offset: 0x12a, Tag: dwarf.TagSubprogram}}) {
+		t.Errorf("DebugNames.Lookup(main) = %v, %v", entries, err)
+	}
+	// without the Apple table, LookupFunction falls back to __debug_names
+	a.Names = nil
+	if offs, err := a.LookupFunction("main"); err != nil || !reflect.DeepEqual(offs, []dwarf.Offset{0x12a}) {
+		t.Errorf("LookupFunction(main) = %v, %v", offs, err)
+	}
+	// but ObjC methods are only indexed by class in __apple_objc
+	if offs, err := a.LookupObjCMethods("NSObject"); err == nil {
+		t.Errorf("LookupObjCMethods(NSObject) = %v, want an error without __apple_objc", offs)
+	}
+
+	// an atom count larger than the header data
+	bad := u32(nil, 0x48415348)
+	bad = append(bad, 1, 0, 0, 0)
+	bad = u32(bad, 1, 1, 8, 0, 0xffffffff)
+	if _, err := NewAppleAccelTable(bad, str, le); err == nil {
+		t.Error("NewAppleAccelTable() should fail on an atom count larger than the header data")
+	}
+	// a table without atoms and a name with more entries than the data can hold
+	bad = u32(nil, 0x48415348)
+	bad = append(bad, 1, 0, 0, 0)
+	bad = u32(bad, 1, 1, 8, 0, 0)
+	bad = u32(bad, 0, djbHash("main"), 40, 1, 0xffffffff, 0)
+	tbl, err := NewAppleAccelTable(bad, str, le)
+	if err != nil {
+		t.Fatalf("NewAppleAccelTable() error = %v", err)
+	}
+	if entries, err := tbl.Lookup("main"); err == nil {
+		t.Errorf("Lookup(main) = %v, want an error on a count larger than the hash data", entries)
+	}
+}
+
+func TestSymbolicate(t *testing.T) {
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.Symbolicate(0x100000f30)
+	if err != nil {
+		t.Fatalf("Symbolicate() error = %v", err)
+	}
+	if s.Function != "start" || s.Offset != 0x1c || s.String() != "start + 28" {
+		t.Errorf("Symbolicate(0x100000f30) = %s", s)
+	}
+
+	// the binary's symtab with the DWARF of the same program from a dSYM, loaded with a slide
+	dsym, err := openObscured("internal/testdata/gcc-amd64-darwin-exec-debug.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := dsym.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = f.Symbolicate(0x100010f6e, SymbolicateConfig{LoadAddress: 0x100010000, DWARF: d})
+	if err != nil {
+		t.Fatalf("Symbolicate() error = %v", err)
+	}
+	if s.Address != 0x100000f6e || s.Function != "main" || s.Offset != 4 || len(s.Frames) != 1 ||
+		s.Frames[0].Line != 4 || !strings.HasSuffix(s.Frames[0].File, "hello.c") {
+		t.Errorf("Symbolicate(0x100010f6e) = %#x %s+%d %v", s.Address, s.Function, s.Offset, s.Frames)
+	}
+
+	// inner() inlined into outer() at a.c:12:3
+	f = newSectionsFile([]testSection{
+		{"__debug_abbrev", []byte{
+			0x01, 0x11, 0x01, 0x03, 0x08, 0x10, 0x17, 0x11, 0x01, 0x12, 0x06, 0x00, 0x00, // DW_TAG_compile_unit
+			0x02, 0x2e, 0x00, 0x03, 0x08, 0x20, 0x0b, 0x00, 0x00, // DW_TAG_subprogram (abstract)
+			0x03, 0x2e, 0x01, 0x03, 0x08, 0x11, 0x01, 0x12, 0x06, 0x00, 0x00, // DW_TAG_subprogram
+			0x04, 0x1d, 0x00, 0x31, 0x13, 0x11, 0x01, 0x12, 0x06, 0x58, 0x0b, 0x59, 0x0b, 0x57, 0x0b, 0x00, 0x00, // DW_TAG_inlined_subroutine
+			0x00,
+		}},
+		{"__debug_info", []byte{
+			0x4d, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, // DWARF 4 unit header
+			0x01, 'a', '.', 'c', 0x00, 0x00, 0x00, 0x00, 0x00, // a.c
+			0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, // [0x1000, 0x1020)
+			0x02, 'i', 'n', 'n', 'e', 'r', 0x00, 0x03, // inner, DW_INL_declared_inlined
+			0x03, 'o', 'u', 't', 'e', 'r', 0x00, // outer
+			0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, // [0x1000, 0x1020)
+			0x04, 0x20, 0x00, 0x00, 0x00, // inlined inner
+			0x04, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, // [0x1004, 0x100c)
+			0x01, 0x0c, 0x03, // called from a.c:12:3
+			0x00, 0x00,
+		}},
+		{"__debug_line", []byte{
+			0x34, 0x00, 0x00, 0x00, 0x04, 0x00, 0x1b, 0x00, 0x00, 0x00, // DWARF 4 line program header
+			0x01, 0x01, 0x01, 0xfb, 0x0e, 0x0d, 0x00, 0x01, 0x01, 0x01, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01,
+			0x00,                                        // no include directories
+			'a', '.', 'c', 0x00, 0x00, 0x00, 0x00, 0x00, // a.c
+			0x00, 0x09, 0x02, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // DW_LNE_set_address 0x1000
+			0x03, 0x04, 0x01, // line 5
+			0x02, 0x20, 0x00, 0x01, 0x01, // DW_LNE_end_sequence at 0x1020
+		}},
+	})
+	s, err = f.Symbolicate(0x1006)
+	if err != nil {
+		t.Fatalf("Symbolicate() error = %v", err)
+	}
+	want := []SymbolicatedFrame{
+		{Function: "inner", File: "a.c", Line: 5, Inlined: true},
+		{Function: "outer", File: "a.c", Line: 12, Column: 3},
+	}
+	if s.Function != "outer" || s.Offset != 6 || !reflect.DeepEqual(s.Frames, want) {
+		t.Errorf("Symbolicate(0x1006) = %s+%d %v, want outer+6 %v", s.Function, s.Offset, s.Frames, want)
+	}
+}
+
+func TestUnwindInfo(t *testing.T) {
+	f, err := openObscured("internal/testdata/clang-amd64-darwin-exec-with-rpath.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.UnwindInfo()
+	if err != nil {
+		t.Fatalf("UnwindInfo() error = %v", err)
+	}
+	if len(info.Index) != 2 || len(info.Functions) != 1 {
+		t.Fatalf("UnwindInfo() index = %v, functions = %v", info.Index, info.Functions)
+	}
+	if fn := info.Functions[0]; fn.Start != 0x100000f60 || fn.End != 0x100000f8b || fn.Encoding != 0x01000000 {
+		t.Errorf("UnwindInfo() function = %#x-%#x %#08x", fn.Start, fn.End, uint32(fn.Encoding))
+	}
+
+	funcs, err := f.GetFunctionsUnwind()
+	if err != nil {
+		t.Fatalf("GetFunctionsUnwind() error = %v", err)
+	}
+	if len(funcs) != 1 || funcs[0].Unwind == nil || !funcs[0].BoundsMatch || funcs[0].Decoded.Mode != unwind.ModeFrame {
+		t.Errorf("GetFunctionsUnwind() = %+v", funcs)
+	}
+
+	tests := []struct {
+		cpu  types.CPU
+		enc  unwind.Encoding
+		want string
+	}{
+		{types.CPUAmd64, 0x0101000a, "frame offset=1 regs=r12,rbx"},
+		{types.CPUAmd64, 0x02020400, "frameless stack=16 regs=rbx"},
+		{types.CPUAmd64, 0x0306a800, "frameless stack=[func+0x6]+40 regs=rbx,r12"},
+		{types.CPUAmd64, 0x04000123, "dwarf eh_frame+0x123"},
+		{types.CPUArm64, 0x04000101, "frame regs=x19,x20,d8,d9"},
+		{types.CPUArm64, 0x02003000, "frameless stack=48"},
+	}
+	for _, tt := range tests {
+		u, err := tt.enc.Decode(tt.cpu)
+		if err != nil {
+			t.Fatalf("Decode(%s) error = %v", tt.cpu, err)
+		}
+		if got := u.String(); got != tt.want {
+			t.Errorf("Encoding(%#08x).Decode(%s) = %q, want %q", uint32(tt.enc), tt.cpu, got, tt.want)
+		}
+	}
+
+	if u, _ := unwind.Encoding(0x04000101).Decode(types.CPUArm64); !reflect.DeepEqual(u.SavedAt, []int64{-24, -32, -40, -48}) {
+		t.Errorf("Decode() SavedAt = %v, want [-24 -32 -40 -48]", u.SavedAt)
+	}
+	if u, _ := unwind.Encoding(0x02020400).Decode(types.CPUAmd64); !reflect.DeepEqual(u.SavedAt, []int64{-16}) {
+		t.Errorf("Decode() SavedAt = %v, want [-16]", u.SavedAt)
+	}
+}
+
+func TestEHFrame(t *testing.T) {
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eh, err := f.EHFrame()
+	if err != nil {
+		t.Fatalf("EHFrame() error = %v", err)
+	}
+	if len(eh.CIEs) != 1 || eh.CIEs[0].Augmentation != "zR" || eh.CIEs[0].DataAlign != -8 || eh.CIEs[0].ReturnAddressRegister != 16 {
+		t.Fatalf("EHFrame() CIEs = %+v", eh.CIEs)
+	}
+	fde, ok := eh.Lookup(0x100000f70)
+	if !ok || fde.PCBegin != 0x100000f6a || fde.PCEnd != 0x100000f81 {
+		t.Fatalf("EHFrame().Lookup(0x100000f70) = %+v", fde)
+	}
+	if at, ok := eh.FDEAt(0x18); !ok || at != fde {
+		t.Errorf("EHFrame().FDEAt(0x18) = %+v", at)
+	}
+	rows, err := fde.Rows()
+	if err != nil {
+		t.Fatalf("Rows() error = %v", err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, row.String())
+	}
+	want := []string{
+		"0x100000f6a: CFA=r7+8 r16=[cfa-8]",
+		"0x100000f6b: CFA=r7+16 r6=[cfa-16] r16=[cfa-8]",
+		"0x100000f6e: CFA=r6+16 r6=[cfa-16] r16=[cfa-8]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rows() = %q, want %q", got, want)
+	}
+
+	// zPLR CIE with an indirect personality and an FDE with an LSDA
+	le := binary.LittleEndian
+	dat := le.AppendUint32(nil, 28)
+	dat = le.AppendUint32(dat, 0)
+	dat = append(dat, 1, 'z', 'P', 'L', 'R', 0, 1, 0x78, 0x10, 7, 0x9b)
+	dat = le.AppendUint32(dat, 0x2000-0x1013)
+	dat = append(dat, 0x1b, 0x1b, 0x0c, 0x07, 0x08, 0x90, 0x01, 0, 0)
+	dat = le.AppendUint32(dat, 28)
+	dat = le.AppendUint32(dat, 36)
+	dat = le.AppendUint32(dat, 0x3000-0x1028)
+	dat = le.AppendUint32(dat, 0x20)
+	dat = append(dat, 4)
+	dat = le.AppendUint32(dat, 0x4000-0x1031)
+	dat = append(dat, 0x41, 0x0e, 0x10, 0x0a, 0x42, 0x0e, 0x20, 0x43, 0x0b, 0x00, 0x00)
+	dat = le.AppendUint32(dat, 0)
+	eh, err = unwind.ParseEHFrame(dat, le, unwind.EHFrameConfig{
+		Addr: 0x1000,
+		ReadPointer: func(addr uint64) (uint64, error) {
+			if addr != 0x2000 {
+				return 0, fmt.Errorf("unexpected address %#x", addr)
+			}
+			return 0x5000, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ParseEHFrame() error = %v", err)
+	}
+	if cie := eh.CIEs[0]; cie.Personality != 0x5000 || cie.PersonalityIndirect {
+		t.Errorf("ParseEHFrame() personality = %#x", cie.Personality)
+	}
+	if len(eh.FDEs) != 1 || eh.FDEs[0].PCBegin != 0x3000 || eh.FDEs[0].PCEnd != 0x3020 || eh.FDEs[0].LSDA != 0x4000 {
+		t.Fatalf("ParseEHFrame() FDEs = %+v", eh.FDEs)
+	}
+	row, err := eh.FDEs[0].RowAt(0x3007)
+	if err != nil {
+		t.Fatalf("RowAt() error = %v", err)
+	}
+	if row.String() != "0x3006: CFA=r7+16 r16=[cfa-8]" {
+		t.Errorf("RowAt(0x3007) = %s", row)
+	}
+
+	// an FDE pointing into the instructions of a CIE at a 64-bit length past the end of the section
+	dat = le.AppendUint32(nil, 28)
+	dat = le.AppendUint32(dat, 0)
+	dat = append(dat, 1, 0, 1, 0x78, 0x10)
+	dat = le.AppendUint32(dat, 0xffffffff)
+	dat = le.AppendUint64(dat, 0x7fffffffffffffff)
+	dat = le.AppendUint32(dat, 0)
+	dat = append(dat, 0, 0, 0)
+	dat = le.AppendUint32(dat, 20)
+	dat = le.AppendUint32(dat, 36-13)
+	dat = le.AppendUint64(dat, 0x3000)
+	dat = le.AppendUint64(dat, 0x20)
+	dat = le.AppendUint32(dat, 0)
+	if _, err := unwind.ParseEHFrame(dat, le, unwind.EHFrameConfig{}); err == nil {
+		t.Error("ParseEHFrame() should fail on a CIE extending past the end of __eh_frame")
+	}
+}
+
+func TestWriteBreakpadSymbols(t *testing.T) {
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsym, err := openObscured("internal/testdata/gcc-amd64-darwin-exec-debug.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := dsym.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.WriteBreakpadSymbols(&buf, BreakpadConfig{Name: "hello", DWARF: d}); err != nil {
+		t.Fatalf("WriteBreakpadSymbols() error = %v", err)
+	}
+	want := `MODULE mac x86_64 3B24B8720E4576D428AAEE89B0C1215D0 hello
+INFO CODE_ID 3B24B8720E4576D428AAEE89B0C1215D
+FILE 0 /home/rsc/go/src/pkg/debug/macho/testdata/hello.c
+FUNC f6a 17 0 main
+f6a 4 3 0
+f6e c 4 0
+f7a 5 5 0
+f7f 2 6 0
+PUBLIC f14 0 start
+PUBLIC f50 0 dyld_stub_binding_helper
+PUBLIC f64 0 _dyld_func_lookup
+STACK CFI INIT f6a 17 .cfa: $rsp 8 + .ra: .cfa -8 + ^
+STACK CFI f6b .cfa: $rsp 16 + $rbp: .cfa -16 + ^
+STACK CFI f6e .cfa: $rbp 16 +
+`
+	if buf.String() != want {
+		t.Errorf("WriteBreakpadSymbols() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// STACK CFI from __unwind_info
+	f, err = openObscured("internal/testdata/clang-amd64-darwin-exec-with-rpath.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := f.WriteBreakpadSymbols(&buf, BreakpadConfig{Name: "rpath"}); err != nil {
+		t.Fatalf("WriteBreakpadSymbols() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "\nSTACK CFI INIT f60 2b .cfa: $rbp 16 + .ra: .cfa -8 + ^ $rbp: .cfa -16 + ^\n") {
+		t.Errorf("WriteBreakpadSymbols() =\n%s", buf.String())
+	}
+	if err := f.WriteBreakpadSymbols(&buf); err == nil {
+		t.Errorf("WriteBreakpadSymbols() of an executable without a module name should fail")
+	}
+
+	// an opened executable is named after its file, like dump_syms does
+	b, err := obscuretestdata.ReadFile("internal/testdata/clang-amd64-darwin-exec-with-rpath.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "rpath")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf.Reset()
+	if err := f.WriteBreakpadSymbols(&buf); err != nil {
+		t.Fatalf("WriteBreakpadSymbols() error = %v", err)
+	}
+	if line, _, _ := strings.Cut(buf.String(), "\n"); !strings.HasPrefix(line, "MODULE mac x86_64 ") || !strings.HasSuffix(line, " rpath") {
+		t.Errorf("WriteBreakpadSymbols() MODULE = %q, want the module named rpath", line)
+	}
+}
+
+func TestResolveChainedFixups(t *testing.T) {
+	// arm64e chained pointers at the start of __DATA
+	raws := []uint64{
+		1<<51 | 0x100003f80, // rebase to 0x100003f80
+		1<<63 | 1<<51 | 2<<49 | 1<<48 | 0x1234<<32 | 0x3f90, // auth rebase (DA, addrDiv) to base+0x3f90
+		1<<62 | 1<<51 | 8<<32 | 1,                           // bind _printf+8
+		1<<63 | 1<<62 | 0x5678<<32,                          // auth bind _objc_msgSend (IA)
+	}
+	blob := make([]byte, 0x5000)
+	f := &File{}
+	f.ByteOrder = binary.LittleEndian
+	f.Loads = []Load{
+		&Segment{SegmentHeader: SegmentHeader{Name: "__TEXT", Addr: 0x100000000, Memsz: 0x4000, Filesz: 0x4000}},
+		&Segment{SegmentHeader: SegmentHeader{Name: "__DATA", Addr: 0x100004000, Memsz: 0x1000, Offset: 0x4000, Filesz: 0x1000}},
+		&DyldChainedFixups{},
+	}
+	start := fixupchains.DyldChainedStarts{}
+	start.PointerFormat = fixupchains.DYLD_CHAINED_PTR_ARM64E
+	for i, raw := range raws {
+		off := uint64(0x4000 + 8*i)
+		binary.LittleEndian.PutUint64(blob[off:], raw)
+		switch {
+		case fixupchains.DcpArm64eIsBind(raw) && fixupchains.DcpArm64eIsAuth(raw):
+			start.Fixups = append(start.Fixups, fixupchains.DyldChainedPtrArm64eAuthBind{Fixup: off, Pointer: raw})
+		case fixupchains.DcpArm64eIsBind(raw):
+			start.Fixups = append(start.Fixups, fixupchains.DyldChainedPtrArm64eBind{Fixup: off, Pointer: raw})
+		case fixupchains.DcpArm64eIsAuth(raw):
+			start.Fixups = append(start.Fixups, fixupchains.DyldChainedPtrArm64eAuthRebase{Fixup: off, Pointer: raw})
+		default:
+			start.Fixups = append(start.Fixups, fixupchains.DyldChainedPtrArm64eRebase{Fixup: off, Pointer: raw})
+		}
+	}
+	f.dcf = &fixupchains.DyldChainedFixups{
+		Starts: []fixupchains.DyldChainedStarts{{}, start},
+		Imports: []fixupchains.DcfImport{
+			{Name: "_objc_msgSend", Import: fixupchains.DyldChainedImportAddend{}},
+			{Name: "_printf", Import: fixupchains.DyldChainedImportAddend{AddendVal: 4}},
+		},
+	}
+	f.vma = &types.VMAddrConverter{
+		Converter:    f.convertToVMAddr,
+		VMAddr2Offet: f.getOffset,
+		Offet2VMAddr: f.getVMAddress,
+	}
+	f.cr = types.NewCustomSectionReader(bytes.NewReader(blob), f.vma, 0, int64(len(blob)))
+
+	for addr, want := range map[uint64]uint64{
+		0x100004000: 0x100003f80,
+		0x100004008: 0x100003f90,
+		0x100004010: raws[2], // binds are left for GetBindName
+	} {
+		if got, err := f.GetPointerAtAddress(addr); err != nil || got != want {
+			t.Errorf("GetPointerAtAddress(%#x) = %#x, %v, want %#x", addr, got, err, want)
+		}
+	}
+	if name, err := f.GetBindName(raws[2]); err != nil || name != "_printf" {
+		t.Errorf("GetBindName() = %s, %v, want _printf", name, err)
+	}
+
+	rf, err := f.ResolveChainedFixup(0x100004008)
+	if err != nil {
+		t.Fatalf("ResolveChainedFixup() error = %v", err)
+	}
+	if rf.Bind || !rf.Auth || rf.KeyName() != "DA" || !rf.AddrDiv || rf.Diversity != 0x1234 || rf.Target != 0x100003f90 {
+		t.Errorf("ResolveChainedFixup(0x100004008) = %+v", rf)
+	}
+	if rf, err = f.ResolveChainedFixup(0x100004010); err != nil || rf.String() != "0x00004010: bind _printf + 0xc" {
+		t.Errorf("ResolveChainedFixup(0x100004010) = %v, %v", rf, err)
+	}
+	if rf, err = f.ResolveChainedFixup(0x100004018); err != nil || rf.String() != "0x00004018: bind _objc_msgSend (key: IA, addrDiv: false, diversity: 0x5678)" {
+		t.Errorf("ResolveChainedFixup(0x100004018) = %v, %v", rf, err)
+	}
+	if _, err := f.ResolveChainedFixup(0x100004020); err == nil {
+		t.Error("ResolveChainedFixup(0x100004020) should fail without a fixup")
+	}
+
+	// targets of the other pointer formats
+	dcf := &fixupchains.DyldChainedFixups{Starts: make([]fixupchains.DyldChainedStarts, 3)}
+	dcf.Starts[0].PointerFormat = fixupchains.DYLD_CHAINED_PTR_64_OFFSET
+	dcf.Starts[0].Fixups = []fixupchains.Fixup{fixupchains.DyldChainedPtr64RebaseOffset{Fixup: 0x10, Pointer: 0x80<<36 | 0x3000}}
+	dcf.Starts[1].PointerFormat = fixupchains.DYLD_CHAINED_PTR_32
+	dcf.Starts[1].MaxValidPointer = 0x100000
+	dcf.Starts[1].Fixups = []fixupchains.Fixup{
+		fixupchains.DyldChainedPtr32Rebase{Fixup: 0x20, Pointer: 0x3000},
+		fixupchains.DyldChainedPtr32Rebase{Fixup: 0x24, Pointer: 0x2080010}, // non-pointer 0x10
+	}
+	dcf.Starts[2].PointerFormat = fixupchains.DYLD_CHAINED_PTR_64_KERNEL_CACHE
+	dcf.Starts[2].Fixups = []fixupchains.Fixup{fixupchains.DyldChainedPtr64KernelCacheRebase{Fixup: 0x30, Pointer: 1<<63 | 1<<49 | 0x2000}}
+	r := fixupchains.NewResolver(dcf, 0xfffffff007004000)
+	for loc, want := range map[uint64]uint64{
+		0x10: 0x8000000000000000 | 0xfffffff007007000,
+		0x20: 0x3000,
+		0x24: 0x10,
+		0x30: 0xfffffff007006000,
+	} {
+		if rf, ok := r.Resolve(loc); !ok || rf.Target != want {
+			t.Errorf("Resolve(%#x) = %+v, want target %#x", loc, rf, want)
+		}
+	}
+	if rf, _ := r.Resolve(0x30); !rf.Auth || rf.KeyName() != "IB" {
+		t.Errorf("Resolve(0x30) = %+v, want an IB authenticated pointer", rf)
+	}
+	if fixups := r.Fixups(); len(fixups) != 4 || fixups[0].Fixup != 0x10 || fixups[3].Fixup != 0x30 {
+		t.Errorf("Fixups() = %v", fixups)
+	}
+}
+
+func TestGetRelocations(t *testing.T) {
+	tests := []struct {
+		file   string
+		relocs []string
+	}{
+		{"internal/testdata/clang-amd64-darwin.obj.base64", []string{
+			"0x0000000000000019 X86_64_RELOC_BRANCH            pcrel _printf",
+			"0x000000000000000b X86_64_RELOC_SIGNED            pcrel __TEXT.__cstring + 0x1b",
+			"0x0000000000000038 X86_64_RELOC_UNSIGNED          __TEXT.__text",
+		}},
+		{"internal/testdata/clang-386-darwin.obj.base64", []string{
+			"0x000000000000001d GENERIC_RELOC_VANILLA          pcrel _printf - 0x21",
+			"0x000000000000000e GENERIC_RELOC_LOCAL_SECTDIFF   0x2d (__TEXT.__cstring) - 0xb (__TEXT.__text) + 0x22",
+		}},
+	}
+	for _, tt := range tests {
+		f, err := openObscured(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, sec := range f.Sections {
+			relocs, err := f.GetRelocations(sec)
+			if err != nil {
+				t.Fatalf("GetRelocations(%s) error = %v", sec.Name, err)
+			}
+			for _, r := range relocs {
+				got = append(got, r.String())
+			}
+		}
+		if !reflect.DeepEqual(got, tt.relocs) {
+			t.Errorf("%s: GetRelocations() = %q, want %q", tt.file, got, tt.relocs)
+		}
+	}
+}
+
+func TestGetRelocationsArm64(t *testing.T) {
+	// an arm64 object with an adrp/add of _foo+0x10, a call to _printf, a pointer to _foo+8 and _bar-_foo+4
+	var blob bytes.Buffer
+	binary.Write(&blob, binary.LittleEndian, []uint32{0x90000000, 0x91000000, 0x94000000, 0xd65f03c0})
+	binary.Write(&blob, binary.LittleEndian, []uint64{8, 4})
+	f := &File{Symtab: &Symtab{Syms: []Symbol{
+		{Name: "_foo", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0x0},
+		{Name: "_bar", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0xc},
+		{Name: "_printf", Type: types.N_UNDF | types.N_EXT},
+	}}}
+	f.ByteOrder = binary.LittleEndian
+	f.CPU = types.CPUArm64
+	f.Type = types.MH_OBJECT
+	f.Sections = []*Section{
+		{SectionHeader: SectionHeader{Name: "__text", Seg: "__TEXT", Addr: 0x0, Size: 0x10, Offset: 0x0}, Relocs: []Reloc{
+			{Addr: 0x8, Value: 2, Type: uint8(types.ARM64_RELOC_BRANCH26), Len: 2, Pcrel: true, Extern: true},
+			{Addr: 0x4, Value: 0x10, Type: uint8(types.ARM64_RELOC_ADDEND), Len: 2},
+			{Addr: 0x4, Value: 0, Type: uint8(types.ARM64_RELOC_PAGEOFF12), Len: 2, Extern: true},
+			{Addr: 0x0, Value: 0x10, Type: uint8(types.ARM64_RELOC_ADDEND), Len: 2},
+			{Addr: 0x0, Value: 0, Type: uint8(types.ARM64_RELOC_PAGE21), Len: 2, Pcrel: true, Extern: true},
+		}},
+		{SectionHeader: SectionHeader{Name: "__data", Seg: "__DATA", Addr: 0x10, Size: 0x10, Offset: 0x10}, Relocs: []Reloc{
+			{Addr: 0x8, Value: 0, Type: uint8(types.ARM64_RELOC_SUBTRACTOR), Len: 3, Extern: true},
+			{Addr: 0x8, Value: 1, Type: uint8(types.ARM64_RELOC_UNSIGNED), Len: 3, Extern: true},
+			{Addr: 0x0, Value: 0, Type: uint8(types.ARM64_RELOC_UNSIGNED), Len: 3, Extern: true},
+		}},
+	}
+	for _, sec := range f.Sections {
+		sec.ReaderAt = bytes.NewReader(blob.Bytes())
+	}
+
+	want := []string{
+		"0x0000000000000008 ARM64_RELOC_BRANCH26           pcrel _printf",
+		"0x0000000000000004 ARM64_RELOC_PAGEOFF12          _foo + 0x10",
+		"0x0000000000000000 ARM64_RELOC_PAGE21             pcrel _foo + 0x10",
+		"0x0000000000000018 ARM64_RELOC_SUBTRACTOR         _bar - _foo + 0x4",
+		"0x0000000000000010 ARM64_RELOC_UNSIGNED           _foo + 0x8",
+	}
+	var got []string
+	for _, sec := range f.Sections {
+		relocs, err := f.GetRelocations(sec)
+		if err != nil {
+			t.Fatalf("GetRelocations(%s) error = %v", sec.Name, err)
+		}
+		for _, r := range relocs {
+			got = append(got, r.String())
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRelocations() = %q, want %q", got, want)
+	}
+
+	layout := SectionLayout{f.Sections[0]: 0x1000, f.Sections[1]: 0x2000}
+	dat, err := f.GetRelocatedSectionData(f.Sections[1], layout)
+	if err != nil {
+		t.Fatalf("GetRelocatedSectionData(__data) error = %v", err)
+	}
+	if got := binary.LittleEndian.Uint64(dat); got != 0x1008 {
+		t.Errorf("GetRelocatedSectionData(__data) pointer = %#x, want %#x", got, 0x1008)
+	}
+	if got := binary.LittleEndian.Uint64(dat[8:]); got != 0x10 {
+		t.Errorf("GetRelocatedSectionData(__data) difference = %#x, want %#x", got, 0x10)
+	}
+	dat, err = f.GetRelocatedSectionData(f.Sections[0], layout)
+	if err != nil {
+		t.Fatalf("GetRelocatedSectionData(__text) error = %v", err)
+	}
+	// add x0, x0, #0x10
+	if got := binary.LittleEndian.Uint32(dat[4:]); got != 0x91004000 {
+		t.Errorf("GetRelocatedSectionData(__text) add = %#x, want %#x", got, 0x91004000)
+	}
+}
+
+func TestGetRelocatedSectionData(t *testing.T) {
+	f, err := openObscured("internal/testdata/clang-amd64-darwin.obj.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := f.Section("__TEXT", "__text")
+	unwind := f.Section("__LD", "__compact_unwind")
+	if text == nil || unwind == nil {
+		t.Fatal("Section() error = __TEXT.__text or __LD.__compact_unwind not found")
+	}
+	layout := SectionLayout{text: 0x1000}
+
+	dat, err := f.GetRelocatedSectionData(unwind, layout)
+	if err != nil {
+		t.Fatalf("GetRelocatedSectionData(__compact_unwind) error = %v", err)
+	}
+	if got := binary.LittleEndian.Uint64(dat); got != 0x1000 {
+		t.Errorf("GetRelocatedSectionData(__compact_unwind) function address = %#x, want %#x", got, 0x1000)
+	}
+
+	dat, err = f.GetRelocatedSectionData(text, layout)
+	if err != nil {
+		t.Fatalf("GetRelocatedSectionData(__text) error = %v", err)
+	}
+	// lea rdi, [rip+disp] to __cstring at 0x2a
+	if got := int32(binary.LittleEndian.Uint32(dat[0xb:])); got != 0x2a-(0x1000+0xb+4) {
+		t.Errorf("GetRelocatedSectionData(__text) lea displacement = %#x, want %#x", got, 0x2a-(0x1000+0xb+4))
+	}
+	// call _printf (undefined, resolves to 0)
+	if got := int32(binary.LittleEndian.Uint32(dat[0x19:])); got != -(0x1000 + 0x19 + 4) {
+		t.Errorf("GetRelocatedSectionData(__text) call displacement = %#x, want %#x", got, -(0x1000 + 0x19 + 4))
+	}
+}
+
+// newCoreFile returns a synthetic arm64 MH_CORE with one segment and the LC_NOTEs written by lldb.
+func newCoreFile(t *testing.T) *File {
+	t.Helper()
+	bo := binary.LittleEndian
+	mainUUID := types.UUID{0x3b, 0x24, 0xb8, 0x72, 0x0e, 0x45, 0x76, 0xd4, 0x28, 0xaa, 0xee, 0x89, 0xb0, 0xc1, 0x21, 0x5d}
+	dyldUUID := types.UUID{0x9e, 0xac, 0xb8, 0xa7, 0x7b, 0x5c, 0x3c, 0x73, 0xb4, 0xe5, 0xde, 0x0b, 0x4f, 0x8f, 0x1f, 0x7e}
+
+	// note payloads
+	payloads := map[string][]byte{}
+	put := func(owner string, v interface{}) {
+		var buf bytes.Buffer
+		binary.Write(&buf, bo, v)
+		payloads[owner] = append(payloads[owner], buf.Bytes()...)
+	}
+	put(NoteAddrableBits, []uint32{4, 39, 49})
+	put(NoteMainBinSpec, struct {
+		Version, Type uint32
+		Address       uint64
+		Slide         uint64
+		UUID          types.UUID
+		Log2PageSize  uint32
+		Platform      types.Platform
+	}{2, uint32(MainBinUser), 0x100000000, 0, mainUUID, 14, types.Platform(1)})
+	put(NoteKernVerStr, uint32(1))
+	payloads[NoteKernVerStr] = append(payloads[NoteKernVerStr], "Darwin Kernel Version 23.0.0\x00"...)
+	payloads[NoteProcessMetadata] = []byte(`{"threads":[{"thread_id":42},{"thread_id":43}]}` + "\x00")
+
+	const (
+		notesOff   = 0x200
+		entriesOff = 0x400
+		entrySize  = 56 // larger than the 48 byte image_entry to check that it is used as the stride
+		stringsOff = 0x500
+		segsOff    = 0x600
+		dataOff    = 0x1000
+	)
+	blob := make([]byte, dataOff+0x1000)
+	copy(blob[stringsOff:], "/tmp/a.out\x00/usr/lib/dyld\x00")
+	var seg bytes.Buffer
+	binary.Write(&seg, bo, struct {
+		Name           [16]byte
+		VMAddr, Unused uint64
+	}{[16]byte{'_', '_', 'T', 'E', 'X', 'T'}, 0x180000000, 0})
+	copy(blob[segsOff:], seg.Bytes())
+	for i, e := range []loadBinariesEntry{
+		{FilePathOffset: stringsOff, UUID: mainUUID, LoadAddress: 0x100000000, SegAddrsOffset: CoreAddressUnknown},
+		{FilePathOffset: stringsOff + 11, UUID: dyldUUID, LoadAddress: 0x180000000, SegAddrsOffset: segsOff, SegmentCount: 1},
+	} {
+		var buf bytes.Buffer
+		binary.Write(&buf, bo, e)
+		copy(blob[entriesOff+i*entrySize:], buf.Bytes())
+	}
+	put(NoteLoadBinaries, loadBinariesHdr{Version: 1, ImageCount: 2, EntriesFileOff: entriesOff, EntriesSize: entrySize})
+	bo.PutUint64(blob[dataOff:], 0x1122334455667788)
+
+	// load commands
+	var cmds bytes.Buffer
+	binary.Write(&cmds, bo, types.Segment64{
+		LoadCmd: types.LC_SEGMENT_64, Len: 72, Name: [16]byte{'_', '_', 'D', 'A', 'T', 'A'},
+		Addr: 0x100000000, Memsz: 0x2000, Offset: dataOff, Filesz: 0x1000,
+	})
+	off := uint64(notesOff)
+	owners := []string{NoteAddrableBits, NoteMainBinSpec, NoteLoadBinaries, NoteKernVerStr, NoteProcessMetadata}
+	for _, owner := range owners {
+		n := types.NoteCmd{LoadCmd: types.LC_NOTE, Len: 40, Offset: off, Size: uint64(len(payloads[owner]))}
+		copy(n.DataOwner[:], owner)
+		binary.Write(&cmds, bo, n)
+		copy(blob[off:], payloads[owner])
+		off += uint64(len(payloads[owner]))
+	}
+	hdr := types.FileHeader{Magic: types.Magic64, CPU: types.CPUArm64, Type: types.MH_CORE, NCommands: uint32(1 + len(owners)), SizeCommands: uint32(cmds.Len())}
+	var buf bytes.Buffer
+	binary.Write(&buf, bo, hdr)
+	buf.Write(cmds.Bytes())
+	if buf.Len() > notesOff {
+		t.Fatalf("core load commands overlap the notes")
+	}
+	copy(blob, buf.Bytes())
+
+	f, err := NewFile(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	return f
+}
+
+func TestCoreNotes(t *testing.T) {
+	f := newCoreFile(t)
+	if len(f.Notes()) != 5 {
+		t.Fatalf("Notes() = %d, want 5", len(f.Notes()))
+	}
+
+	ab, err := f.GetAddrableBits()
+	if err != nil {
+		t.Fatalf("GetAddrableBits() error = %v", err)
+	}
+	if ab.LowBits != 39 || ab.HighBits != 49 || ab.Mask() != 1<<39-1 {
+		t.Errorf("GetAddrableBits() = %s", ab)
+	}
+
+	mbs, err := f.GetMainBinSpec()
+	if err != nil {
+		t.Fatalf("GetMainBinSpec() error = %v", err)
+	}
+	if mbs.Version != 2 || mbs.Type != MainBinUser || mbs.Address != 0x100000000 || mbs.Slide != 0 || mbs.Log2PageSize != 14 {
+		t.Errorf("GetMainBinSpec() = %s", mbs)
+	}
+
+	images, err := f.GetLoadBinaries()
+	if err != nil {
+		t.Fatalf("GetLoadBinaries() error = %v", err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("GetLoadBinaries() = %v, want 2 images", images)
+	}
+	if images[0].Name != "/tmp/a.out" || images[0].LoadAddress != 0x100000000 || images[0].Segments != nil {
+		t.Errorf("GetLoadBinaries() image 0 = %+v", images[0])
+	}
+	want := []CoreImageSegment{{Name: "__TEXT", Addr: 0x180000000}}
+	if images[1].Name != "/usr/lib/dyld" || images[1].LoadAddress != 0x180000000 || !reflect.DeepEqual(images[1].Segments, want) {
+		t.Errorf("GetLoadBinaries() image 1 = %+v", images[1])
+	}
+
+	images, err = f.CoreImages()
+	if err != nil {
+		t.Fatalf("CoreImages() error = %v", err)
+	}
+	if len(images) != 2 || !images[0].Main || images[1].Main || images[0].UUID != mbs.UUID {
+		t.Errorf("CoreImages() = %+v", images)
+	}
+
+	if kv, err := f.GetKernelVersion(); err != nil || kv != "Darwin Kernel Version 23.0.0" {
+		t.Errorf("GetKernelVersion() = %q, %v", kv, err)
+	}
+
+	pm, err := f.GetProcessMetadata()
+	if err != nil {
+		t.Fatalf("GetProcessMetadata() error = %v", err)
+	}
+	if len(pm.Threads) != 2 || pm.Threads[1].ThreadID != 43 {
+		t.Errorf("GetProcessMetadata() = %+v", pm)
+	}
+
+	vmr := f.VMReader()
+	if ptr, err := vmr.GetPointerAtAddress(0x100000000); err != nil || ptr != 0x1122334455667788 {
+		t.Errorf("VMReader.GetPointerAtAddress(0x100000000) = %#x, %v", ptr, err)
+	}
+	// memory past the file size reads as zeros
+	if ptr, err := vmr.GetPointerAtAddress(0x100001ff8); err != nil || ptr != 0 {
+		t.Errorf("VMReader.GetPointerAtAddress(0x100001ff8) = %#x, %v", ptr, err)
+	}
+
+	// corrupt sizes and counts fail without allocating them
+	if _, err := f.GetNoteData(&Note{Offset: 0x200, Size: 1 << 62}); err == nil {
+		t.Error("GetNoteData() should fail on a note extending past the end of the file")
+	}
+	blob, err := io.ReadAll(io.NewSectionReader(f.sr, 0, 0x2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(blob[0x400+56+40:], 0xffffffff) // dyld's segment count
+	if f, err = NewFile(bytes.NewReader(blob)); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	if _, err := f.GetLoadBinaries(); err == nil {
+		t.Error("GetLoadBinaries() should fail on a segment count past the end of the file")
+	}
+}
+
+func TestVMReader(t *testing.T) {
+	f, err := openObscured("internal/testdata/gcc-amd64-darwin-exec.base64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := f.Section("__TEXT", "__cstring")
+	if sec == nil {
+		t.Fatal("Section() error = section __TEXT.__cstring not found")
+	}
+	want, err := f.GetCString(sec.Addr)
+	if err != nil {
+		t.Fatalf("GetCString() error = %v", err)
+	}
+	vmr := f.VMReader()
+	got, err := vmr.GetCString(sec.Addr)
+	if err != nil {
+		t.Fatalf("VMReader.GetCString() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("VMReader.GetCString() = %q, want %q", got, want)
+	}
+
+	// __PAGEZERO has no file data and reads as zeros
+	ptr, err := vmr.GetPointerAtAddress(0x1000)
+	if err != nil {
+		t.Fatalf("VMReader.GetPointerAtAddress() error = %v", err)
+	}
+	if ptr != 0 {
+		t.Errorf("VMReader.GetPointerAtAddress() = %#x, want 0", ptr)
+	}
+}
+
+func TestParseSplitInfo(t *testing.T) {
+	// kind 2: offsets 0x10, 0x18; kind 1: offset 0x100
+	v1, err := parseSplitInfoV1(bytes.NewReader([]byte{0x02, 0x10, 0x08, 0x00, 0x01, 0x80, 0x02, 0x00, 0x00}))
+	if err != nil {
+		t.Fatalf("parseSplitInfoV1() error = %v", err)
+	}
+	want := []SplitInfoEntry{
+		{Kind: 2, FromOffset: 0x10, v1: true},
+		{Kind: 2, FromOffset: 0x18, v1: true},
+		{Kind: 1, FromOffset: 0x100, v1: true},
+	}
+	if !reflect.DeepEqual(v1, want) {
+		t.Errorf("parseSplitInfoV1() = %v, want %v", v1, want)
+	}
+
+	// 1 section pair 1->3, to offset 0x20 referenced by two arm64_adrp at 0x4 and 0xc
+	v2, err := parseSplitInfoV2(bytes.NewReader([]byte{0x01, 0x01, 0x03, 0x01, 0x20, 0x01, 0x05, 0x02, 0x04, 0x08}))
+	if err != nil {
+		t.Fatalf("parseSplitInfoV2() error = %v", err)
+	}
+	want = []SplitInfoEntry{
+		{Kind: types.DYLD_CACHE_ADJ_V2_ARM64_ADRP, FromSection: 1, FromOffset: 0x4, ToSection: 3, ToOffset: 0x20},
+		{Kind: types.DYLD_CACHE_ADJ_V2_ARM64_ADRP, FromSection: 1, FromOffset: 0xc, ToSection: 3, ToOffset: 0x20},
+	}
+	if !reflect.DeepEqual(v2, want) {
+		t.Errorf("parseSplitInfoV2() = %v, want %v", v2, want)
+	}
+
+	for _, dat := range [][]byte{
+		{0x01, 0x01, 0x03, 0x01, 0x20, 0x01, 0x05, 0x02, 0x04},             // truncated delta
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},       // huge section count
+		{0x01, 0x01, 0x03, 0x01, 0x20, 0x01, 0x05, 0xff, 0xff, 0xff, 0x0f}, // huge delta count
+		{0x01, 0x01},
+	} {
+		if _, err := parseSplitInfoV2(bytes.NewReader(dat)); err == nil {
+			t.Errorf("parseSplitInfoV2(% x) should fail", dat)
+		}
+	}
+}
+
+func TestParseLinkerOptimizationHints(t *testing.T) {
+	hints, err := parseLinkerOptimizationHints([]byte{0x07, 0x02, 0x10, 0x14, 0x03, 0x03, 0x20, 0x24, 0x28, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatalf("parseLinkerOptimizationHints() error = %v", err)
+	}
+	want := []LOH{
+		{Kind: types.LOH_ARM64_ADRP_ADD, Addresses: []uint64{0x10, 0x14}},
+		{Kind: types.LOH_ARM64_ADRP_ADD_LDR, Addresses: []uint64{0x20, 0x24, 0x28}},
+	}
+	if !reflect.DeepEqual(hints, want) {
+		t.Errorf("parseLinkerOptimizationHints() = %v, want %v", hints, want)
+	}
+	if !hints[1].Within(0x20, 0x2c) || hints[1].Within(0x20, 0x28) {
+		t.Errorf("LOH.Within() returned wrong result for %v", hints[1])
+	}
+
+	// an object file has no function starts, its functions come from the symtab
+	f := &File{Symtab: &Symtab{Syms: []Symbol{
+		{Name: "ltmp0", Type: types.N_SECT, Sect: 1, Value: 0x0},
+		{Name: "_foo", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0x0},
+		{Name: "_bar", Type: types.N_SECT | types.N_EXT, Sect: 1, Value: 0x20},
+		{Name: "_data", Type: types.N_SECT | types.N_EXT, Sect: 2, Value: 0x40},
+		{Name: "_printf", Type: types.N_UNDF | types.N_EXT},
+	}}}
+	f.Type = types.MH_OBJECT
+	f.Sections = []*Section{
+		{SectionHeader: SectionHeader{Name: "__text", Seg: "__TEXT", Addr: 0x0, Size: 0x30, Flags: types.PURE_INSTRUCTIONS}},
+		{SectionHeader: SectionHeader{Name: "__data", Seg: "__DATA", Addr: 0x40, Size: 0x8}},
+	}
+	f.Loads = []Load{&LinkerOptimizationHint{Hints: hints}}
+	funcs := f.GetObjectFunctions()
+	wantFuncs := []types.Function{{Name: "_foo", StartAddr: 0x0, EndAddr: 0x20}, {Name: "_bar", StartAddr: 0x20, EndAddr: 0x30}}
+	if !reflect.DeepEqual(funcs, wantFuncs) {
+		t.Errorf("GetObjectFunctions() = %v, want %v", funcs, wantFuncs)
+	}
+	wantLOHs := map[uint64][]LOH{0x0: hints[:1], 0x20: hints[1:]}
+	if got := f.GetFunctionLOHs(); !reflect.DeepEqual(got, wantLOHs) {
+		t.Errorf("GetFunctionLOHs() = %v, want %v", got, wantLOHs)
+	}
+	if got := f.GetLOHsForFunction(funcs[1]); !reflect.DeepEqual(got, hints[1:]) {
+		t.Errorf("GetLOHsForFunction(_bar) = %v, want %v", got, hints[1:])
+	}
+
+	// malformed hints are reported instead of failing the parse
+	bad := []byte{0x07, 0x05, 0x10}
+	f = &File{}
+	f.ByteOrder = binary.LittleEndian
+	f.cr = types.NewCustomSectionReader(bytes.NewReader(append(make([]byte, 0x10), bad...)), nil, 0, 0x13)
+	f.Loads = make([]Load, 1)
+	var cmd bytes.Buffer
+	binary.Write(&cmd, binary.LittleEndian, types.LinkEditDataCmd{LoadCmd: types.LC_LINKER_OPTIMIZATION_HINT, Len: 16, Offset: 0x10, Size: uint32(len(bad))})
+	if err := f.parseLoadCmd(0, types.LC_LINKER_OPTIMIZATION_HINT, 16, cmd.Bytes(), 16, nil); err != nil {
+		t.Fatalf("parseLoadCmd() error = %v", err)
+	}
+	if l, ok := f.Loads[0].(*LinkerOptimizationHint); !ok || l.Hints != nil {
+		t.Errorf("parseLoadCmd() = %v, want a LinkerOptimizationHint without hints", f.Loads[0])
+	}
+	if diags := f.Diagnostics(); len(diags) != 1 || diags[0].Kind != DiagLinkerOptimizationHint {
+		t.Errorf("Diagnostics() = %v, want a malformed linker optimization hint", diags)
+	}
+}
+
+func TestNewLoadCommands(t *testing.T) {
+	// __LINKEDIT payloads: a function variants table followed by two variant fixups
+	var linkedit bytes.Buffer
+	linkedit.Write(make([]byte, 0x20))
+	binary.Write(&linkedit, binary.LittleEndian, []uint32{1, 8})
+	binary.Write(&linkedit, binary.LittleEndian, []uint32{uint32(types.FunctionVariantsArm64), 2})
+	binary.Write(&linkedit, binary.LittleEndian, []types.FunctionVariantsEntry{
+		{Impl: 0x3f00, FlagBitNums: [4]uint8{1, 2}},
+		{Impl: 0x3f80},
+	})
+	binary.Write(&linkedit, binary.LittleEndian, []types.FunctionVariantFixup{
+		{SegOffset: 0x10, Info: 0x2 | 1<<4},
+		{SegOffset: 0x18, Info: 0x2 | 1<<12 | 1<<13 | 2<<14 | 0x1234<<16},
+	})
+
+	f := &File{}
+	f.ByteOrder = binary.LittleEndian
+	f.cr = types.NewCustomSectionReader(bytes.NewReader(linkedit.Bytes()), nil, 0, int64(linkedit.Len()))
+
+	triple := []byte("arm64-apple-macosx15.0.0\x00\x00\x00\x00")
+	tests := []struct {
+		cmd        types.LoadCmd
+		dat        []byte
+		wantString string
+	}{
+		{types.LC_ATOM_INFO, leCmd(types.LC_ATOM_INFO, 0x40, 0x10), "offset=0x00000040-0x00000050 size=   16"},
+		{types.LC_FUNCTION_VARIANTS, leCmd(types.LC_FUNCTION_VARIANTS, 0x20, 0x20), "offset=0x00000020-0x00000040 size=   32"},
+		{types.LC_FUNCTION_VARIANT_FIXUPS, leCmd(types.LC_FUNCTION_VARIANT_FIXUPS, 0x40, 0x10), "offset=0x00000040-0x00000050 size=   16 count=2"},
+		{types.LC_TARGET_TRIPLE, append(leCmd(types.LC_TARGET_TRIPLE, 12), triple...), "arm64-apple-macosx15.0.0"},
+	}
+	f.Loads = make([]Load, len(tests))
+	for i, tt := range tests {
+		binary.LittleEndian.PutUint32(tt.dat[4:], uint32(len(tt.dat)))
+		if err := f.parseLoadCmd(i, tt.cmd, uint32(len(tt.dat)), tt.dat, int64(i*0x40), nil); err != nil {
+			t.Fatalf("parseLoadCmd(%s) error = %v", tt.cmd, err)
+		}
+		l := f.Loads[i]
+		if l.Command() != tt.cmd {
+			t.Errorf("parseLoadCmd(%s) = %s", tt.cmd, l.Command())
+		}
+		if got := l.String(); got != tt.wantString {
+			t.Errorf("%s String() = %q, want %q", tt.cmd, got, tt.wantString)
+		}
+		put := make([]byte, len(tt.dat))
+		if n := l.(interface {
+			Put([]byte, binary.ByteOrder) int
+		}).Put(put, binary.LittleEndian); n != len(tt.dat) || !bytes.Equal(put, tt.dat) {
+			t.Errorf("%s Put() = %d, % x, want % x", tt.cmd, n, put, tt.dat)
+		}
+		var buf bytes.Buffer
+		if err := l.(interface {
+			Write(*bytes.Buffer, binary.ByteOrder) error
+		}).Write(&buf, binary.LittleEndian); err != nil || !bytes.Equal(buf.Bytes(), tt.dat) {
+			t.Errorf("%s Write() = % x, %v, want % x", tt.cmd, buf.Bytes(), err, tt.dat)
+		}
+	}
+	if tt := f.TargetTriple(); tt == nil || tt.TargetTripleCmd.Triple != 12 {
+		t.Errorf("TargetTriple() = %#v, want the triple at offset 12", tt)
+	}
+
+	tables, err := f.GetFunctionVariants()
+	if err != nil {
+		t.Fatalf("GetFunctionVariants() error = %v", err)
+	}
+	wantTables := []types.FunctionVariantsTable{{
+		Kind: types.FunctionVariantsArm64,
+		Entries: []types.FunctionVariantsEntry{
+			{Impl: 0x3f00, FlagBitNums: [4]uint8{1, 2}},
+			{Impl: 0x3f80},
+		},
+	}}
+	if !reflect.DeepEqual(tables, wantTables) {
+		t.Errorf("GetFunctionVariants() = %v, want %v", tables, wantTables)
+	}
+	// a table with more entries than the payload holds
+	f.FunctionVariants().Size = 0x18
+	if tables, err := f.GetFunctionVariants(); err == nil {
+		t.Errorf("GetFunctionVariants() = %v, want an error on a truncated table", tables)
+	}
+	fixups, err := f.GetFunctionVariantFixups()
+	if err != nil {
+		t.Fatalf("GetFunctionVariantFixups() error = %v", err)
+	}
+	wantFixups := []string{
+		"seg=2 offset=0x10 variant=1",
+		"seg=2 offset=0x18 variant=0 auth(key=2, addr=true, diversity=0x1234)",
+	}
+	if len(fixups) != len(wantFixups) {
+		t.Fatalf("GetFunctionVariantFixups() = %v, want %v", fixups, wantFixups)
+	}
+	for i, fixup := range fixups {
+		if fixup.String() != wantFixups[i] {
+			t.Errorf("GetFunctionVariantFixups()[%d] = %q, want %q", i, fixup.String(), wantFixups[i])
+		}
+	}
+}
+
+// leCmd returns a little endian load command made of cmd, a placeholder cmdsize and args
+func leCmd(cmd types.LoadCmd, args ...uint32) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, append([]uint32{uint32(cmd), 0}, args...))
+	return buf.Bytes()
+}
+
 func TestRelocTypeString(t *testing.T) {
 	if types.X86_64_RELOC_BRANCH.String() != "X86_64_RELOC_BRANCH" {
 		t.Errorf("got %v, want %v", types.X86_64_RELOC_BRANCH.String(), "X86_64_RELOC_BRANCH")
@@ -0,0 +1,166 @@
+package macho
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// An IndirectSymbol is a slot of a symbol stubs or symbol pointers section
+// (e.g. __stubs, __auth_stubs, __got, __auth_got or __la_symbol_ptr) and the
+// symbol it is bound to, as listed by `otool -Iv`.
+type IndirectSymbol struct {
+	Addr    uint64   // address of the stub or pointer
+	Index   uint32   // raw indirect symbol table entry: an index into Symtab.Syms or INDIRECT_SYMBOL_LOCAL/INDIRECT_SYMBOL_ABS
+	Section *Section // section containing the slot
+	Symbol  *Symbol  // nil if the entry is INDIRECT_SYMBOL_LOCAL/INDIRECT_SYMBOL_ABS
+	Library string   // name of the dylib providing the symbol, empty if not an imported symbol
+}
+
+// IsLocal returns true if the symbol was stripped and is local.
+func (i IndirectSymbol) IsLocal() bool {
+	return i.Index&types.INDIRECT_SYMBOL_LOCAL != 0
+}
+
+// IsAbsolute returns true if the symbol was stripped and is absolute.
+func (i IndirectSymbol) IsAbsolute() bool {
+	return i.Index&types.INDIRECT_SYMBOL_ABS != 0
+}
+
+// Name returns the symbol's name, or the otool marker for stripped entries.
+func (i IndirectSymbol) Name() string {
+	switch {
+	case i.IsLocal() && i.IsAbsolute():
+		return "LOCAL ABSOLUTE"
+	case i.IsLocal():
+		return "LOCAL"
+	case i.IsAbsolute():
+		return "ABSOLUTE"
+	case i.Symbol != nil:
+		return i.Symbol.Name
+	}
+	return "?"
+}
+
+func (i IndirectSymbol) String() string {
+	var index string
+	if i.IsLocal() || i.IsAbsolute() {
+		index = i.Name()
+	} else {
+		index = fmt.Sprintf("%5d", i.Index)
+	}
+	var lib string
+	if i.Library != "" {
+		lib = fmt.Sprintf(" (from %s)", i.Library)
+	}
+	if i.Symbol == nil {
+		return fmt.Sprintf("%#016x %s", i.Addr, index)
+	}
+	return fmt.Sprintf("%#016x %s %s%s", i.Addr, index, i.Name(), lib)
+}
+
+// isIndirectSymbolSection returns true if the section's slots are described by the indirect symbol table.
+func isIndirectSymbolSection(sec *Section) bool {
+	return sec.Flags.IsSymbolStubs() ||
+		sec.Flags.IsNonLazySymbolPointers() ||
+		sec.Flags.IsLazySymbolPointers() ||
+		(sec.Flags&types.SectionType) == types.LazyDylibSymbolPointers
+}
+
+// GetIndirectSymbolsForSection returns the indirect symbols of a symbol stubs or symbol pointers section.
+func (f *File) GetIndirectSymbolsForSection(sec *Section) ([]IndirectSymbol, error) {
+	if f.Dysymtab == nil {
+		return nil, fmt.Errorf("no LC_DYSYMTAB load command")
+	}
+	if !isIndirectSymbolSection(sec) {
+		return nil, fmt.Errorf("section %s.%s is not a symbol stubs or symbol pointers section", sec.Seg, sec.Name)
+	}
+
+	stride := f.pointerSize()
+	if sec.Flags.IsSymbolStubs() {
+		stride = uint64(sec.Reserved2) // size of a stub
+	}
+	if stride == 0 {
+		return nil, fmt.Errorf("section %s.%s has a zero stub size", sec.Seg, sec.Name)
+	}
+
+	count := sec.Size / stride
+	start := uint64(sec.Reserved1)
+	if start+count > uint64(len(f.Dysymtab.IndirectSyms)) {
+		return nil, fmt.Errorf("section %s.%s indirect symbols [%d:%d] are out of range of the indirect symbol table (%d entries)",
+			sec.Seg, sec.Name, start, start+count, len(f.Dysymtab.IndirectSyms))
+	}
+
+	isyms := make([]IndirectSymbol, 0, count)
+	for j := uint64(0); j < count; j++ {
+		isym := IndirectSymbol{
+			Addr:    sec.Addr + j*stride,
+			Index:   f.Dysymtab.IndirectSyms[start+j],
+			Section: sec,
+		}
+		if !isym.IsLocal() && !isym.IsAbsolute() {
+			if f.Symtab == nil || int(isym.Index) >= len(f.Symtab.Syms) {
+				return nil, fmt.Errorf("indirect symbol index %d for %#x is out of range of the symbol table", isym.Index, isym.Addr)
+			}
+			isym.Symbol = &f.Symtab.Syms[isym.Index]
+			if isym.Symbol.Type.IsUndefinedSym() {
+				isym.Library = f.symbolLibrary(isym.Symbol)
+			}
+		}
+		isyms = append(isyms, isym)
+	}
+
+	return isyms, nil
+}
+
+// GetIndirectSymbols returns the indirect symbols of all the symbol stubs and symbol pointers sections.
+func (f *File) GetIndirectSymbols() ([]IndirectSymbol, error) {
+	var isyms []IndirectSymbol
+	for _, sec := range f.Sections {
+		if !isIndirectSymbolSection(sec) {
+			continue
+		}
+		syms, err := f.GetIndirectSymbolsForSection(sec)
+		if err != nil {
+			return nil, err
+		}
+		isyms = append(isyms, syms...)
+	}
+	sort.Slice(isyms, func(i, j int) bool { return isyms[i].Addr < isyms[j].Addr })
+	return isyms, nil
+}
+
+// GetIndirectSymbolAtAddress returns the indirect symbol of the stub or symbol pointer containing addr;
+// use it to turn the target of a call to a stub into the name of the imported function.
+func (f *File) GetIndirectSymbolAtAddress(addr uint64) (*IndirectSymbol, error) {
+	for _, sec := range f.Sections {
+		if !isIndirectSymbolSection(sec) || addr < sec.Addr || addr >= sec.Addr+sec.Size {
+			continue
+		}
+		isyms, err := f.GetIndirectSymbolsForSection(sec)
+		if err != nil {
+			return nil, err
+		}
+		i := sort.Search(len(isyms), func(i int) bool { return isyms[i].Addr > addr }) - 1
+		if i >= 0 {
+			return &isyms[i], nil
+		}
+	}
+	return nil, fmt.Errorf("address %#x is not in a symbol stubs or symbol pointers section", addr)
+}
+
+// symbolLibrary returns the name of the dylib an undefined symbol is bound to.
+func (f *File) symbolLibrary(sym *Symbol) string {
+	if !f.Flags.TwoLevel() {
+		return f.LibraryOrdinalName(types.BIND_SPECIAL_DYLIB_FLAT_LOOKUP)
+	}
+	switch ord := sym.Desc.GetLibraryOrdinal(); ord {
+	case types.DYNAMIC_LOOKUP_ORDINAL:
+		return f.LibraryOrdinalName(types.BIND_SPECIAL_DYLIB_FLAT_LOOKUP)
+	case types.EXECUTABLE_ORDINAL:
+		return f.LibraryOrdinalName(types.BIND_SPECIAL_DYLIB_MAIN_EXECUTABLE)
+	default:
+		return f.LibraryOrdinalName(int(ord))
+	}
+}
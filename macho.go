@@ -116,3 +116,113 @@ type RegsARM64 struct {
 	CPSR uint32
 	PAD  uint32
 }
+
+// ExceptionState386 is the Mach-O 386 exception state structure.
+type ExceptionState386 struct {
+	TrapNo     uint16
+	CPU        uint16
+	Err        uint32
+	FaultVAddr uint32
+}
+
+// ExceptionStateAMD64 is the Mach-O AMD64 exception state structure.
+type ExceptionStateAMD64 struct {
+	TrapNo     uint16
+	CPU        uint16
+	Err        uint32
+	FaultVAddr uint64
+}
+
+// FloatState386 is the Mach-O 386 floating point state structure.
+type FloatState386 struct {
+	Reserved  [2]uint32
+	FCW       uint16
+	FSW       uint16
+	FTW       uint8
+	Rsrv1     uint8
+	FOP       uint16
+	IP        uint32
+	CS        uint16
+	Rsrv2     uint16
+	DP        uint32
+	DS        uint16
+	Rsrv3     uint16
+	MXCSR     uint32
+	MXCSRMask uint32
+	STMM      [8][16]byte
+	XMM       [8][16]byte
+	Rsrv4     [14 * 16]byte
+	Reserved1 uint32
+}
+
+// FloatStateAMD64 is the Mach-O AMD64 floating point state structure.
+type FloatStateAMD64 struct {
+	Reserved  [2]uint32
+	FCW       uint16
+	FSW       uint16
+	FTW       uint8
+	Rsrv1     uint8
+	FOP       uint16
+	IP        uint32
+	CS        uint16
+	Rsrv2     uint16
+	DP        uint32
+	DS        uint16
+	Rsrv3     uint16
+	MXCSR     uint32
+	MXCSRMask uint32
+	STMM      [8][16]byte
+	XMM       [16][16]byte
+	Rsrv4     [6 * 16]byte
+	Reserved1 uint32
+}
+
+// DebugState386 is the Mach-O 386 debug register structure.
+type DebugState386 struct {
+	DR [8]uint32
+}
+
+// DebugStateAMD64 is the Mach-O AMD64 debug register structure.
+type DebugStateAMD64 struct {
+	DR [8]uint64
+}
+
+// ExceptionStateARM is the Mach-O ARM exception state structure.
+type ExceptionStateARM struct {
+	Exception uint32
+	FSR       uint32
+	FAR       uint32
+}
+
+// ExceptionStateARM64 is the Mach-O ARM 64 exception state structure.
+type ExceptionStateARM64 struct {
+	FAR       uint64
+	ESR       uint32
+	Exception uint32
+}
+
+// ExceptionStateARM64V2 is the Mach-O ARM 64 exception state (v2) structure.
+type ExceptionStateARM64V2 struct {
+	FAR uint64
+	ESR uint64
+}
+
+// FloatStateARM is the Mach-O ARM VFP register structure.
+type FloatStateARM struct {
+	R     [64]uint32
+	FPSCR uint32
+}
+
+// NeonStateARM is the Mach-O ARM NEON register structure.
+type NeonStateARM struct {
+	Q    [16][16]byte
+	FPSR uint32
+	FPCR uint32
+}
+
+// NeonStateARM64 is the Mach-O ARM 64 NEON register structure.
+type NeonStateARM64 struct {
+	Q    [32][16]byte
+	FPSR uint32
+	FPCR uint32
+}
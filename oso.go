@@ -0,0 +1,278 @@
+package macho
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-dwarf"
+)
+
+// An OSOObject is an object file referenced by a debug map N_OSO entry and its DWARF.
+type OSOObject struct {
+	*DebugMapObject
+	File  *File
+	DWARF *dwarf.Data
+
+	closer io.Closer
+}
+
+// osoRange maps a function or global of the linked binary back to its object file.
+type osoRange struct {
+	start, end uint64 // linked binary address range
+	objAddr    uint64 // object file address of start
+	obj        *OSOObject
+}
+
+// OSODWARF is the DWARF of a binary without a dSYM, read from the object
+// files its debug map (N_OSO stabs) references, with the object file
+// addresses mapped to the linked binary the way lldb does for debug builds.
+type OSODWARF struct {
+	Map     *DebugMap
+	Objects []*OSOObject
+
+	ranges []osoRange // sorted by start
+}
+
+// OSODWARF opens the object files referenced by the debug map of the binary
+// and reads their DWARF. The object files are looked for at their N_OSO path
+// under root (or at the N_OSO path itself if root is empty), then by their
+// base name in root. Static archive members ("libfoo.a(bar.o)") are supported.
+//
+// Object files that are missing, out of date or without DWARF are reported
+// as DiagDebugMap diagnostics and skipped. Call Close when done.
+func (f *File) OSODWARF(root string) (*OSODWARF, error) {
+	m, err := f.DebugMap()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &OSODWARF{Map: m}
+	for _, dmo := range m.Objects {
+		obj, err := openOSOObject(dmo, root)
+		if err != nil {
+			f.warn(DiagDebugMap, -1, "%v", err)
+			continue
+		}
+		if err := d.addObject(obj); err != nil {
+			obj.close()
+			f.warn(DiagDebugMap, -1, "%v", err)
+			continue
+		}
+		if missing := dmo.ResolveObjectAddrs(obj.File); missing > 0 {
+			f.warn(DiagDebugMap, -1, "%d debug map symbols not found in %s", missing, dmo.Path)
+		}
+	}
+	if len(d.Objects) == 0 {
+		return nil, fmt.Errorf("none of the %d debug map object files could be loaded", len(m.Objects))
+	}
+	d.buildRanges()
+
+	return d, nil
+}
+
+// addObject reads the DWARF of an opened debug map object file and adds it to d.
+func (d *OSODWARF) addObject(obj *OSOObject) error {
+	dw, err := obj.File.DWARF()
+	if err != nil {
+		return fmt.Errorf("failed to read DWARF of %s: %v", obj.Path, err)
+	}
+	obj.DWARF = dw
+	d.Objects = append(d.Objects, obj)
+	return nil
+}
+
+func (d *OSODWARF) buildRanges() {
+	d.ranges = d.ranges[:0]
+	for _, obj := range d.Objects {
+		for _, sym := range obj.Symbols {
+			if !sym.Resolved {
+				continue
+			}
+			size := sym.Size
+			if size == 0 {
+				size = 1 // globals only match their address
+			}
+			d.ranges = append(d.ranges, osoRange{
+				start:   sym.BinaryAddr,
+				end:     sym.BinaryAddr + size,
+				objAddr: sym.ObjectAddr,
+				obj:     obj,
+			})
+		}
+	}
+	sort.Slice(d.ranges, func(i, j int) bool { return d.ranges[i].start < d.ranges[j].start })
+}
+
+// Close closes all the object files.
+func (d *OSODWARF) Close() error {
+	var err error
+	for _, obj := range d.Objects {
+		if cerr := obj.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Lookup returns the object file containing the linked binary address addr
+// and addr translated to the address space of that object file.
+func (d *OSODWARF) Lookup(addr uint64) (*OSOObject, uint64, bool) {
+	i := sort.Search(len(d.ranges), func(i int) bool { return d.ranges[i].start > addr }) - 1
+	if i < 0 || addr >= d.ranges[i].end {
+		return nil, 0, false
+	}
+	r := d.ranges[i]
+	return r.obj, r.objAddr + addr - r.start, true
+}
+
+// BinaryAddr translates an address of one of the object files to the linked binary.
+func (d *OSODWARF) BinaryAddr(obj *OSOObject, objAddr uint64) (uint64, bool) {
+	for _, r := range d.ranges {
+		if r.obj == obj && objAddr >= r.objAddr && objAddr < r.objAddr+(r.end-r.start) {
+			return r.start + objAddr - r.objAddr, true
+		}
+	}
+	return 0, false
+}
+
+// LineForAddr returns the source line of the linked binary address addr.
+// The Address of the returned line entry is translated to the linked binary.
+func (d *OSODWARF) LineForAddr(addr uint64) (*dwarf.LineEntry, error) {
+	obj, objAddr, ok := d.Lookup(addr)
+	if !ok {
+		return nil, fmt.Errorf("address %#x not found in debug map", addr)
+	}
+	cu, err := obj.DWARF.Reader().SeekPC(objAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find compile unit for %#x in %s: %v", objAddr, obj.Path, err)
+	}
+	lr, err := obj.DWARF.LineReader(cu)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read line table of %s: %v", obj.Path, err)
+	}
+	if lr == nil {
+		return nil, fmt.Errorf("compile unit for %#x in %s has no line table", objAddr, obj.Path)
+	}
+	var le dwarf.LineEntry
+	if err := lr.SeekPC(objAddr, &le); err != nil {
+		return nil, fmt.Errorf("failed to find line for %#x in %s: %v", objAddr, obj.Path, err)
+	}
+	if baddr, ok := d.BinaryAddr(obj, le.Address); ok {
+		le.Address = baddr
+	}
+	return &le, nil
+}
+
+// FunctionForAddr returns the DW_TAG_subprogram containing the linked binary
+// address addr and the object file whose DWARF it is from.
+func (d *OSODWARF) FunctionForAddr(addr uint64) (*OSOObject, *dwarf.Entry, error) {
+	obj, objAddr, ok := d.Lookup(addr)
+	if !ok {
+		return nil, nil, fmt.Errorf("address %#x not found in debug map", addr)
+	}
+	r := obj.DWARF.Reader()
+	if _, err := r.SeekPC(objAddr); err != nil {
+		return nil, nil, fmt.Errorf("failed to find compile unit for %#x in %s: %v", objAddr, obj.Path, err)
+	}
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read DWARF of %s: %v", obj.Path, err)
+		}
+		if e == nil || e.Tag == dwarf.TagCompileUnit {
+			break
+		}
+		if e.Tag != dwarf.TagSubprogram {
+			continue
+		}
+		ranges, err := obj.DWARF.Ranges(e)
+		if err != nil {
+			continue
+		}
+		for _, rng := range ranges {
+			if objAddr >= rng[0] && objAddr < rng[1] {
+				return obj, e, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("no function found for %#x in %s", objAddr, obj.Path)
+}
+
+func (o *OSOObject) close() error {
+	if o.closer == nil {
+		return nil
+	}
+	err := o.closer.Close()
+	o.closer = nil
+	return err
+}
+
+// findOSOPath returns the first of the candidate paths of an N_OSO path that exists.
+func findOSOPath(path, root string) (string, error) {
+	candidates := []string{path}
+	if root != "" {
+		candidates = []string{filepath.Join(root, path), filepath.Join(root, filepath.Base(path))}
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("debug map object file %s not found", path)
+}
+
+// openOSOObject opens the object file (or archive member) of a debug map N_OSO entry.
+func openOSOObject(dmo *DebugMapObject, root string) (*OSOObject, error) {
+	// archive members are referenced as "libfoo.a(bar.o)"
+	if strings.HasSuffix(dmo.Path, ")") {
+		if i := strings.LastIndex(dmo.Path, "("); i > 0 {
+			path, err := findOSOPath(dmo.Path[:i], root)
+			if err != nil {
+				return nil, err
+			}
+			a, err := OpenArchive(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open debug map archive %s: %v", path, err)
+			}
+			name := dmo.Path[i+1 : len(dmo.Path)-1]
+			m := a.Member(name)
+			if m == nil {
+				a.Close()
+				return nil, fmt.Errorf("debug map object file %s not found in %s", name, path)
+			}
+			if dmo.ModTime.Unix() != 0 && m.ModTime.Unix() != dmo.ModTime.Unix() {
+				a.Close()
+				return nil, fmt.Errorf("debug map object file %s is out of date: modified %s, expected %s", dmo.Path, m.ModTime, dmo.ModTime)
+			}
+			f, err := m.Open()
+			if err != nil {
+				a.Close()
+				return nil, err
+			}
+			return &OSOObject{DebugMapObject: dmo, File: f, closer: a}, nil
+		}
+	}
+
+	path, err := findOSOPath(dmo.Path, root)
+	if err != nil {
+		return nil, err
+	}
+	if dmo.ModTime.Unix() != 0 {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.ModTime().Unix() != dmo.ModTime.Unix() {
+			return nil, fmt.Errorf("debug map object file %s is out of date: modified %s, expected %s", path, fi.ModTime(), dmo.ModTime)
+		}
+	}
+	f, err := Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open debug map object file %s: %v", path, err)
+	}
+	return &OSOObject{DebugMapObject: dmo, File: f, closer: f}, nil
+}
@@ -37,7 +37,7 @@ func ParseCodeSignature(cmddat []byte) (*types.CodeSignature, error) {
 		case types.CSSLOT_CODEDIRECTORY:
 			fallthrough
 		case types.CSSLOT_ALTERNATE_CODEDIRECTORIES:
-			cd, err := parseCodeDirectory(r, index.Offset)
+			cd, err := parseCodeDirectory(r, index.Offset, cs)
 			if err != nil {
 				return nil, err
 			}
@@ -110,13 +110,13 @@ func ParseCodeSignature(cmddat []byte) (*types.CodeSignature, error) {
 		case types.CSSLOT_TICKETSLOT:
 			fallthrough // TODO 🤷‍♂️
 		default:
-			fmt.Printf("Found unsupported codesign slot %s, please notify author\n", index.Type)
+			cs.Warnings = append(cs.Warnings, fmt.Sprintf("found unsupported codesign slot %s at offset %#x", index.Type, index.Offset))
 		}
 	}
 	return cs, nil
 }
 
-func parseCodeDirectory(r *bytes.Reader, offset uint32) (*types.CodeDirectory, error) {
+func parseCodeDirectory(r *bytes.Reader, offset uint32, cs *types.CodeSignature) (*types.CodeDirectory, error) {
 	var cd types.CodeDirectory
 	if err := binary.Read(r, binary.BigEndian, &cd.Header); err != nil {
 		return nil, err
@@ -138,14 +138,14 @@ func parseCodeDirectory(r *bytes.Reader, offset uint32) (*types.CodeDirectory, e
 		h.Write(cdData)
 		cd.CDHash = fmt.Sprintf("%x", h.Sum(nil))
 	default:
-		fmt.Printf("Found unsupported code directory hash type %s, please notify author\n", cd.Header.HashType)
+		cs.Warnings = append(cs.Warnings, fmt.Sprintf("found unsupported code directory hash type %s at offset %#x", cd.Header.HashType, offset))
 	}
 
 	// Parse version
 	if cd.Header.Version < types.EARLIEST_VERSION {
-		fmt.Printf("unsupported type or version of signature: %#x (too old)\n", cd.Header.Version)
+		cs.Warnings = append(cs.Warnings, fmt.Sprintf("unsupported type or version of signature: %#x (too old)", cd.Header.Version))
 	} else if cd.Header.Version > types.COMPATIBILITY_LIMIT {
-		fmt.Printf("unsupported type or version of signature: %#x (too new)\n", cd.Header.Version)
+		cs.Warnings = append(cs.Warnings, fmt.Sprintf("unsupported type or version of signature: %#x (too new)", cd.Header.Version))
 	}
 
 	// SUPPORTS_SCATTER
@@ -266,6 +266,6 @@ func parseCodeDirectory(r *bytes.Reader, offset uint32) (*types.CodeDirectory, e
 // id is the identifier used for signing (a field in CodeDirectory blob, which
 // has no significance in ad-hoc signing).
 // Similar to: `codesign --force --deep -s - MyApp.app`
-func AdHocSign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int64, isMain bool) {
-	types.Sign(out, data, id, codeSize, textOff, textSize, isMain, uint32(types.ADHOC))
+func AdHocSign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int64, isMain bool) error {
+	return types.Sign(out, data, id, codeSize, textOff, textSize, isMain, uint32(types.ADHOC))
 }
@@ -535,7 +535,6 @@ func ParseRequirements(r *bytes.Reader, reqs Requirements) (string, error) {
 		}
 		return strings.Join(reqSet, " "), nil
 	default:
-		fmt.Printf("Found unsupported codesign requirement type %s, please notify author\n", reqs.Type)
+		return "", fmt.Errorf("failed to dump requirements set: unsupported codesign requirement type %s", reqs.Type)
 	}
-	return "", fmt.Errorf("failed to dump requirements set")
 }
@@ -3,6 +3,7 @@ package types
 import (
 	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
 	"io"
 
 	mtypes "github.com/blacktop/go-macho/types"
@@ -23,6 +24,8 @@ type CodeSignature struct {
 	CMSSignature    []byte
 	Entitlements    string
 	EntitlementsDER []byte
+	// Warnings are the unsupported parts of the signature that were skipped
+	Warnings []string
 }
 
 type magic uint32
@@ -175,7 +178,7 @@ func size(codeSize int64, id string) int64 {
 	return int64(superBlobSize+blobSize) + cdirSz
 }
 
-func Sign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int64, isMain bool, flags uint32) {
+func Sign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int64, isMain bool, flags uint32) error {
 
 	nhashes := (codeSize + pageSize - 1) / pageSize
 	idOff := int64(codeDirectorySize)
@@ -229,7 +232,7 @@ func Sign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int
 			break
 		}
 		if err != nil && err != io.ErrUnexpectedEOF {
-			panic(err)
+			return fmt.Errorf("failed to read code page at offset %#x: %v", p, err)
 		}
 		if p+n > int(codeSize) {
 			n = int(codeSize) - p
@@ -240,4 +243,6 @@ func Sign(out []byte, data io.Reader, id string, codeSize, textOff, textSize int
 		b := h.Sum(nil)
 		outp = puts(outp, b[:])
 	}
+
+	return nil
 }
@@ -0,0 +1,505 @@
+// Package crashreport parses and symbolicates Apple crash reports, both the
+// JSON .ips format and the legacy text .crash format.
+package crashreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// Format is the format of a crash report.
+type Format uint8
+
+const (
+	FormatIPS    Format = iota + 1 // JSON .ips (macOS 12 / iOS 15 and later)
+	FormatLegacy                   // text .crash with a "Binary Images" section
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatIPS:
+		return "ips"
+	case FormatLegacy:
+		return "legacy"
+	}
+	return fmt.Sprintf("Format(%d)", f)
+}
+
+// An Image is a binary image loaded in the crashed process.
+type Image struct {
+	Name string
+	Path string
+	Arch string // e.g. arm64e or x86_64, empty if unknown
+	UUID types.UUID
+	Base uint64 // load address of __TEXT
+	Size uint64
+}
+
+// Contains returns true if the address is in the image.
+func (i *Image) Contains(addr uint64) bool {
+	return addr >= i.Base && (i.Size == 0 || addr < i.Base+i.Size)
+}
+
+// A Frame is a stack frame of a thread.
+type Frame struct {
+	Index        int    // frame number
+	Image        *Image // nil if unknown
+	Address      uint64 // runtime address
+	Symbol       string
+	SymbolOffset uint64
+	File         string // source file, empty if unknown
+	Line         int
+	Column       int
+	Inlined      bool // the frame was inlined into the next one (symbolicated reports only)
+
+	raw  map[string]interface{} // .ips frame
+	line int                    // index in the lines of a legacy report, -1 for added inline frames
+	rest string                 // what follows the address on a legacy frame line
+}
+
+// A Thread is a backtrace of the report.
+type Thread struct {
+	Number  int // -1 for the last exception backtrace
+	Name    string
+	Crashed bool
+	Frames  []*Frame
+
+	raw map[string]interface{} // .ips thread
+}
+
+// A Report is a parsed crash report.
+type Report struct {
+	Format Format
+	// Header is the first line of an .ips report (bug_type, os_version, ...)
+	Header  map[string]interface{}
+	Arch    string // architecture of the crashed process
+	Images  []*Image
+	Threads []*Thread
+
+	body  map[string]interface{} // .ips report
+	lines []string               // legacy report
+}
+
+// Open opens and parses the crash report at path.
+func Open(path string) (*Report, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(dat)
+}
+
+// Parse parses a crash report, detecting its format.
+func Parse(dat []byte) (*Report, error) {
+	if trimmed := bytes.TrimSpace(dat); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseIPS(trimmed)
+	}
+	return parseLegacy(dat)
+}
+
+// archNames maps the code types of crash reports to architectures.
+var archNames = map[string]string{
+	"X86":    "i386",
+	"X86-64": "x86_64",
+	"ARM":    "armv7",
+	"ARM-64": "arm64",
+	"ARM64E": "arm64e",
+}
+
+func codeTypeArch(codeType string) string {
+	codeType = strings.TrimSpace(codeType)
+	if i := strings.IndexAny(codeType, " ("); i > 0 {
+		codeType = codeType[:i]
+	}
+	return archNames[strings.ToUpper(codeType)]
+}
+
+func parseUUID(s string) (types.UUID, error) {
+	var u types.UUID
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		return u, fmt.Errorf("invalid UUID %q: %v", s, err)
+	}
+	if len(b) != len(u) {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+/*******************************************************************************
+ * .ips
+ *******************************************************************************/
+
+func jsonUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case json.Number:
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u
+		}
+		if f, err := n.Float64(); err == nil {
+			return uint64(f)
+		}
+	case float64:
+		return uint64(n)
+	}
+	return 0
+}
+
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func decodeJSON(dat []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(dat))
+	d.UseNumber()
+	var m map[string]interface{}
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseIPS(dat []byte) (*Report, error) {
+	r := &Report{Format: FormatIPS}
+
+	// the header is the first line, the report the rest
+	nl := bytes.IndexByte(dat, '\n')
+	if nl < 0 {
+		return nil, fmt.Errorf("failed to parse .ips report: missing report after the header")
+	}
+	var err error
+	if r.Header, err = decodeJSON(dat[:nl]); err != nil {
+		return nil, fmt.Errorf("failed to parse .ips header: %v", err)
+	}
+	if r.body, err = decodeJSON(dat[nl+1:]); err != nil {
+		return nil, fmt.Errorf("failed to parse .ips report: %v", err)
+	}
+
+	r.Arch = codeTypeArch(jsonString(r.body["cpuType"]))
+
+	images, _ := r.body["usedImages"].([]interface{})
+	for _, v := range images {
+		m, _ := v.(map[string]interface{})
+		img := &Image{
+			Name: jsonString(m["name"]),
+			Path: jsonString(m["path"]),
+			Arch: jsonString(m["arch"]),
+			Base: jsonUint(m["base"]),
+			Size: jsonUint(m["size"]),
+		}
+		if img.Arch == "" {
+			img.Arch = r.Arch
+		}
+		if s := jsonString(m["uuid"]); s != "" {
+			if img.UUID, err = parseUUID(s); err != nil {
+				return nil, fmt.Errorf("failed to parse .ips image %s: %v", img.Name, err)
+			}
+		}
+		r.Images = append(r.Images, img)
+	}
+
+	threads, _ := r.body["threads"].([]interface{})
+	for i, v := range threads {
+		m, _ := v.(map[string]interface{})
+		t := &Thread{Number: i, raw: m}
+		t.Name = jsonString(m["name"])
+		if q := jsonString(m["queue"]); t.Name == "" && q != "" {
+			t.Name = "Dispatch queue: " + q
+		}
+		t.Crashed, _ = m["triggered"].(bool)
+		frames, _ := m["frames"].([]interface{})
+		t.Frames = r.ipsFrames(frames)
+		r.Threads = append(r.Threads, t)
+	}
+	if frames, ok := r.body["lastExceptionBacktrace"].([]interface{}); ok {
+		r.Threads = append(r.Threads, &Thread{
+			Number: -1,
+			Name:   "Last Exception Backtrace",
+			Frames: r.ipsFrames(frames),
+		})
+	}
+
+	return r, nil
+}
+
+func (r *Report) ipsFrames(frames []interface{}) []*Frame {
+	var fs []*Frame
+	for i, v := range frames {
+		m, _ := v.(map[string]interface{})
+		fr := &Frame{Index: i, raw: m, line: -1}
+		if idx, ok := m["imageIndex"]; ok {
+			if j := int(jsonUint(idx)); j < len(r.Images) {
+				fr.Image = r.Images[j]
+				fr.Address = fr.Image.Base + jsonUint(m["imageOffset"])
+			}
+		}
+		fr.Symbol = jsonString(m["symbol"])
+		fr.SymbolOffset = jsonUint(m["symbolLocation"])
+		fr.File = jsonString(m["sourceFile"])
+		fr.Line = int(jsonUint(m["sourceLine"]))
+		fr.Inlined, _ = m["inline"].(bool)
+		fs = append(fs, fr)
+	}
+	return fs
+}
+
+// ipsFrame returns the .ips representation of a frame.
+func (r *Report) ipsFrame(fr *Frame) map[string]interface{} {
+	m := make(map[string]interface{}, len(fr.raw)+4)
+	for k, v := range fr.raw {
+		m[k] = v
+	}
+	if fr.Image != nil {
+		for i, img := range r.Images {
+			if img == fr.Image {
+				m["imageIndex"] = i
+				break
+			}
+		}
+		m["imageOffset"] = fr.Address - fr.Image.Base
+	}
+	if fr.Symbol != "" {
+		m["symbol"] = fr.Symbol
+		m["symbolLocation"] = fr.SymbolOffset
+	}
+	if fr.File != "" {
+		m["sourceFile"] = filepath.Base(fr.File)
+		m["sourceLine"] = fr.Line
+	}
+	if fr.Inlined {
+		m["inline"] = true
+	}
+	return m
+}
+
+func (r *Report) writeIPS(w io.Writer) error {
+	for _, t := range r.Threads {
+		frames := make([]interface{}, 0, len(t.Frames))
+		for _, fr := range t.Frames {
+			frames = append(frames, r.ipsFrame(fr))
+		}
+		if t.raw != nil {
+			t.raw["frames"] = frames
+		} else if t.Number == -1 {
+			r.body["lastExceptionBacktrace"] = frames
+		}
+	}
+	hdr, err := json.Marshal(r.Header)
+	if err != nil {
+		return fmt.Errorf("failed to encode .ips header: %v", err)
+	}
+	body, err := json.MarshalIndent(r.body, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode .ips report: %v", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s\n%s\n", hdr, body); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*******************************************************************************
+ * legacy .crash
+ *******************************************************************************/
+
+var (
+	legacyThreadRe     = regexp.MustCompile(`^Thread (\d+)( Crashed)?:(?::\s*(.*))?$`)
+	legacyThreadNameRe = regexp.MustCompile(`^Thread (\d+) name:\s*(.*)$`)
+	legacyFrameRe      = regexp.MustCompile(`^(\d+)\s+(.+?)\s+(0x[0-9a-fA-F]+)(?:\s+(.*))?$`)
+	legacyImageRe      = regexp.MustCompile(`^\s*(0x[0-9a-fA-F]+)\s*-\s*(0x[0-9a-fA-F]+|\?+)\s+\+?(.+?)\s+(?:(arm64e|arm64_32|arm64|x86_64h|x86_64|i386|armv7[a-z]*)\s+)?(?:\([^)]*\)\s+)?<([0-9a-fA-F-]+)>\s*(.*)$`)
+)
+
+func parseLegacy(dat []byte) (*Report, error) {
+	r := &Report{Format: FormatLegacy}
+	s := bufio.NewScanner(bytes.NewReader(dat))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		r.lines = append(r.lines, strings.TrimRight(s.Text(), "\r"))
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read crash report: %v", err)
+	}
+
+	// the binary images are needed to resolve the frames, so read them first
+	names := make(map[int]string)
+	inImages := false
+	for _, line := range r.lines {
+		switch {
+		case strings.HasPrefix(line, "Code Type:"):
+			r.Arch = codeTypeArch(strings.TrimPrefix(line, "Code Type:"))
+		case strings.HasPrefix(line, "Binary Images:"):
+			inImages = true
+		case inImages && strings.TrimSpace(line) == "":
+			inImages = len(r.Images) == 0
+		case inImages:
+			m := legacyImageRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			img := &Image{Name: m[3], Arch: m[4], Path: m[6]}
+			img.Base, _ = strconv.ParseUint(strings.TrimPrefix(m[1], "0x"), 16, 64)
+			if end, err := strconv.ParseUint(strings.TrimPrefix(m[2], "0x"), 16, 64); err == nil && end >= img.Base {
+				img.Size = end - img.Base + 1
+			}
+			u, err := parseUUID(m[5])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse binary image %s: %v", img.Name, err)
+			}
+			img.UUID = u
+			r.Images = append(r.Images, img)
+		}
+	}
+	if len(r.Images) == 0 {
+		return nil, fmt.Errorf("failed to parse crash report: no Binary Images found")
+	}
+	for _, img := range r.Images {
+		if img.Arch == "" {
+			img.Arch = r.Arch
+		}
+	}
+
+	var t *Thread
+	for i, line := range r.lines {
+		if strings.HasPrefix(line, "Binary Images:") {
+			break
+		}
+		if m := legacyThreadNameRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			names[n] = m[2]
+			continue
+		}
+		if m := legacyThreadRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			t = &Thread{Number: n, Crashed: m[2] != "", Name: names[n]}
+			if m[3] != "" {
+				t.Name = m[3]
+			}
+			r.Threads = append(r.Threads, t)
+			continue
+		}
+		if strings.HasPrefix(line, "Last Exception Backtrace:") {
+			t = &Thread{Number: -1, Name: "Last Exception Backtrace"}
+			r.Threads = append(r.Threads, t)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			t = nil
+			continue
+		}
+		if t == nil {
+			continue
+		}
+		m := legacyFrameRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		fr := &Frame{line: i, rest: m[4]}
+		fr.Index, _ = strconv.Atoi(m[1])
+		fr.Address, _ = strconv.ParseUint(strings.TrimPrefix(m[3], "0x"), 16, 64)
+		fr.Image = r.imageForFrame(strings.TrimSpace(m[2]), fr.Address)
+		t.Frames = append(t.Frames, fr)
+	}
+
+	return r, nil
+}
+
+func (r *Report) imageForFrame(name string, addr uint64) *Image {
+	for _, img := range r.Images {
+		if img.Size > 0 && img.Contains(addr) {
+			return img
+		}
+	}
+	for _, img := range r.Images {
+		if img.Name == name && img.Contains(addr) {
+			return img
+		}
+	}
+	return nil
+}
+
+// legacySymbol returns the symbol part of a legacy frame line.
+func legacySymbol(fr *Frame) string {
+	if fr.Symbol == "" {
+		return fr.rest
+	}
+	s := fr.Symbol
+	if !fr.Inlined && fr.SymbolOffset > 0 {
+		s += fmt.Sprintf(" + %d", fr.SymbolOffset)
+	}
+	if fr.File != "" {
+		s += fmt.Sprintf(" (%s:%d)", filepath.Base(fr.File), fr.Line)
+	}
+	if fr.Inlined {
+		s += " [inlined]"
+	}
+	return s
+}
+
+func (r *Report) writeLegacy(w io.Writer) error {
+	// the frames of each line of the original report, with their inlined frames
+	frames := make(map[int][]*Frame)
+	for _, t := range r.Threads {
+		line := -1
+		// inlined frames come before the frame they were inlined into
+		for i := len(t.Frames) - 1; i >= 0; i-- {
+			fr := t.Frames[i]
+			if fr.line >= 0 {
+				line = fr.line
+			}
+			if line >= 0 {
+				frames[line] = append([]*Frame{fr}, frames[line]...)
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, line := range r.lines {
+		frs, ok := frames[i]
+		m := legacyFrameRe.FindStringSubmatchIndex(line)
+		if !ok || m == nil {
+			fmt.Fprintln(bw, line)
+			continue
+		}
+		prefix := line[:m[7]] // up to the end of the address
+		for _, fr := range frs {
+			fmt.Fprintf(bw, "%s %s\n", prefix, legacySymbol(fr))
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteTo writes the report, with its symbolicated frames, in its original format.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	var err error
+	if r.Format == FormatIPS {
+		err = r.writeIPS(cw)
+	} else {
+		err = r.writeLegacy(cw)
+	}
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,134 @@
+package crashreport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/blacktop/go-macho"
+	"github.com/blacktop/go-macho/internal/obscuretestdata"
+)
+
+func openObscured(t *testing.T, name string) *macho.File {
+	t.Helper()
+	dat, err := obscuretestdata.ReadFile("../../internal/testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := macho.NewFile(bytes.NewReader(dat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func newTestSymbolicator(t *testing.T) *Symbolicator {
+	t.Helper()
+	f := openObscured(t, "gcc-amd64-darwin-exec.base64")
+	s := NewSymbolicator()
+	if err := s.AddFile(f); err != nil {
+		t.Fatal(err)
+	}
+	// the DWARF of a debug build of the same hello.c
+	dsym := openObscured(t, "gcc-amd64-darwin-exec-debug.base64")
+	if err := s.AddDSYM(&macho.DSYM{Path: "hello.dSYM", UUID: f.UUID().UUID, File: dsym}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+const legacyReport = `Process:               hello [123]
+Code Type:             X86-64 (Native)
+
+Thread 0 Crashed:: Dispatch queue: com.apple.main-thread
+0   hello                         	0x0000000100010f6e 0x100010000 + 3950
+1   hello                         	0x0000000100010f31 0x100010000 + 3889
+2   libdyld.dylib                 	0x00007fff6b06d3d5 start + 1
+
+Thread 0 crashed with X86 Thread State (64-bit):
+  rax: 0x0000000000000000  rbx: 0x0000000000000000
+
+Binary Images:
+       0x100010000 -        0x100010fff +hello (0) <3B24B872-0E45-76D4-28AA-EE89B0C1215D> /tmp/hello
+    0x7fff6b06a000 -     0x7fff6b0a0fff  libdyld.dylib (750.5) <9EACB8A7-7B5C-3C73-B4E5-DE0B4F8F1F7E> /usr/lib/system/libdyld.dylib
+`
+
+func TestSymbolicateLegacy(t *testing.T) {
+	r, err := Parse([]byte(legacyReport))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if r.Format != FormatLegacy || r.Arch != "x86_64" || len(r.Images) != 2 || len(r.Threads) != 1 {
+		t.Fatalf("Parse() = %+v", r)
+	}
+	if th := r.Threads[0]; !th.Crashed || th.Name != "Dispatch queue: com.apple.main-thread" || len(th.Frames) != 3 || th.Frames[2].Image != r.Images[1] {
+		t.Fatalf("Parse() thread = %+v", th)
+	}
+
+	if n := r.Symbolicate(newTestSymbolicator(t)); n != 2 {
+		t.Errorf("Symbolicate() = %d, want 2", n)
+	}
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := strings.Replace(legacyReport,
+		"0x0000000100010f6e 0x100010000 + 3950\n", "0x0000000100010f6e main + 4 (hello.c:4)\n", 1)
+	want = strings.Replace(want,
+		"0x0000000100010f31 0x100010000 + 3889\n", "0x0000000100010f31 start + 29\n", 1)
+	if buf.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+const ipsReport = `{"app_name":"hello","bug_type":"309"}
+{
+  "cpuType" : "X86-64",
+  "procName" : "hello",
+  "usedImages" : [
+    {"arch" : "x86_64", "base" : 4295032832, "name" : "hello", "path" : "/tmp/hello", "size" : 4096, "uuid" : "3b24b872-0e45-76d4-28aa-ee89b0c1215d"},
+    {"arch" : "x86_64", "base" : 140734990000128, "name" : "other", "size" : 4096, "uuid" : "9eacb8a7-7b5c-3c73-b4e5-de0b4f8f1f7e"}
+  ],
+  "threads" : [
+    {"triggered" : true, "queue" : "com.apple.main-thread", "frames" : [
+      {"imageIndex" : 0, "imageOffset" : 3950},
+      {"imageIndex" : 0, "imageOffset" : 3889},
+      {"imageIndex" : 1, "imageOffset" : 16}
+    ]}
+  ]
+}`
+
+func TestSymbolicateIPS(t *testing.T) {
+	r, err := Parse([]byte(ipsReport))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if r.Format != FormatIPS || r.Header["bug_type"] != "309" || len(r.Images) != 2 || len(r.Threads) != 1 {
+		t.Fatalf("Parse() = %+v", r)
+	}
+	if n := r.Symbolicate(newTestSymbolicator(t)); n != 2 {
+		t.Errorf("Symbolicate() = %d, want 2", n)
+	}
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	r, err = Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse() of the symbolicated report error = %v", err)
+	}
+	frames := r.Threads[0].Frames
+	if len(frames) != 3 {
+		t.Fatalf("symbolicated frames = %d, want 3", len(frames))
+	}
+	if fr := frames[0]; fr.Symbol != "main" || fr.SymbolOffset != 4 || fr.File != "hello.c" || fr.Line != 4 || fr.Address != 0x100010f6e {
+		t.Errorf("frame 0 = %+v", fr)
+	}
+	if fr := frames[1]; fr.Symbol != "start" || fr.SymbolOffset != 29 || fr.File != "" {
+		t.Errorf("frame 1 = %+v", fr)
+	}
+	if fr := frames[2]; fr.Symbol != "" || fr.Image != r.Images[1] {
+		t.Errorf("frame 2 = %+v", fr)
+	}
+}
@@ -0,0 +1,189 @@
+package crashreport
+
+import (
+	"fmt"
+
+	"github.com/blacktop/go-dwarf"
+	"github.com/blacktop/go-macho"
+	"github.com/blacktop/go-macho/types"
+)
+
+type symbolicatorImage struct {
+	file  *macho.File
+	dwarf *dwarf.Data
+}
+
+// A Symbolicator holds the binaries and dSYMs used to symbolicate reports, by UUID.
+type Symbolicator struct {
+	images map[types.UUID]*symbolicatorImage
+}
+
+// NewSymbolicator returns an empty Symbolicator.
+func NewSymbolicator() *Symbolicator {
+	return &Symbolicator{images: make(map[types.UUID]*symbolicatorImage)}
+}
+
+// AddFile adds a binary. Its own DWARF is used unless a dSYM is added for its UUID.
+func (s *Symbolicator) AddFile(f *macho.File) error {
+	u := f.UUID()
+	if u == nil {
+		return fmt.Errorf("binary has no LC_UUID load command")
+	}
+	if img, ok := s.images[u.UUID]; ok {
+		img.file = f
+		return nil
+	}
+	s.images[u.UUID] = &symbolicatorImage{file: f}
+	return nil
+}
+
+// AddFatFile adds all the slices of a universal binary.
+func (s *Symbolicator) AddFatFile(ff *macho.FatFile) error {
+	for _, arch := range ff.Arches {
+		if err := s.AddFile(arch.File); err != nil {
+			return fmt.Errorf("failed to add %s slice: %v", arch.CPU, err)
+		}
+	}
+	return nil
+}
+
+// AddDSYM adds the DWARF of a dSYM. The dSYM's own symtab is used if the binary is not added.
+func (s *Symbolicator) AddDSYM(d *macho.DSYM) error {
+	dw, err := d.DWARF()
+	if err != nil {
+		return fmt.Errorf("failed to read DWARF of dSYM %s: %v", d.Path, err)
+	}
+	if img, ok := s.images[d.UUID]; ok {
+		img.dwarf = dw
+		return nil
+	}
+	s.images[d.UUID] = &symbolicatorImage{file: d.File, dwarf: dw}
+	return nil
+}
+
+// AddDSYMIndex adds the dSYMs of an index matching the images of the report.
+// The dSYMs are left open for the life of the Symbolicator.
+func (s *Symbolicator) AddDSYMIndex(idx *macho.DSYMIndex, r *Report) error {
+	for _, img := range r.Images {
+		d, err := idx.Open(img.UUID)
+		if err != nil {
+			continue // no dSYM for this image
+		}
+		if err := s.AddDSYM(d); err != nil {
+			d.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// Arch returns the crash report name of the architecture of a binary.
+func Arch(f *macho.File) string {
+	sub := f.SubCPU & types.CpuSubtypeMask
+	switch f.CPU {
+	case types.CPU386:
+		return "i386"
+	case types.CPUAmd64:
+		if sub == types.CPUSubtypeX86_64H {
+			return "x86_64h"
+		}
+		return "x86_64"
+	case types.CPUArm:
+		switch sub {
+		case types.CPUSubtypeArmV7S:
+			return "armv7s"
+		case types.CPUSubtypeArmV7K:
+			return "armv7k"
+		}
+		return "armv7"
+	case types.CPUArm64:
+		if sub == types.CPUSubtypeArm64E {
+			return "arm64e"
+		}
+		return "arm64"
+	case types.CPUArm6432:
+		return "arm64_32"
+	}
+	return f.CPU.String()
+}
+
+// archMatches returns true if the architecture of an image is compatible with a binary.
+func archMatches(arch string, f *macho.File) bool {
+	if arch == "" {
+		return true
+	}
+	fa := Arch(f)
+	// the report's code type does not tell arm64 from arm64e
+	return arch == fa || (arch == "arm64" && fa == "arm64e") || (arch == "x86_64" && fa == "x86_64h")
+}
+
+// Symbolicate rewrites every frame of the report whose image is in s with its
+// function, offset and, when DWARF is available, source file and line.
+// Inlined functions are added as frames before the frame they were inlined into.
+// It returns the number of frames symbolicated.
+func (r *Report) Symbolicate(s *Symbolicator) int {
+	var count int
+	for _, t := range r.Threads {
+		var frames []*Frame
+		for _, fr := range t.Frames {
+			if fr.Inlined && fr.line < 0 && fr.raw == nil {
+				continue // added by a previous Symbolicate
+			}
+			inlined, ok := s.symbolicateFrame(fr)
+			if ok {
+				count++
+			}
+			frames = append(frames, inlined...)
+			frames = append(frames, fr)
+		}
+		t.Frames = frames
+	}
+	return count
+}
+
+// symbolicateFrame symbolicates fr and returns the frames inlined into it, innermost first.
+func (s *Symbolicator) symbolicateFrame(fr *Frame) ([]*Frame, bool) {
+	if fr.Image == nil || fr.Inlined {
+		return nil, false
+	}
+	img, ok := s.images[fr.Image.UUID]
+	if !ok || !archMatches(fr.Image.Arch, img.file) {
+		return nil, false
+	}
+	addr := fr.Address
+	if fr.Index > 0 && addr > 0 {
+		// the return address of a caller frame is after the call instruction
+		addr--
+	}
+	sym, err := img.file.Symbolicate(addr, macho.SymbolicateConfig{
+		LoadAddress: fr.Image.Base,
+		DWARF:       img.dwarf,
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	fr.Symbol = sym.Function
+	fr.SymbolOffset = sym.Offset
+	if addr != fr.Address {
+		fr.SymbolOffset++
+	}
+	outer := sym.Frames[len(sym.Frames)-1]
+	fr.File, fr.Line, fr.Column = outer.File, outer.Line, outer.Column
+
+	var inlined []*Frame
+	for _, in := range sym.Frames[:len(sym.Frames)-1] {
+		inlined = append(inlined, &Frame{
+			Index:   fr.Index,
+			Image:   fr.Image,
+			Address: fr.Address,
+			Symbol:  in.Function,
+			File:    in.File,
+			Line:    in.Line,
+			Column:  in.Column,
+			Inlined: true,
+			line:    -1,
+		})
+	}
+	return inlined, true
+}
@@ -124,6 +124,10 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 		dcf.sr.Seek(int64(fixupLocation), io.SeekStart)
 
 		pointerFormat := dcf.Starts[segIdx].DyldChainedStartsInSegment.PointerFormat
+		ptrStride, err := stride(pointerFormat)
+		if err != nil {
+			return err
+		}
 
 		switch pointerFormat {
 		case DYLD_CHAINED_PTR_32:
@@ -143,7 +147,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic32Next(dcPtr) == 0 {
 				chainEnd = true
 			}
-			next += Generic32Next(dcPtr) * stride(pointerFormat)
+			next += Generic32Next(dcPtr) * ptrStride
 		case DYLD_CHAINED_PTR_32_CACHE:
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr); err != nil {
 				return err
@@ -155,7 +159,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic32Next(dcPtr) == 0 {
 				chainEnd = true
 			}
-			next += Generic32Next(dcPtr) * stride(pointerFormat)
+			next += Generic32Next(dcPtr) * ptrStride
 		case DYLD_CHAINED_PTR_32_FIRMWARE:
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr); err != nil {
 				return err
@@ -167,7 +171,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic32Next(dcPtr) == 0 {
 				chainEnd = true
 			}
-			next += Generic32Next(dcPtr) * stride(pointerFormat)
+			next += Generic32Next(dcPtr) * ptrStride
 		case DYLD_CHAINED_PTR_64: // target is vmaddr
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -185,7 +189,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic64Next(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += Generic64Next(dcPtr64) * stride(pointerFormat)
+			next += Generic64Next(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_64_OFFSET: // target is vm offset
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -197,7 +201,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic64Next(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += Generic64Next(dcPtr64) * stride(pointerFormat)
+			next += Generic64Next(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_64_KERNEL_CACHE:
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -209,7 +213,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic64Next(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += Generic64Next(dcPtr64) * stride(pointerFormat)
+			next += Generic64Next(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_X86_64_KERNEL_CACHE: // stride 1, x86_64 kernel caches
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -221,7 +225,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if Generic64Next(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += Generic64Next(dcPtr64) * stride(pointerFormat)
+			next += Generic64Next(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_ARM64E_KERNEL: // stride 4, unauth target is vm offset
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -248,7 +252,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if DcpArm64eNext(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += DcpArm64eNext(dcPtr64) * stride(pointerFormat)
+			next += DcpArm64eNext(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_ARM64E_FIRMWARE: // stride 4, unauth target is vmaddr
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -275,7 +279,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if DcpArm64eNext(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += DcpArm64eNext(dcPtr64) * stride(pointerFormat)
+			next += DcpArm64eNext(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_ARM64E: // stride 8, unauth target is vmaddr
 			fallthrough
 		case DYLD_CHAINED_PTR_ARM64E_USERLAND: // stride 8, unauth target is vm offset
@@ -304,7 +308,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if DcpArm64eNext(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += DcpArm64eNext(dcPtr64) * stride(pointerFormat)
+			next += DcpArm64eNext(dcPtr64) * ptrStride
 		case DYLD_CHAINED_PTR_ARM64E_USERLAND24: // stride 8, unauth target is vm offset, 24-bit bind
 			if err := binary.Read(dcf.sr, dcf.bo, &dcPtr64); err != nil {
 				return err
@@ -331,7 +335,7 @@ func (dcf *DyldChainedFixups) walkDcFixupChain(segIdx int, pageIndex uint16, off
 			if DcpArm64eNext(dcPtr64) == 0 {
 				chainEnd = true
 			}
-			next += DcpArm64eNext(dcPtr64) * stride(pointerFormat)
+			next += DcpArm64eNext(dcPtr64) * ptrStride
 		default:
 			return fmt.Errorf("unknown pointer format %#04X", dcf.Starts[segIdx].DyldChainedStartsInSegment.PointerFormat)
 		}
@@ -0,0 +1,193 @@
+package fixupchains
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ResolvedFixup is the value dyld writes at a chained fixup location
+type ResolvedFixup struct {
+	Fixup  uint64    // location of the fixup (as in Fixup.Offset())
+	Format DCPtrKind // DYLD_CHAINED_PTR_* of the fixup's segment
+	Raw    uint64    // chained pointer on disk
+
+	Target uint64 // unslid VM address of a rebase (including high8)
+
+	Bind    bool
+	Ordinal uint64 // import ordinal of a bind
+	Name    string // bound symbol
+	Addend  int64  // bind addend plus the import's addend
+	Weak    bool   // weak import
+
+	// arm64e (and arm64e kernel cache) pointer authentication
+	Auth      bool
+	Key       uint64 // IA, IB, DA or DB
+	AddrDiv   bool   // address diversity
+	Diversity uint64 // 16-bit discriminator
+
+	CacheLevel uint64 // kernel cache level whose base the target is relative to
+}
+
+// KeyName returns the PAC key name of an authenticated pointer
+func (r ResolvedFixup) KeyName() string {
+	return KeyName(r.Key)
+}
+
+func (r ResolvedFixup) String() string {
+	var s string
+	if r.Bind {
+		s = fmt.Sprintf("0x%08x: bind %s", r.Fixup, r.Name)
+		if r.Addend != 0 {
+			s += fmt.Sprintf(" + %#x", r.Addend)
+		}
+	} else {
+		s = fmt.Sprintf("0x%08x: rebase %#x", r.Fixup, r.Target)
+	}
+	if r.Auth {
+		s += fmt.Sprintf(" (key: %s, addrDiv: %t, diversity: %#04x)", r.KeyName(), r.AddrDiv, r.Diversity)
+	}
+	return s
+}
+
+// Resolver maps the locations of the chained fixups to the values dyld writes there
+type Resolver struct {
+	fixups map[uint64]ResolvedFixup
+}
+
+// NewResolver creates a Resolver for the parsed fixups of dcf where baseAddr is
+// the preferred load address of the image (the base of vm offset targets)
+func NewResolver(dcf *DyldChainedFixups, baseAddr uint64) *Resolver {
+	r := &Resolver{fixups: make(map[uint64]ResolvedFixup)}
+	for _, start := range dcf.Starts {
+		for _, fixup := range start.Fixups {
+			if rf, ok := dcf.resolve(fixup, &start.DyldChainedStartsInSegment, baseAddr); ok {
+				r.fixups[rf.Fixup] = rf
+			}
+		}
+	}
+	return r
+}
+
+// Resolve returns the resolved fixup at a location
+func (r *Resolver) Resolve(location uint64) (ResolvedFixup, bool) {
+	rf, ok := r.fixups[location]
+	return rf, ok
+}
+
+// Fixups returns all the resolved fixups sorted by location
+func (r *Resolver) Fixups() []ResolvedFixup {
+	fixups := make([]ResolvedFixup, 0, len(r.fixups))
+	for _, rf := range r.fixups {
+		fixups = append(fixups, rf)
+	}
+	sort.Slice(fixups, func(i, j int) bool {
+		return fixups[i].Fixup < fixups[j].Fixup
+	})
+	return fixups
+}
+
+// Len returns the number of fixups
+func (r *Resolver) Len() int {
+	return len(r.fixups)
+}
+
+func (dcf *DyldChainedFixups) resolve(fixup Fixup, seg *DyldChainedStartsInSegment, baseAddr uint64) (ResolvedFixup, bool) {
+	rf := ResolvedFixup{Fixup: fixup.Offset(), Format: seg.PointerFormat}
+
+	// targets of the arm64e userland and kernel formats are vm offsets
+	vmOffset := seg.PointerFormat == DYLD_CHAINED_PTR_ARM64E_KERNEL ||
+		seg.PointerFormat == DYLD_CHAINED_PTR_ARM64E_USERLAND ||
+		seg.PointerFormat == DYLD_CHAINED_PTR_ARM64E_USERLAND24
+
+	switch f := fixup.(type) {
+	case DyldChainedPtrArm64eRebase:
+		rf.Raw = f.Pointer
+		rf.Target = f.UnpackTarget()
+		if vmOffset {
+			rf.Target = f.High8()<<56 | (baseAddr + f.Target())
+		}
+	case DyldChainedPtrArm64eRebase24:
+		rf.Raw = f.Pointer
+		rf.Target = f.High8()<<56 | (baseAddr + f.Target())
+	case DyldChainedPtrArm64eAuthRebase:
+		rf.Raw = f.Pointer
+		rf.Target = baseAddr + f.Target()
+		rf.setAuth(f.Key(), f.AddrDiv(), f.Diversity())
+	case DyldChainedPtrArm64eAuthRebase24:
+		rf.Raw = f.Pointer
+		rf.Target = baseAddr + f.Target()
+		rf.setAuth(f.Key(), f.AddrDiv(), f.Diversity())
+	case DyldChainedPtrArm64eBind:
+		rf.Raw = f.Pointer
+		dcf.setBind(&rf, f.Ordinal(), f.SignExtendedAddend())
+	case DyldChainedPtrArm64eBind24:
+		rf.Raw = f.Pointer
+		dcf.setBind(&rf, f.Ordinal(), f.SignExtendedAddend())
+	case DyldChainedPtrArm64eAuthBind:
+		rf.Raw = f.Pointer
+		dcf.setBind(&rf, f.Ordinal(), 0)
+		rf.setAuth(f.Key(), f.AddrDiv(), f.Diversity())
+	case DyldChainedPtrArm64eAuthBind24:
+		rf.Raw = f.Pointer
+		dcf.setBind(&rf, f.Ordinal(), 0)
+		rf.setAuth(f.Key(), f.AddrDiv(), f.Diversity())
+	case DyldChainedPtr64Rebase:
+		rf.Raw = f.Pointer
+		rf.Target = uint64(f.UnpackedTarget())
+	case DyldChainedPtr64RebaseOffset:
+		rf.Raw = f.Pointer
+		rf.Target = f.High8()<<56 | (baseAddr + f.Target())
+	case DyldChainedPtr64Bind:
+		rf.Raw = f.Pointer
+		dcf.setBind(&rf, f.Ordinal(), int64(f.Addend()))
+	case DyldChainedPtr64KernelCacheRebase:
+		rf.Raw = f.Pointer
+		rf.Target = baseAddr + f.Target()
+		rf.CacheLevel = f.CacheLevel()
+		if f.IsAuth() != 0 {
+			rf.setAuth(f.Key(), f.AddrDiv(), f.Diversity())
+		}
+	case DyldChainedPtr32Rebase:
+		rf.Raw = uint64(f.Pointer)
+		rf.Target = f.Target()
+		if maxPtr := uint64(seg.MaxValidPointer); maxPtr != 0 && rf.Target > maxPtr {
+			// not a pointer, but a small value biased to fit in the 26-bit target
+			bias := (0x04000000 + maxPtr) / 2
+			rf.Target -= bias
+		}
+	case DyldChainedPtr32Bind:
+		rf.Raw = uint64(f.Pointer)
+		dcf.setBind(&rf, f.Ordinal(), int64(f.Addend()))
+	case DyldChainedPtr32CacheRebase:
+		rf.Raw = uint64(f.Pointer)
+		rf.Target = baseAddr + f.Target()
+	case DyldChainedPtr32FirmwareRebase:
+		rf.Raw = uint64(f.Pointer)
+		rf.Target = f.Target()
+	default:
+		return rf, false
+	}
+
+	return rf, true
+}
+
+func (dcf *DyldChainedFixups) setBind(rf *ResolvedFixup, ordinal uint64, addend int64) {
+	rf.Bind = true
+	rf.Ordinal = ordinal
+	rf.Addend = addend
+	if ordinal < uint64(len(dcf.Imports)) {
+		imp := dcf.Imports[ordinal]
+		rf.Name = imp.Name
+		if imp.Import != nil {
+			rf.Addend += int64(imp.Addend())
+			rf.Weak = imp.WeakImport()
+		}
+	}
+}
+
+func (rf *ResolvedFixup) setAuth(key, addrDiv, diversity uint64) {
+	rf.Auth = true
+	rf.Key = key
+	rf.AddrDiv = addrDiv != 0
+	rf.Diversity = diversity
+}
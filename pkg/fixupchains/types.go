@@ -111,14 +111,14 @@ func (s *DyldChainedStarts) Binds() []Bind {
 	return binds
 }
 
-func stride(pointerFormat DCPtrKind) uint64 {
+func stride(pointerFormat DCPtrKind) (uint64, error) {
 	switch pointerFormat {
 	case DYLD_CHAINED_PTR_ARM64E:
 		fallthrough
 	case DYLD_CHAINED_PTR_ARM64E_USERLAND:
 		fallthrough
 	case DYLD_CHAINED_PTR_ARM64E_USERLAND24:
-		return uint64(8)
+		return uint64(8), nil
 	case DYLD_CHAINED_PTR_ARM64E_KERNEL:
 		fallthrough
 	case DYLD_CHAINED_PTR_ARM64E_FIRMWARE:
@@ -134,11 +134,11 @@ func stride(pointerFormat DCPtrKind) uint64 {
 	case DYLD_CHAINED_PTR_32_CACHE:
 		fallthrough
 	case DYLD_CHAINED_PTR_64_KERNEL_CACHE:
-		return uint64(4)
+		return uint64(4), nil
 	case DYLD_CHAINED_PTR_X86_64_KERNEL_CACHE:
-		return uint64(1)
+		return uint64(1), nil
 	default:
-		panic(fmt.Sprintf("unsupported pointer chain format: %d", pointerFormat))
+		return 0, fmt.Errorf("unsupported pointer chain format: %d", pointerFormat)
 	}
 }
 
@@ -85,12 +85,12 @@ func ReadSleb128(r *bytes.Reader) (int64, error) {
 
 		// If high order bit is 1.
 		if (b & 0x80) == 0 {
+			// sign extend the last byte
+			if (shift < 64) && ((b & 0x40) > 0) {
+				result |= -(1 << shift)
+			}
 			break
 		}
-
-		if (shift < 64) && ((b & 0x40) > 0) {
-			result |= -(1 << shift)
-		}
 	}
 
 	return result, nil
@@ -0,0 +1,60 @@
+package trie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadSleb128(t *testing.T) {
+	tests := []struct {
+		dat  []byte
+		want int64
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x02}, 2},
+		{[]byte{0x3f}, 63},
+		{[]byte{0x40}, -64},
+		{[]byte{0x78}, -8},
+		{[]byte{0x7f}, -1},
+		{[]byte{0x80, 0x01}, 128},
+		{[]byte{0xff, 0x00}, 127},
+		{[]byte{0x80, 0x7f}, -128},
+		{[]byte{0x81, 0x7f}, -127},
+		{[]byte{0xc0, 0xbb, 0x78}, -123456},
+		{[]byte{0xe5, 0x8e, 0x26}, 624485},
+	}
+	for _, tt := range tests {
+		r := bytes.NewReader(tt.dat)
+		got, err := ReadSleb128(r)
+		if err != nil {
+			t.Errorf("ReadSleb128(% x) error = %v", tt.dat, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ReadSleb128(% x) = %d, want %d", tt.dat, got, tt.want)
+		}
+		if r.Len() != 0 {
+			t.Errorf("ReadSleb128(% x) left %d bytes", tt.dat, r.Len())
+		}
+	}
+	if _, err := ReadSleb128(bytes.NewReader([]byte{0x80})); err == nil {
+		t.Error("ReadSleb128() of a truncated value should fail")
+	}
+}
+
+func TestReadUleb128(t *testing.T) {
+	tests := []struct {
+		dat  []byte
+		want uint64
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x7f}, 127},
+		{[]byte{0x80, 0x01}, 128},
+		{[]byte{0xe5, 0x8e, 0x26}, 624485},
+	}
+	for _, tt := range tests {
+		if got, err := ReadUleb128(bytes.NewReader(tt.dat)); err != nil || got != tt.want {
+			t.Errorf("ReadUleb128(% x) = %d, %v, want %d", tt.dat, got, err, tt.want)
+		}
+	}
+}
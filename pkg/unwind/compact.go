@@ -0,0 +1,503 @@
+package unwind
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+const (
+	UNWIND_SECTION_VERSION = 1
+
+	UNWIND_SECOND_LEVEL_REGULAR    = 2
+	UNWIND_SECOND_LEVEL_COMPRESSED = 3
+
+	UNWIND_IS_NOT_FUNCTION_START = 0x80000000
+	UNWIND_HAS_LSDA              = 0x40000000
+	UNWIND_PERSONALITY_MASK      = 0x30000000
+	UNWIND_MODE_MASK             = 0x0F000000
+
+	UNWIND_X86_64_MODE_RBP_FRAME                  = 0x01000000
+	UNWIND_X86_64_MODE_STACK_IMMD                 = 0x02000000
+	UNWIND_X86_64_MODE_STACK_IND                  = 0x03000000
+	UNWIND_X86_64_MODE_DWARF                      = 0x04000000
+	UNWIND_X86_64_RBP_FRAME_REGISTERS             = 0x00007FFF
+	UNWIND_X86_64_RBP_FRAME_OFFSET                = 0x00FF0000
+	UNWIND_X86_64_FRAMELESS_STACK_SIZE            = 0x00FF0000
+	UNWIND_X86_64_FRAMELESS_STACK_ADJUST          = 0x0000E000
+	UNWIND_X86_64_FRAMELESS_STACK_REG_COUNT       = 0x00001C00
+	UNWIND_X86_64_FRAMELESS_STACK_REG_PERMUTATION = 0x000003FF
+	UNWIND_X86_64_DWARF_SECTION_OFFSET            = 0x00FFFFFF
+
+	UNWIND_ARM64_MODE_FRAMELESS            = 0x02000000
+	UNWIND_ARM64_MODE_DWARF                = 0x03000000
+	UNWIND_ARM64_MODE_FRAME                = 0x04000000
+	UNWIND_ARM64_FRAME_X19_X20_PAIR        = 0x00000001
+	UNWIND_ARM64_FRAME_X21_X22_PAIR        = 0x00000002
+	UNWIND_ARM64_FRAME_X23_X24_PAIR        = 0x00000004
+	UNWIND_ARM64_FRAME_X25_X26_PAIR        = 0x00000008
+	UNWIND_ARM64_FRAME_X27_X28_PAIR        = 0x00000010
+	UNWIND_ARM64_FRAME_D8_D9_PAIR          = 0x00000100
+	UNWIND_ARM64_FRAME_D10_D11_PAIR        = 0x00000200
+	UNWIND_ARM64_FRAME_D12_D13_PAIR        = 0x00000400
+	UNWIND_ARM64_FRAME_D14_D15_PAIR        = 0x00000800
+	UNWIND_ARM64_FRAMELESS_STACK_SIZE_MASK = 0x00FFF000
+	UNWIND_ARM64_DWARF_SECTION_OFFSET      = 0x00FFFFFF
+)
+
+// Encoding is a compact unwind encoding.
+type Encoding uint32
+
+// IsNotFunctionStart returns true if the entry does not start a function (e.g. a cold part).
+func (e Encoding) IsNotFunctionStart() bool { return e&UNWIND_IS_NOT_FUNCTION_START != 0 }
+
+// HasLSDA returns true if the function has a language specific data area (e.g. C++ exception tables).
+func (e Encoding) HasLSDA() bool { return e&UNWIND_HAS_LSDA != 0 }
+
+// PersonalityIndex returns the 1-based index of the personality function, 0 if none.
+func (e Encoding) PersonalityIndex() int { return int((e & UNWIND_PERSONALITY_MASK) >> 28) }
+
+// Mode returns the CPU specific unwind mode bits.
+func (e Encoding) Mode() uint32 { return uint32(e & UNWIND_MODE_MASK) }
+
+// Mode is how a function's frame is unwound.
+type Mode uint8
+
+const (
+	ModeNone              Mode = iota // no unwind info (leaf function that does not touch the stack)
+	ModeFrame                         // frame pointer based
+	ModeFrameless                     // frameless with a fixed stack size
+	ModeFramelessIndirect             // frameless with the stack size encoded in the function's sub instruction
+	ModeDWARF                         // described by the DWARF CFI in __eh_frame
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "none"
+	case ModeFrame:
+		return "frame"
+	case ModeFrameless:
+		return "frameless"
+	case ModeFramelessIndirect:
+		return "frameless indirect"
+	case ModeDWARF:
+		return "dwarf"
+	}
+	return fmt.Sprintf("Mode(%d)", m)
+}
+
+// An Unwind is a decoded compact unwind encoding.
+type Unwind struct {
+	Mode Mode
+	// StackSize is the size of the frameless stack frame in bytes
+	StackSize uint32
+	// StackSizeOffset is the offset in the function of the 32-bit stack size
+	// immediate of its sub instruction (ModeFramelessIndirect)
+	StackSizeOffset uint32
+	// StackAdjust is added to the stack size read at StackSizeOffset (ModeFramelessIndirect)
+	StackAdjust uint32
+	// FrameOffset is the offset below the frame pointer of the saved registers, in pointer sized units (x86 ModeFrame)
+	FrameOffset uint32
+	// DWARFOffset is the offset of the function's FDE in __eh_frame (ModeDWARF)
+	DWARFOffset uint32
+	// Registers are the saved non-volatile registers
+	Registers []string
+	// SavedAt is the offset from the CFA (the stack pointer before the call)
+	// where each of Registers is saved
+	SavedAt []int64
+}
+
+func (u Unwind) String() string {
+	var s string
+	switch u.Mode {
+	case ModeFrame:
+		s = "frame"
+		if u.FrameOffset > 0 {
+			s += fmt.Sprintf(" offset=%d", u.FrameOffset)
+		}
+	case ModeFrameless:
+		s = fmt.Sprintf("frameless stack=%d", u.StackSize)
+	case ModeFramelessIndirect:
+		s = fmt.Sprintf("frameless stack=[func+%#x]+%d", u.StackSizeOffset, u.StackAdjust)
+	case ModeDWARF:
+		return fmt.Sprintf("dwarf eh_frame+%#x", u.DWARFOffset)
+	default:
+		return u.Mode.String()
+	}
+	if len(u.Registers) > 0 {
+		s += " regs=" + strings.Join(u.Registers, ",")
+	}
+	return s
+}
+
+// Decode decodes the CPU specific part of the encoding. x86_64, i386 and arm64(_32) are supported.
+func (e Encoding) Decode(cpu types.CPU) (Unwind, error) {
+	switch cpu {
+	case types.CPUAmd64:
+		return e.decodeX86([]string{"", "rbx", "r12", "r13", "r14", "r15", "rbp"}, 8), nil
+	case types.CPU386:
+		return e.decodeX86([]string{"", "ebx", "ecx", "edx", "edi", "esi", "ebp"}, 4), nil
+	case types.CPUArm64, types.CPUArm6432:
+		return e.decodeArm64(), nil
+	}
+	return Unwind{}, fmt.Errorf("unsupported CPU %s for compact unwind", cpu)
+}
+
+// decodeX86 decodes the x86_64 (and identically laid out i386) encodings.
+func (e Encoding) decodeX86(regNames []string, ptrSize uint32) Unwind {
+	var u Unwind
+	switch e.Mode() {
+	case UNWIND_X86_64_MODE_RBP_FRAME:
+		u.Mode = ModeFrame
+		u.FrameOffset = uint32(e&UNWIND_X86_64_RBP_FRAME_OFFSET) >> 16
+		// the registers are saved in 5 slots starting FrameOffset words below
+		// the frame pointer, which points at the saved frame pointer below the return address
+		ptr := int64(ptrSize)
+		regs := uint32(e & UNWIND_X86_64_RBP_FRAME_REGISTERS)
+		for i := 0; i < 5; i++ {
+			if reg := (regs >> (3 * i)) & 0x7; reg != 0 && int(reg) < len(regNames) {
+				u.Registers = append(u.Registers, regNames[reg])
+				u.SavedAt = append(u.SavedAt, -2*ptr-int64(u.FrameOffset)*ptr+int64(i)*ptr)
+			}
+		}
+	case UNWIND_X86_64_MODE_STACK_IMMD, UNWIND_X86_64_MODE_STACK_IND:
+		size := uint32(e&UNWIND_X86_64_FRAMELESS_STACK_SIZE) >> 16
+		if e.Mode() == UNWIND_X86_64_MODE_STACK_IMMD {
+			u.Mode = ModeFrameless
+			u.StackSize = size * ptrSize
+		} else {
+			u.Mode = ModeFramelessIndirect
+			u.StackSizeOffset = size
+			u.StackAdjust = (uint32(e&UNWIND_X86_64_FRAMELESS_STACK_ADJUST) >> 13) * ptrSize
+		}
+		// the registers are pushed right below the return address
+		ptr := int64(ptrSize)
+		count := int(uint32(e&UNWIND_X86_64_FRAMELESS_STACK_REG_COUNT) >> 10)
+		for i, reg := range decodePermutation(uint32(e&UNWIND_X86_64_FRAMELESS_STACK_REG_PERMUTATION), count) {
+			if reg < len(regNames) {
+				u.Registers = append(u.Registers, regNames[reg])
+				u.SavedAt = append(u.SavedAt, -ptr-int64(count)*ptr+int64(i)*ptr)
+			}
+		}
+	case UNWIND_X86_64_MODE_DWARF:
+		u.Mode = ModeDWARF
+		u.DWARFOffset = uint32(e & UNWIND_X86_64_DWARF_SECTION_OFFSET)
+	}
+	return u
+}
+
+// decodePermutation decodes the saved registers of a frameless x86 function
+// from their permutation number, as libunwind does.
+func decodePermutation(perm uint32, count int) []int {
+	if count < 1 || count > 6 {
+		return nil
+	}
+	var unreg [6]uint32
+	switch count {
+	case 6, 5:
+		unreg[0] = perm / 120
+		perm -= unreg[0] * 120
+		unreg[1] = perm / 24
+		perm -= unreg[1] * 24
+		unreg[2] = perm / 6
+		perm -= unreg[2] * 6
+		unreg[3] = perm / 2
+		perm -= unreg[3] * 2
+		unreg[4] = perm
+	case 4:
+		unreg[0] = perm / 60
+		perm -= unreg[0] * 60
+		unreg[1] = perm / 12
+		perm -= unreg[1] * 12
+		unreg[2] = perm / 3
+		perm -= unreg[2] * 3
+		unreg[3] = perm
+	case 3:
+		unreg[0] = perm / 20
+		perm -= unreg[0] * 20
+		unreg[1] = perm / 4
+		perm -= unreg[1] * 4
+		unreg[2] = perm
+	case 2:
+		unreg[0] = perm / 5
+		perm -= unreg[0] * 5
+		unreg[1] = perm
+	case 1:
+		unreg[0] = perm
+	}
+	var used [7]bool
+	regs := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		renum := uint32(0)
+		for r := 1; r < 7; r++ {
+			if used[r] {
+				continue
+			}
+			if renum == unreg[i] {
+				regs = append(regs, r)
+				used[r] = true
+				break
+			}
+			renum++
+		}
+	}
+	return regs
+}
+
+func (e Encoding) decodeArm64() Unwind {
+	var u Unwind
+	// the register pairs are saved downwards from the top of the frame,
+	// or from below the frame record (fp, lr) of frame based functions
+	loc := int64(0)
+	switch e.Mode() {
+	case UNWIND_ARM64_MODE_FRAMELESS:
+		u.Mode = ModeFrameless
+		u.StackSize = (uint32(e&UNWIND_ARM64_FRAMELESS_STACK_SIZE_MASK) >> 12) * 16
+		loc = -8
+	case UNWIND_ARM64_MODE_DWARF:
+		u.Mode = ModeDWARF
+		u.DWARFOffset = uint32(e & UNWIND_ARM64_DWARF_SECTION_OFFSET)
+		return u
+	case UNWIND_ARM64_MODE_FRAME:
+		u.Mode = ModeFrame
+		loc = -24
+	default:
+		return u
+	}
+	for _, pair := range []struct {
+		flag uint32
+		regs []string
+	}{
+		{UNWIND_ARM64_FRAME_X19_X20_PAIR, []string{"x19", "x20"}},
+		{UNWIND_ARM64_FRAME_X21_X22_PAIR, []string{"x21", "x22"}},
+		{UNWIND_ARM64_FRAME_X23_X24_PAIR, []string{"x23", "x24"}},
+		{UNWIND_ARM64_FRAME_X25_X26_PAIR, []string{"x25", "x26"}},
+		{UNWIND_ARM64_FRAME_X27_X28_PAIR, []string{"x27", "x28"}},
+		{UNWIND_ARM64_FRAME_D8_D9_PAIR, []string{"d8", "d9"}},
+		{UNWIND_ARM64_FRAME_D10_D11_PAIR, []string{"d10", "d11"}},
+		{UNWIND_ARM64_FRAME_D12_D13_PAIR, []string{"d12", "d13"}},
+		{UNWIND_ARM64_FRAME_D14_D15_PAIR, []string{"d14", "d15"}},
+	} {
+		if uint32(e)&pair.flag != 0 {
+			u.Registers = append(u.Registers, pair.regs...)
+			u.SavedAt = append(u.SavedAt, loc, loc-8)
+			loc -= 16
+		}
+	}
+	return u
+}
+
+// An IndexEntry is an entry of the first-level index of __unwind_info.
+type IndexEntry struct {
+	FunctionOffset                uint32 // offset from the image base of the first function of the page
+	SecondLevelPagesSectionOffset uint32 // 0 for the sentinel entry
+	LSDAIndexArraySectionOffset   uint32
+}
+
+// An LSDAEntry maps a function to its language specific data area.
+type LSDAEntry struct {
+	FunctionOffset uint32
+	LSDAOffset     uint32
+}
+
+// A Function is a range of code with the same compact unwind encoding.
+// ld merges adjacent functions with the same encoding, so it may span several functions.
+type Function struct {
+	Start       uint64 // VM address
+	End         uint64 // VM address of the next entry
+	Encoding    Encoding
+	Personality uint64 // VM address of the personality function pointer (GOT slot), 0 if none
+	LSDA        uint64 // VM address of the LSDA, 0 if none
+}
+
+// Info is a parsed __TEXT,__unwind_info section.
+type Info struct {
+	Version         uint32
+	CommonEncodings []Encoding
+	Personalities   []uint64 // VM addresses of the personality function pointers
+	Index           []IndexEntry
+	LSDAs           []LSDAEntry
+	Functions       []Function // sorted by Start
+}
+
+// Parse parses an __unwind_info section; base is the VM address of the image's mach header (__TEXT).
+func Parse(dat []byte, base uint64, bo binary.ByteOrder) (*Info, error) {
+	u32 := func(off uint64) (uint32, error) {
+		if off+4 > uint64(len(dat)) {
+			return 0, fmt.Errorf("unwind info offset %#x out of range", off)
+		}
+		return bo.Uint32(dat[off:]), nil
+	}
+	u16 := func(off uint64) (uint16, error) {
+		if off+2 > uint64(len(dat)) {
+			return 0, fmt.Errorf("unwind info offset %#x out of range", off)
+		}
+		return bo.Uint16(dat[off:]), nil
+	}
+
+	var hdr [7]uint32
+	for i := range hdr {
+		v, err := u32(uint64(i) * 4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unwind info header: %v", err)
+		}
+		hdr[i] = v
+	}
+	if hdr[0] != UNWIND_SECTION_VERSION {
+		return nil, fmt.Errorf("unsupported unwind info version %d", hdr[0])
+	}
+	info := &Info{Version: hdr[0]}
+
+	for i := uint64(0); i < uint64(hdr[2]); i++ {
+		v, err := u32(uint64(hdr[1]) + i*4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read common encodings: %v", err)
+		}
+		info.CommonEncodings = append(info.CommonEncodings, Encoding(v))
+	}
+	for i := uint64(0); i < uint64(hdr[4]); i++ {
+		v, err := u32(uint64(hdr[3]) + i*4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read personalities: %v", err)
+		}
+		info.Personalities = append(info.Personalities, base+uint64(v))
+	}
+	for i := uint64(0); i < uint64(hdr[6]); i++ {
+		var e [3]uint32
+		for j := range e {
+			v, err := u32(uint64(hdr[5]) + i*12 + uint64(j)*4)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read first-level index: %v", err)
+			}
+			e[j] = v
+		}
+		info.Index = append(info.Index, IndexEntry{e[0], e[1], e[2]})
+	}
+
+	// the LSDA array runs from the first index entry's LSDA offset to the sentinel's
+	if len(info.Index) > 0 {
+		start, end := uint64(info.Index[0].LSDAIndexArraySectionOffset), uint64(info.Index[len(info.Index)-1].LSDAIndexArraySectionOffset)
+		for off := start; off+8 <= end; off += 8 {
+			fn, err := u32(off)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read LSDA index: %v", err)
+			}
+			lsda, err := u32(off + 4)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read LSDA index: %v", err)
+			}
+			info.LSDAs = append(info.LSDAs, LSDAEntry{fn, lsda})
+		}
+	}
+
+	for i, idx := range info.Index {
+		if idx.SecondLevelPagesSectionOffset == 0 || i+1 >= len(info.Index) {
+			continue // sentinel
+		}
+		pageEnd := base + uint64(info.Index[i+1].FunctionOffset)
+		page := uint64(idx.SecondLevelPagesSectionOffset)
+		kind, err := u32(page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read second-level page: %v", err)
+		}
+		entryOff, err := u16(page + 4)
+		if err != nil {
+			return nil, err
+		}
+		count, err := u16(page + 6)
+		if err != nil {
+			return nil, err
+		}
+
+		var fns []Function
+		switch kind {
+		case UNWIND_SECOND_LEVEL_REGULAR:
+			for j := uint64(0); j < uint64(count); j++ {
+				fn, err := u32(page + uint64(entryOff) + j*8)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read regular second-level page: %v", err)
+				}
+				enc, err := u32(page + uint64(entryOff) + j*8 + 4)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read regular second-level page: %v", err)
+				}
+				fns = append(fns, Function{Start: base + uint64(fn), Encoding: Encoding(enc)})
+			}
+		case UNWIND_SECOND_LEVEL_COMPRESSED:
+			encOff, err := u16(page + 8)
+			if err != nil {
+				return nil, err
+			}
+			encCount, err := u16(page + 10)
+			if err != nil {
+				return nil, err
+			}
+			for j := uint64(0); j < uint64(count); j++ {
+				entry, err := u32(page + uint64(entryOff) + j*4)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read compressed second-level page: %v", err)
+				}
+				encIdx := int(entry >> 24)
+				var enc Encoding
+				switch {
+				case encIdx < len(info.CommonEncodings):
+					enc = info.CommonEncodings[encIdx]
+				case encIdx-len(info.CommonEncodings) < int(encCount):
+					v, err := u32(page + uint64(encOff) + uint64(encIdx-len(info.CommonEncodings))*4)
+					if err != nil {
+						return nil, fmt.Errorf("failed to read compressed second-level page encodings: %v", err)
+					}
+					enc = Encoding(v)
+				default:
+					return nil, fmt.Errorf("compressed second-level page encoding index %d out of range", encIdx)
+				}
+				fns = append(fns, Function{
+					Start:    base + uint64(idx.FunctionOffset) + uint64(entry&0x00FFFFFF),
+					Encoding: enc,
+				})
+			}
+		default:
+			return nil, fmt.Errorf("unknown second-level page kind %d", kind)
+		}
+
+		for j := range fns {
+			if j+1 < len(fns) {
+				fns[j].End = fns[j+1].Start
+			} else {
+				fns[j].End = pageEnd
+			}
+		}
+		info.Functions = append(info.Functions, fns...)
+	}
+
+	sort.SliceStable(info.Functions, func(i, j int) bool { return info.Functions[i].Start < info.Functions[j].Start })
+
+	for i := range info.Functions {
+		fn := &info.Functions[i]
+		if pi := fn.Encoding.PersonalityIndex(); pi > 0 && pi <= len(info.Personalities) {
+			fn.Personality = info.Personalities[pi-1]
+		}
+		if fn.Encoding.HasLSDA() {
+			off := uint32(fn.Start - base)
+			j := sort.Search(len(info.LSDAs), func(j int) bool { return info.LSDAs[j].FunctionOffset >= off })
+			if j < len(info.LSDAs) && info.LSDAs[j].FunctionOffset == off {
+				fn.LSDA = base + uint64(info.LSDAs[j].LSDAOffset)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// Lookup returns the unwind entry covering the VM address addr.
+func (i *Info) Lookup(addr uint64) (*Function, bool) {
+	j := sort.Search(len(i.Functions), func(j int) bool { return i.Functions[j].Start > addr }) - 1
+	if j < 0 || addr >= i.Functions[j].End {
+		return nil, false
+	}
+	return &i.Functions[j], true
+}
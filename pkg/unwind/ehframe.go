@@ -0,0 +1,808 @@
+package unwind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/blacktop/go-macho/pkg/trie"
+)
+
+// PointerEncoding is a DW_EH_PE pointer encoding.
+type PointerEncoding uint8
+
+const (
+	DW_EH_PE_absptr  PointerEncoding = 0x00
+	DW_EH_PE_uleb128 PointerEncoding = 0x01
+	DW_EH_PE_udata2  PointerEncoding = 0x02
+	DW_EH_PE_udata4  PointerEncoding = 0x03
+	DW_EH_PE_udata8  PointerEncoding = 0x04
+	DW_EH_PE_sleb128 PointerEncoding = 0x09
+	DW_EH_PE_sdata2  PointerEncoding = 0x0a
+	DW_EH_PE_sdata4  PointerEncoding = 0x0b
+	DW_EH_PE_sdata8  PointerEncoding = 0x0c
+
+	DW_EH_PE_pcrel   PointerEncoding = 0x10
+	DW_EH_PE_textrel PointerEncoding = 0x20
+	DW_EH_PE_datarel PointerEncoding = 0x30
+	DW_EH_PE_funcrel PointerEncoding = 0x40
+	DW_EH_PE_aligned PointerEncoding = 0x50
+
+	DW_EH_PE_indirect PointerEncoding = 0x80
+	DW_EH_PE_omit     PointerEncoding = 0xff
+)
+
+// DW_CFA call frame instructions
+const (
+	DW_CFA_nop                          = 0x00
+	DW_CFA_set_loc                      = 0x01
+	DW_CFA_advance_loc1                 = 0x02
+	DW_CFA_advance_loc2                 = 0x03
+	DW_CFA_advance_loc4                 = 0x04
+	DW_CFA_offset_extended              = 0x05
+	DW_CFA_restore_extended             = 0x06
+	DW_CFA_undefined                    = 0x07
+	DW_CFA_same_value                   = 0x08
+	DW_CFA_register                     = 0x09
+	DW_CFA_remember_state               = 0x0a
+	DW_CFA_restore_state                = 0x0b
+	DW_CFA_def_cfa                      = 0x0c
+	DW_CFA_def_cfa_register             = 0x0d
+	DW_CFA_def_cfa_offset               = 0x0e
+	DW_CFA_def_cfa_expression           = 0x0f
+	DW_CFA_expression                   = 0x10
+	DW_CFA_offset_extended_sf           = 0x11
+	DW_CFA_def_cfa_sf                   = 0x12
+	DW_CFA_def_cfa_offset_sf            = 0x13
+	DW_CFA_val_offset                   = 0x14
+	DW_CFA_val_offset_sf                = 0x15
+	DW_CFA_val_expression               = 0x16
+	DW_CFA_AARCH64_negate_ra_state      = 0x2d
+	DW_CFA_GNU_args_size                = 0x2e
+	DW_CFA_GNU_negative_offset_extended = 0x2f
+	DW_CFA_advance_loc                  = 0x40
+	DW_CFA_offset                       = 0x80
+	DW_CFA_restore                      = 0xc0
+)
+
+// EHFrameConfig describes where __eh_frame and the bases of its relative pointer encodings are.
+type EHFrameConfig struct {
+	Addr        uint64 // VM address of __eh_frame (DW_EH_PE_pcrel)
+	TextAddr    uint64 // VM address of __TEXT (DW_EH_PE_textrel)
+	DataAddr    uint64 // VM address of __DATA (DW_EH_PE_datarel)
+	PointerSize int    // size of DW_EH_PE_absptr pointers, 8 if zero
+	// ReadPointer reads the pointer at a VM address for DW_EH_PE_indirect
+	// pointers; if nil indirect pointers are left as the address of the pointer.
+	ReadPointer func(addr uint64) (uint64, error)
+}
+
+// A CIE is a DWARF Common Information Entry.
+type CIE struct {
+	Offset                uint64 // offset in __eh_frame
+	Version               uint8
+	Augmentation          string
+	CodeAlign             uint64
+	DataAlign             int64
+	ReturnAddressRegister uint64
+	// Personality is the personality function, or the address of the pointer
+	// to it if PersonalityIndirect and it could not be read
+	Personality         uint64
+	PersonalityIndirect bool
+	LSDAEncoding        PointerEncoding
+	FDEEncoding         PointerEncoding
+	SignalFrame         bool
+	Instructions        []byte
+
+	bo      binary.ByteOrder
+	ptrSize int
+}
+
+// An FDE is a DWARF Frame Description Entry.
+type FDE struct {
+	Offset       uint64 // offset in __eh_frame
+	CIE          *CIE
+	PCBegin      uint64
+	PCEnd        uint64
+	LSDA         uint64 // VM address of the LSDA (C++ exception table), 0 if none
+	Instructions []byte
+}
+
+// EHFrame is a parsed __eh_frame section.
+type EHFrame struct {
+	CIEs []*CIE
+	FDEs []*FDE // sorted by PCBegin
+
+	byOffset map[uint64]*FDE
+}
+
+type ehReader struct {
+	*bytes.Reader
+	bo  binary.ByteOrder
+	cfg *EHFrameConfig
+}
+
+func (r *ehReader) pos() uint64 { return uint64(r.Size()) - uint64(r.Len()) }
+
+func (r *ehReader) read(v interface{}) error { return binary.Read(r, r.bo, v) }
+
+func (r *ehReader) uleb() (uint64, error) { return trie.ReadUleb128(r.Reader) }
+
+func (r *ehReader) sleb() (int64, error) { return trie.ReadSleb128(r.Reader) }
+
+func (r *ehReader) cstring() (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == 0 {
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// pointer reads a pointer with the encoding enc; funcAddr is the base of DW_EH_PE_funcrel.
+func (r *ehReader) pointer(enc PointerEncoding, funcAddr uint64) (ptr uint64, indirect bool, err error) {
+	if enc == DW_EH_PE_omit {
+		return 0, false, nil
+	}
+	pc := r.cfg.Addr + r.pos()
+	if enc&0x70 == DW_EH_PE_aligned {
+		size := uint64(r.cfg.PointerSize)
+		if pad := r.pos() % size; pad != 0 {
+			if _, err := r.Seek(int64(size-pad), io.SeekCurrent); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+
+	switch enc & 0x0f {
+	case DW_EH_PE_absptr:
+		if r.cfg.PointerSize == 4 {
+			var v uint32
+			err = r.read(&v)
+			ptr = uint64(v)
+		} else {
+			err = r.read(&ptr)
+		}
+	case DW_EH_PE_uleb128:
+		ptr, err = r.uleb()
+	case DW_EH_PE_udata2:
+		var v uint16
+		err = r.read(&v)
+		ptr = uint64(v)
+	case DW_EH_PE_udata4:
+		var v uint32
+		err = r.read(&v)
+		ptr = uint64(v)
+	case DW_EH_PE_udata8:
+		err = r.read(&ptr)
+	case DW_EH_PE_sleb128:
+		var v int64
+		v, err = r.sleb()
+		ptr = uint64(v)
+	case DW_EH_PE_sdata2:
+		var v int16
+		err = r.read(&v)
+		ptr = uint64(int64(v))
+	case DW_EH_PE_sdata4:
+		var v int32
+		err = r.read(&v)
+		ptr = uint64(int64(v))
+	case DW_EH_PE_sdata8:
+		err = r.read(&ptr)
+	default:
+		return 0, false, fmt.Errorf("unsupported pointer encoding %#x", uint8(enc))
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch enc & 0x70 {
+	case DW_EH_PE_absptr, DW_EH_PE_aligned:
+	case DW_EH_PE_pcrel:
+		ptr += pc
+	case DW_EH_PE_textrel:
+		ptr += r.cfg.TextAddr
+	case DW_EH_PE_datarel:
+		ptr += r.cfg.DataAddr
+	case DW_EH_PE_funcrel:
+		ptr += funcAddr
+	default:
+		return 0, false, fmt.Errorf("unsupported pointer encoding %#x", uint8(enc))
+	}
+	if r.cfg.PointerSize == 4 {
+		ptr &= 0xffffffff
+	}
+
+	if enc&DW_EH_PE_indirect != 0 {
+		if r.cfg.ReadPointer == nil {
+			return ptr, true, nil
+		}
+		if p, err := r.cfg.ReadPointer(ptr); err == nil {
+			return p, false, nil
+		}
+		return ptr, true, nil
+	}
+	return ptr, false, nil
+}
+
+// ParseEHFrame parses an __eh_frame section.
+func ParseEHFrame(dat []byte, bo binary.ByteOrder, cfg EHFrameConfig) (*EHFrame, error) {
+	if cfg.PointerSize == 0 {
+		cfg.PointerSize = 8
+	}
+	r := &ehReader{Reader: bytes.NewReader(dat), bo: bo, cfg: &cfg}
+	eh := &EHFrame{byOffset: make(map[uint64]*FDE)}
+	cies := make(map[uint64]*CIE)
+
+	for r.Len() > 0 {
+		start := r.pos()
+		var length uint32
+		if err := r.read(&length); err != nil {
+			return nil, fmt.Errorf("failed to read entry length at %#x: %v", start, err)
+		}
+		if length == 0 { // terminator
+			break
+		}
+		size := uint64(length)
+		if length == 0xffffffff {
+			if err := r.read(&size); err != nil {
+				return nil, fmt.Errorf("failed to read entry length at %#x: %v", start, err)
+			}
+		}
+		idPos := r.pos()
+		if size > uint64(len(dat))-idPos {
+			return nil, fmt.Errorf("entry at %#x extends past the end of __eh_frame", start)
+		}
+		end := idPos + size
+		var id uint32
+		if err := r.read(&id); err != nil {
+			return nil, fmt.Errorf("failed to read CIE id at %#x: %v", start, err)
+		}
+
+		if id == 0 {
+			cie, err := r.parseCIE(start, end)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse CIE at %#x: %v", start, err)
+			}
+			cies[start] = cie
+			eh.CIEs = append(eh.CIEs, cie)
+		} else {
+			// the CIE pointer is relative to the CIE pointer field
+			cie, ok := cies[idPos-uint64(id)]
+			if !ok {
+				if uint64(id) > idPos {
+					return nil, fmt.Errorf("FDE at %#x has invalid CIE pointer %#x", start, id)
+				}
+				var err error
+				if cie, err = parseCIEAt(dat, bo, &cfg, idPos-uint64(id)); err != nil {
+					return nil, fmt.Errorf("failed to parse CIE of FDE at %#x: %v", start, err)
+				}
+				cies[cie.Offset] = cie
+				eh.CIEs = append(eh.CIEs, cie)
+			}
+			fde, err := r.parseFDE(start, end, cie)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse FDE at %#x: %v", start, err)
+			}
+			eh.FDEs = append(eh.FDEs, fde)
+			eh.byOffset[start] = fde
+		}
+
+		if _, err := r.Seek(int64(end), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(eh.FDEs, func(i, j int) bool { return eh.FDEs[i].PCBegin < eh.FDEs[j].PCBegin })
+
+	return eh, nil
+}
+
+func parseCIEAt(dat []byte, bo binary.ByteOrder, cfg *EHFrameConfig, off uint64) (*CIE, error) {
+	r := &ehReader{Reader: bytes.NewReader(dat), bo: bo, cfg: cfg}
+	if _, err := r.Seek(int64(off), io.SeekStart); err != nil {
+		return nil, err
+	}
+	var length, id uint32
+	if err := r.read(&length); err != nil {
+		return nil, err
+	}
+	size := uint64(length)
+	if length == 0xffffffff {
+		if err := r.read(&size); err != nil {
+			return nil, err
+		}
+	}
+	if size > uint64(len(dat))-r.pos() {
+		return nil, fmt.Errorf("entry at %#x extends past the end of __eh_frame", off)
+	}
+	end := r.pos() + size
+	if err := r.read(&id); err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		return nil, fmt.Errorf("entry at %#x is not a CIE", off)
+	}
+	return r.parseCIE(off, end)
+}
+
+func (r *ehReader) parseCIE(off, end uint64) (*CIE, error) {
+	cie := &CIE{
+		Offset:       off,
+		LSDAEncoding: DW_EH_PE_omit,
+		FDEEncoding:  DW_EH_PE_absptr,
+		bo:           r.bo,
+		ptrSize:      r.cfg.PointerSize,
+	}
+	var err error
+	if cie.Version, err = r.ReadByte(); err != nil {
+		return nil, err
+	}
+	if cie.Augmentation, err = r.cstring(); err != nil {
+		return nil, err
+	}
+	if cie.CodeAlign, err = r.uleb(); err != nil {
+		return nil, err
+	}
+	if cie.DataAlign, err = r.sleb(); err != nil {
+		return nil, err
+	}
+	if cie.Version == 1 {
+		ra, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		cie.ReturnAddressRegister = uint64(ra)
+	} else if cie.ReturnAddressRegister, err = r.uleb(); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(cie.Augmentation, "z") {
+		augLen, err := r.uleb()
+		if err != nil {
+			return nil, err
+		}
+		augEnd := r.pos() + augLen
+		for _, c := range cie.Augmentation[1:] {
+			switch c {
+			case 'P':
+				enc, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				if cie.Personality, cie.PersonalityIndirect, err = r.pointer(PointerEncoding(enc), 0); err != nil {
+					return nil, fmt.Errorf("failed to read personality: %v", err)
+				}
+			case 'L':
+				enc, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				cie.LSDAEncoding = PointerEncoding(enc)
+			case 'R':
+				enc, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				cie.FDEEncoding = PointerEncoding(enc)
+			case 'S':
+				cie.SignalFrame = true
+			default:
+				// unknown augmentations can be skipped thanks to the augmentation length
+			}
+		}
+		if _, err := r.Seek(int64(augEnd), io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if cie.Augmentation != "" {
+		return nil, fmt.Errorf("unsupported augmentation %q", cie.Augmentation)
+	}
+
+	if pos := r.pos(); pos <= end {
+		cie.Instructions = make([]byte, end-pos)
+		if _, err := io.ReadFull(r, cie.Instructions); err != nil {
+			return nil, err
+		}
+	}
+	return cie, nil
+}
+
+func (r *ehReader) parseFDE(off, end uint64, cie *CIE) (*FDE, error) {
+	fde := &FDE{Offset: off, CIE: cie}
+	var err error
+	if fde.PCBegin, _, err = r.pointer(cie.FDEEncoding, 0); err != nil {
+		return nil, fmt.Errorf("failed to read pc begin: %v", err)
+	}
+	// the range has the same size but no relative part
+	pcRange, _, err := r.pointer(cie.FDEEncoding&0x0f, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pc range: %v", err)
+	}
+	fde.PCEnd = fde.PCBegin + pcRange
+
+	if strings.HasPrefix(cie.Augmentation, "z") {
+		augLen, err := r.uleb()
+		if err != nil {
+			return nil, err
+		}
+		augEnd := r.pos() + augLen
+		if strings.ContainsRune(cie.Augmentation, 'L') && cie.LSDAEncoding != DW_EH_PE_omit {
+			if fde.LSDA, _, err = r.pointer(cie.LSDAEncoding, fde.PCBegin); err != nil {
+				return nil, fmt.Errorf("failed to read LSDA: %v", err)
+			}
+		}
+		if _, err := r.Seek(int64(augEnd), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	if pos := r.pos(); pos <= end {
+		fde.Instructions = make([]byte, end-pos)
+		if _, err := io.ReadFull(r, fde.Instructions); err != nil {
+			return nil, err
+		}
+	}
+	return fde, nil
+}
+
+// FDEAt returns the FDE at the given offset in __eh_frame, e.g. the DWARFOffset of a compact unwind encoding.
+func (e *EHFrame) FDEAt(offset uint64) (*FDE, bool) {
+	fde, ok := e.byOffset[offset]
+	return fde, ok
+}
+
+// Lookup returns the FDE covering the VM address pc.
+func (e *EHFrame) Lookup(pc uint64) (*FDE, bool) {
+	i := sort.Search(len(e.FDEs), func(i int) bool { return e.FDEs[i].PCBegin > pc }) - 1
+	if i < 0 || pc >= e.FDEs[i].PCEnd {
+		return nil, false
+	}
+	return e.FDEs[i], true
+}
+
+// RuleKind is the kind of rule used to recover a register.
+type RuleKind uint8
+
+const (
+	RuleUndefined     RuleKind = iota // the register cannot be recovered
+	RuleSameValue                     // the register has not been modified
+	RuleOffset                        // saved at CFA+Offset
+	RuleValOffset                     // the value is CFA+Offset
+	RuleRegister                      // saved in Register
+	RuleExpression                    // saved at the address computed by Expression
+	RuleValExpression                 // the value is computed by Expression
+	RuleCFA                           // the CFA is Register+Offset, or computed by Expression
+)
+
+// A Rule is how to recover a register (or compute the CFA) in a frame.
+type Rule struct {
+	Kind       RuleKind
+	Register   uint64
+	Offset     int64
+	Expression []byte
+}
+
+func (r Rule) String() string {
+	switch r.Kind {
+	case RuleUndefined:
+		return "undefined"
+	case RuleSameValue:
+		return "same"
+	case RuleOffset:
+		return fmt.Sprintf("[cfa%+d]", r.Offset)
+	case RuleValOffset:
+		return fmt.Sprintf("cfa%+d", r.Offset)
+	case RuleRegister:
+		return fmt.Sprintf("r%d", r.Register)
+	case RuleExpression:
+		return "[expr]"
+	case RuleValExpression:
+		return "expr"
+	case RuleCFA:
+		if r.Expression != nil {
+			return "expr"
+		}
+		return fmt.Sprintf("r%d%+d", r.Register, r.Offset)
+	}
+	return fmt.Sprintf("RuleKind(%d)", r.Kind)
+}
+
+// A Row is the unwind rules in effect from Loc to the next row.
+type Row struct {
+	Loc       uint64
+	CFA       Rule
+	Registers map[uint64]Rule // registers without a rule keep their value
+}
+
+func (r Row) String() string {
+	regs := make([]uint64, 0, len(r.Registers))
+	for reg := range r.Registers {
+		regs = append(regs, reg)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i] < regs[j] })
+	s := fmt.Sprintf("%#x: CFA=%s", r.Loc, r.CFA)
+	for _, reg := range regs {
+		s += fmt.Sprintf(" r%d=%s", reg, r.Registers[reg])
+	}
+	return s
+}
+
+func (r Row) clone() Row {
+	c := Row{Loc: r.Loc, CFA: r.CFA, Registers: make(map[uint64]Rule, len(r.Registers))}
+	for k, v := range r.Registers {
+		c.Registers[k] = v
+	}
+	return c
+}
+
+// Rows evaluates the CIE and FDE call frame instructions and returns the unwind table of the function.
+func (f *FDE) Rows() ([]Row, error) {
+	row := Row{Loc: f.PCBegin, Registers: make(map[uint64]Rule)}
+	if _, err := f.CIE.execute(f.CIE.Instructions, &row, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to evaluate CIE instructions: %v", err)
+	}
+	initial := row.clone()
+	rows, err := f.CIE.execute(f.Instructions, &row, &initial, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate FDE instructions: %v", err)
+	}
+	return append(rows, row), nil
+}
+
+// RowAt returns the unwind rules in effect at the VM address pc.
+func (f *FDE) RowAt(pc uint64) (Row, error) {
+	if pc < f.PCBegin || pc >= f.PCEnd {
+		return Row{}, fmt.Errorf("address %#x not in FDE range %#x-%#x", pc, f.PCBegin, f.PCEnd)
+	}
+	rows, err := f.Rows()
+	if err != nil {
+		return Row{}, err
+	}
+	i := sort.Search(len(rows), func(i int) bool { return rows[i].Loc > pc }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return rows[i], nil
+}
+
+// execute runs call frame instructions on row. Completed rows are appended to
+// rows; initial is the row after the CIE instructions, for DW_CFA_restore.
+func (c *CIE) execute(insns []byte, row *Row, initial *Row, rows []Row) ([]Row, error) {
+	r := bytes.NewReader(insns)
+	bo := c.bo
+	var stack []Row
+
+	advance := func(delta uint64) {
+		rows = append(rows, row.clone())
+		row.Loc += delta * c.CodeAlign
+	}
+	restore := func(reg uint64) {
+		if initial != nil {
+			if rule, ok := initial.Registers[reg]; ok {
+				row.Registers[reg] = rule
+				return
+			}
+		}
+		delete(row.Registers, reg)
+	}
+	block := func() ([]byte, error) {
+		n, err := trie.ReadUleb128(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(r.Len()) {
+			return nil, fmt.Errorf("expression length %d out of range", n)
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return b, err
+	}
+
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		var err error
+		var reg, off uint64
+		var soff int64
+
+		switch op & 0xc0 {
+		case DW_CFA_advance_loc:
+			advance(uint64(op & 0x3f))
+			continue
+		case DW_CFA_offset:
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.Registers[uint64(op&0x3f)] = Rule{Kind: RuleOffset, Offset: int64(off) * c.DataAlign}
+			continue
+		case DW_CFA_restore:
+			restore(uint64(op & 0x3f))
+			continue
+		}
+
+		switch op {
+		case DW_CFA_nop, DW_CFA_AARCH64_negate_ra_state:
+		case DW_CFA_set_loc:
+			var loc uint64
+			switch c.fdePointerSize() {
+			case 2:
+				var v uint16
+				err = binary.Read(r, bo, &v)
+				loc = uint64(v)
+			case 4:
+				var v uint32
+				err = binary.Read(r, bo, &v)
+				loc = uint64(v)
+			case 8:
+				err = binary.Read(r, bo, &loc)
+			default:
+				err = fmt.Errorf("unsupported DW_CFA_set_loc pointer encoding %#x", uint8(c.FDEEncoding))
+			}
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row.clone())
+			row.Loc = loc
+		case DW_CFA_advance_loc1:
+			var d uint8
+			if err = binary.Read(r, bo, &d); err != nil {
+				return nil, err
+			}
+			advance(uint64(d))
+		case DW_CFA_advance_loc2:
+			var d uint16
+			if err = binary.Read(r, bo, &d); err != nil {
+				return nil, err
+			}
+			advance(uint64(d))
+		case DW_CFA_advance_loc4:
+			var d uint32
+			if err = binary.Read(r, bo, &d); err != nil {
+				return nil, err
+			}
+			advance(uint64(d))
+		case DW_CFA_offset_extended, DW_CFA_val_offset:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			kind := RuleOffset
+			if op == DW_CFA_val_offset {
+				kind = RuleValOffset
+			}
+			row.Registers[reg] = Rule{Kind: kind, Offset: int64(off) * c.DataAlign}
+		case DW_CFA_offset_extended_sf, DW_CFA_val_offset_sf:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if soff, err = trie.ReadSleb128(r); err != nil {
+				return nil, err
+			}
+			kind := RuleOffset
+			if op == DW_CFA_val_offset_sf {
+				kind = RuleValOffset
+			}
+			row.Registers[reg] = Rule{Kind: kind, Offset: soff * c.DataAlign}
+		case DW_CFA_GNU_negative_offset_extended:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.Registers[reg] = Rule{Kind: RuleOffset, Offset: -int64(off) * c.DataAlign}
+		case DW_CFA_restore_extended:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			restore(reg)
+		case DW_CFA_undefined, DW_CFA_same_value:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			kind := RuleUndefined
+			if op == DW_CFA_same_value {
+				kind = RuleSameValue
+			}
+			row.Registers[reg] = Rule{Kind: kind}
+		case DW_CFA_register:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.Registers[reg] = Rule{Kind: RuleRegister, Register: off}
+		case DW_CFA_remember_state:
+			stack = append(stack, row.clone())
+		case DW_CFA_restore_state:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("DW_CFA_restore_state without DW_CFA_remember_state")
+			}
+			loc := row.Loc
+			*row = stack[len(stack)-1]
+			row.Loc = loc
+			stack = stack[:len(stack)-1]
+		case DW_CFA_def_cfa:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Register: reg, Offset: int64(off)}
+		case DW_CFA_def_cfa_sf:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			if soff, err = trie.ReadSleb128(r); err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Register: reg, Offset: soff * c.DataAlign}
+		case DW_CFA_def_cfa_register:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Register: reg, Offset: row.CFA.Offset}
+		case DW_CFA_def_cfa_offset:
+			if off, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Register: row.CFA.Register, Offset: int64(off)}
+		case DW_CFA_def_cfa_offset_sf:
+			if soff, err = trie.ReadSleb128(r); err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Register: row.CFA.Register, Offset: soff * c.DataAlign}
+		case DW_CFA_def_cfa_expression:
+			expr, err := block()
+			if err != nil {
+				return nil, err
+			}
+			row.CFA = Rule{Kind: RuleCFA, Expression: expr}
+		case DW_CFA_expression, DW_CFA_val_expression:
+			if reg, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+			expr, err := block()
+			if err != nil {
+				return nil, err
+			}
+			kind := RuleExpression
+			if op == DW_CFA_val_expression {
+				kind = RuleValExpression
+			}
+			row.Registers[reg] = Rule{Kind: kind, Expression: expr}
+		case DW_CFA_GNU_args_size:
+			if _, err = trie.ReadUleb128(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported call frame instruction %#x", op)
+		}
+	}
+	return rows, nil
+}
+
+// fdePointerSize returns the size in bytes of the FDE pointers, 0 if variable.
+func (c *CIE) fdePointerSize() int {
+	switch c.FDEEncoding & 0x0f {
+	case DW_EH_PE_udata2, DW_EH_PE_sdata2:
+		return 2
+	case DW_EH_PE_udata4, DW_EH_PE_sdata4:
+		return 4
+	case DW_EH_PE_udata8, DW_EH_PE_sdata8:
+		return 8
+	case DW_EH_PE_absptr:
+		return c.ptrSize
+	}
+	return 0
+}
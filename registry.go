@@ -0,0 +1,100 @@
+package macho
+
+import (
+	"sync"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// A LoadCmdDecoder decodes the raw bytes of a load command that NewFile does
+// not know about. The returned Load is stored in File.Loads.
+type LoadCmdDecoder func(f *File, cmd types.LoadCmd, dat []byte) (Load, error)
+
+// A SectionDecoder decodes the contents of a section. The returned value is
+// stored in the section's Decoded field.
+type SectionDecoder func(f *File, sec *Section) (interface{}, error)
+
+// Decoders is a registry of custom load command and section decoders used by NewFile.
+//
+// Load command decoders are only consulted for commands NewFile does not
+// parse itself. Section decoders are keyed by segment and section name and
+// run once all the load commands have been parsed.
+type Decoders struct {
+	mu       sync.RWMutex
+	loadCmds map[types.LoadCmd]LoadCmdDecoder
+	sections map[string]SectionDecoder
+}
+
+// NewDecoders returns an empty decoder registry.
+func NewDecoders() *Decoders {
+	return &Decoders{
+		loadCmds: make(map[types.LoadCmd]LoadCmdDecoder),
+		sections: make(map[string]SectionDecoder),
+	}
+}
+
+// DefaultDecoders is the global registry consulted after the one in FileConfig.
+var DefaultDecoders = NewDecoders()
+
+// RegisterLoadCmd registers a decoder for a load command, replacing any previous one.
+func (d *Decoders) RegisterLoadCmd(cmd types.LoadCmd, dec LoadCmdDecoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadCmds[cmd] = dec
+}
+
+// RegisterSection registers a decoder for the named section, replacing any previous one.
+func (d *Decoders) RegisterSection(segment, section string, dec SectionDecoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sections[segment+"."+section] = dec
+}
+
+func (d *Decoders) loadCmd(cmd types.LoadCmd) LoadCmdDecoder {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.loadCmds[cmd]
+}
+
+func (d *Decoders) section(segment, section string) SectionDecoder {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.sections[segment+"."+section]
+}
+
+// RegisterLoadCmdDecoder registers a load command decoder in DefaultDecoders.
+func RegisterLoadCmdDecoder(cmd types.LoadCmd, dec LoadCmdDecoder) {
+	DefaultDecoders.RegisterLoadCmd(cmd, dec)
+}
+
+// RegisterSectionDecoder registers a section decoder in DefaultDecoders.
+func RegisterSectionDecoder(segment, section string, dec SectionDecoder) {
+	DefaultDecoders.RegisterSection(segment, section, dec)
+}
+
+// decoderList is the ordered list of registries consulted by NewFile.
+type decoderList []*Decoders
+
+func (dl decoderList) loadCmd(cmd types.LoadCmd) LoadCmdDecoder {
+	for _, d := range dl {
+		if dec := d.loadCmd(cmd); dec != nil {
+			return dec
+		}
+	}
+	return nil
+}
+
+func (dl decoderList) section(segment, section string) SectionDecoder {
+	for _, d := range dl {
+		if dec := d.section(segment, section); dec != nil {
+			return dec
+		}
+	}
+	return nil
+}
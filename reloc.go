@@ -0,0 +1,458 @@
+package macho
+
+import (
+	"fmt"
+
+	"github.com/blacktop/go-macho/types"
+)
+
+// A RelocTarget is what a relocation refers to: a symbol, a section or, for
+// scattered relocations, an address.
+type RelocTarget struct {
+	Symbol  *Symbol  // set for extern relocations
+	Section *Section // set for section (non-extern) relocations, or the section containing Value
+	Value   uint64   // address of the target for scattered relocations
+}
+
+func (t RelocTarget) String() string {
+	switch {
+	case t.Symbol != nil:
+		return t.Symbol.Name
+	case t.Value != 0 && t.Section != nil:
+		return fmt.Sprintf("%#x (%s.%s)", t.Value, t.Section.Seg, t.Section.Name)
+	case t.Section != nil:
+		return fmt.Sprintf("%s.%s", t.Section.Seg, t.Section.Name)
+	}
+	return fmt.Sprintf("%#x", t.Value)
+}
+
+// A Relocation is a relocation entry decoded with the semantics of the file's CPU.
+//
+// Pairs of entries are merged into a single Relocation: an ARM64_RELOC_ADDEND
+// becomes the Addend of the entry that follows it, a *_RELOC_SUBTRACTOR and
+// its UNSIGNED entry become one relocation of Target minus Minus, and the
+// *_RELOC_PAIR entry of a SECTDIFF or HALF relocation becomes its Minus (or Other half).
+type Relocation struct {
+	Reloc                // the raw (first) entry
+	Kind    fmt.Stringer // types.RelocTypeX86_64, types.RelocTypeARM64, types.RelocTypeARM or types.RelocTypeGeneric
+	Address uint64       // address of the location to fix up
+	Target  RelocTarget  // the symbol, section or address being referenced
+	Minus   *RelocTarget // the subtracted target of SUBTRACTOR and SECTDIFF relocations
+	Addend  int64        // the explicit ARM64_RELOC_ADDEND or the value stored at the location
+	Other   uint32       // the other half of an ARM_RELOC_HALF(_SECTDIFF) pair
+	Section *Section     // the section containing the location
+}
+
+// Size returns the size in bytes of the location to fix up.
+func (r Relocation) Size() int {
+	return 1 << (r.Len & 3)
+}
+
+func (r Relocation) String() string {
+	var pcrel string
+	if r.Pcrel {
+		pcrel = " pcrel"
+	}
+	target := r.Target.String()
+	if r.Minus != nil {
+		target += " - " + r.Minus.String()
+	}
+	if r.Addend > 0 {
+		target += fmt.Sprintf(" + %#x", r.Addend)
+	} else if r.Addend < 0 {
+		target += fmt.Sprintf(" - %#x", -r.Addend)
+	}
+	return fmt.Sprintf("%#016x %-30s%s %s", r.Address, r.Kind, pcrel, target)
+}
+
+func (f *File) relocKind(typ uint8) fmt.Stringer {
+	switch f.CPU {
+	case types.CPUAmd64:
+		return types.RelocTypeX86_64(typ)
+	case types.CPUArm64, types.CPUArm6432:
+		return types.RelocTypeARM64(typ)
+	case types.CPUArm:
+		return types.RelocTypeARM(typ)
+	}
+	return types.RelocTypeGeneric(typ)
+}
+
+// relocTarget returns the symbol or section a non-scattered relocation refers to.
+func (f *File) relocTarget(r Reloc) (RelocTarget, error) {
+	if r.Scattered {
+		return RelocTarget{Value: uint64(r.Value), Section: f.FindSectionForVMAddr(uint64(r.Value))}, nil
+	}
+	if r.Extern {
+		if f.Symtab == nil || int(r.Value) >= len(f.Symtab.Syms) {
+			return RelocTarget{}, fmt.Errorf("relocation symbol index %d is out of range of the symbol table", r.Value)
+		}
+		return RelocTarget{Symbol: &f.Symtab.Syms[r.Value]}, nil
+	}
+	if r.Value == 0 { // R_ABS
+		return RelocTarget{}, nil
+	}
+	if int(r.Value) > len(f.Sections) {
+		return RelocTarget{}, fmt.Errorf("relocation section number %d is out of range (%d sections)", r.Value, len(f.Sections))
+	}
+	return RelocTarget{Section: f.Sections[r.Value-1]}, nil
+}
+
+// readAddend returns the sign extended value stored at the relocation's location.
+func (f *File) readAddend(dat []byte, off uint32, length uint8) (int64, error) {
+	size := uint32(1) << (length & 3)
+	if uint64(off)+uint64(size) > uint64(len(dat)) {
+		return 0, fmt.Errorf("relocation at offset %#x is out of range of the section data", off)
+	}
+	switch size {
+	case 1:
+		return int64(int8(dat[off])), nil
+	case 2:
+		return int64(int16(f.ByteOrder.Uint16(dat[off:]))), nil
+	case 4:
+		return int64(int32(f.ByteOrder.Uint32(dat[off:]))), nil
+	default:
+		return int64(f.ByteOrder.Uint64(dat[off:])), nil
+	}
+}
+
+// hasInPlaceAddend returns true if the relocation's addend is stored at its location
+// (as opposed to being encoded in the instruction or in an ARM64_RELOC_ADDEND).
+func (f *File) hasInPlaceAddend(r Reloc) bool {
+	switch f.CPU {
+	case types.CPUAmd64, types.CPU386:
+		return true
+	case types.CPUArm64, types.CPUArm6432:
+		typ := types.RelocTypeARM64(r.Type)
+		return typ == types.ARM64_RELOC_UNSIGNED || typ == types.ARM64_RELOC_SUBTRACTOR || typ == types.ARM64_RELOC_POINTER_TO_GOT
+	case types.CPUArm:
+		switch types.RelocTypeARM(r.Type) {
+		case types.ARM_RELOC_VANILLA, types.ARM_RELOC_SECTDIFF, types.ARM_RELOC_LOCAL_SECTDIFF, types.ARM_RELOC_PB_LA_PTR:
+			return true
+		}
+	}
+	return false
+}
+
+// isPairedReloc returns true if the relocation is a SECTDIFF style relocation followed by a *_RELOC_PAIR entry.
+func (f *File) isPairedReloc(r Reloc) bool {
+	switch f.CPU {
+	case types.CPUAmd64, types.CPUArm64, types.CPUArm6432:
+		return false
+	case types.CPUArm:
+		switch types.RelocTypeARM(r.Type) {
+		case types.ARM_RELOC_SECTDIFF, types.ARM_RELOC_LOCAL_SECTDIFF, types.ARM_RELOC_HALF, types.ARM_RELOC_HALF_SECTDIFF:
+			return true
+		}
+		return false
+	}
+	switch types.RelocTypeGeneric(r.Type) {
+	case types.GENERIC_RELOC_SECTDIFF, types.GENERIC_RELOC_LOCAL_SECTDIFF:
+		return true
+	}
+	return false
+}
+
+// GetRelocations returns the relocation entries of a section decoded for the file's CPU.
+func (f *File) GetRelocations(sec *Section) ([]Relocation, error) {
+	if len(sec.Relocs) == 0 {
+		return nil, nil
+	}
+
+	var dat []byte
+	if !sec.Flags.IsZerofill() {
+		var err error
+		if dat, err = sec.Data(); err != nil {
+			return nil, fmt.Errorf("failed to read section %s.%s data: %v", sec.Seg, sec.Name, err)
+		}
+	}
+
+	var relocs []Relocation
+	var addend *int64
+
+	for i := 0; i < len(sec.Relocs); i++ {
+		r := sec.Relocs[i]
+		rel := Relocation{
+			Reloc:   r,
+			Kind:    f.relocKind(r.Type),
+			Address: sec.Addr + uint64(r.Addr),
+			Section: sec,
+		}
+
+		switch f.CPU {
+		case types.CPUArm64, types.CPUArm6432:
+			switch types.RelocTypeARM64(r.Type) {
+			case types.ARM64_RELOC_ADDEND:
+				a := int64(int32(r.Value<<8) >> 8) // sign extend the 24-bit addend
+				addend = &a
+				continue
+			case types.ARM64_RELOC_SUBTRACTOR:
+				if err := f.pairSubtractor(sec, &rel, &i, uint8(types.ARM64_RELOC_UNSIGNED)); err != nil {
+					return nil, err
+				}
+			}
+		case types.CPUAmd64:
+			if types.RelocTypeX86_64(r.Type) == types.X86_64_RELOC_SUBTRACTOR {
+				if err := f.pairSubtractor(sec, &rel, &i, uint8(types.X86_64_RELOC_UNSIGNED)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if rel.Minus == nil {
+			target, err := f.relocTarget(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode relocation %d of section %s.%s: %v", i, sec.Seg, sec.Name, err)
+			}
+			rel.Target = target
+		}
+
+		if f.isPairedReloc(r) {
+			if i+1 >= len(sec.Relocs) {
+				return nil, fmt.Errorf("relocation %d of section %s.%s is missing its pair", i, sec.Seg, sec.Name)
+			}
+			i++
+			pair := sec.Relocs[i]
+			if f.CPU == types.CPUArm && (types.RelocTypeARM(r.Type) == types.ARM_RELOC_HALF || types.RelocTypeARM(r.Type) == types.ARM_RELOC_HALF_SECTDIFF) {
+				rel.Other = pair.Addr & 0xffff // the other half of the address is stored in the pair's r_address
+			}
+			if types.RelocTypeARM(r.Type) != types.ARM_RELOC_HALF || f.CPU != types.CPUArm {
+				rel.Minus = &RelocTarget{Value: uint64(pair.Value), Section: f.FindSectionForVMAddr(uint64(pair.Value))}
+			}
+		}
+
+		switch {
+		case addend != nil:
+			rel.Addend = *addend
+			addend = nil
+		case dat != nil && f.hasInPlaceAddend(r):
+			a, err := f.readAddend(dat, r.Addr, r.Len)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode relocation %d of section %s.%s: %v", i, sec.Seg, sec.Name, err)
+			}
+			rel.Addend = a
+			if f.CPU == types.CPUAmd64 {
+				switch types.RelocTypeX86_64(r.Type) {
+				case types.X86_64_RELOC_SIGNED_1:
+					rel.Addend++
+				case types.X86_64_RELOC_SIGNED_2:
+					rel.Addend += 2
+				case types.X86_64_RELOC_SIGNED_4:
+					rel.Addend += 4
+				}
+			}
+		}
+
+		relocs = append(relocs, rel)
+	}
+
+	if addend != nil {
+		return nil, fmt.Errorf("ARM64_RELOC_ADDEND at the end of section %s.%s has no relocation to apply to", sec.Seg, sec.Name)
+	}
+
+	return relocs, nil
+}
+
+// pairSubtractor merges a SUBTRACTOR entry with the UNSIGNED entry that must follow it.
+func (f *File) pairSubtractor(sec *Section, rel *Relocation, i *int, unsigned uint8) error {
+	if *i+1 >= len(sec.Relocs) || sec.Relocs[*i+1].Type != unsigned {
+		return fmt.Errorf("SUBTRACTOR relocation %d of section %s.%s is not followed by an UNSIGNED relocation", *i, sec.Seg, sec.Name)
+	}
+	minus, err := f.relocTarget(rel.Reloc)
+	if err != nil {
+		return fmt.Errorf("failed to decode relocation %d of section %s.%s: %v", *i, sec.Seg, sec.Name, err)
+	}
+	*i++
+	plus, err := f.relocTarget(sec.Relocs[*i])
+	if err != nil {
+		return fmt.Errorf("failed to decode relocation %d of section %s.%s: %v", *i, sec.Seg, sec.Name, err)
+	}
+	rel.Minus = &minus
+	rel.Target = plus
+	return nil
+}
+
+// A SectionLayout is the address each section of an object file is placed at
+// when applying relocations. Sections not in the layout stay at their recorded address.
+type SectionLayout map[*Section]uint64
+
+func (l SectionLayout) slide(sec *Section) uint64 {
+	if sec == nil {
+		return 0
+	}
+	if addr, ok := l[sec]; ok {
+		return addr - sec.Addr
+	}
+	return 0
+}
+
+func (l SectionLayout) addr(sec *Section) uint64 {
+	return sec.Addr + l.slide(sec)
+}
+
+// targetAddr returns the address of an extern relocation's symbol in the layout, or the
+// slide of the section that a section or scattered relocation's in-place value points into.
+func (f *File) targetAddr(t RelocTarget, layout SectionLayout) uint64 {
+	if t.Symbol != nil {
+		if !t.Symbol.Type.IsDefinedInSection() || t.Symbol.Sect == 0 || int(t.Symbol.Sect) > len(f.Sections) {
+			return t.Symbol.Value // undefined or absolute
+		}
+		return t.Symbol.Value + layout.slide(f.Sections[t.Symbol.Sect-1])
+	}
+	return layout.slide(t.Section)
+}
+
+// GetRelocatedSectionData returns the contents of a section with its relocations
+// applied, as if the file's sections were placed at the addresses in layout
+// (a nil layout keeps the addresses recorded in the section headers).
+// Undefined symbols resolve to address 0.
+//
+// Data relocations (UNSIGNED, VANILLA, SUBTRACTOR, SECTDIFF), x86 PC-relative
+// displacements and the arm64 BRANCH26, PAGE21 and PAGEOFF12 instruction relocations
+// are supported; relocations that need a GOT or TLV descriptors are not.
+func (f *File) GetRelocatedSectionData(sec *Section, layout SectionLayout) ([]byte, error) {
+	dat, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read section %s.%s data: %v", sec.Seg, sec.Name, err)
+	}
+	relocs, err := f.GetRelocations(sec)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range relocs {
+		if err := f.applyRelocation(dat, sec, r, layout); err != nil {
+			return nil, fmt.Errorf("failed to apply relocation at %#x in section %s.%s: %v", r.Address, sec.Seg, sec.Name, err)
+		}
+	}
+	return dat, nil
+}
+
+func (f *File) applyRelocation(dat []byte, sec *Section, r Relocation, layout SectionLayout) error {
+	size := uint64(r.Size())
+	if uint64(r.Addr)+size > uint64(len(dat)) {
+		return fmt.Errorf("location is out of range of the section data")
+	}
+	loc := dat[r.Addr:]
+	pc := layout.addr(sec) + uint64(r.Addr)
+	origPC := sec.Addr + uint64(r.Addr)
+
+	// the value stored at the location, without the SIGNED_N adjustment of Addend
+	content, err := f.readAddend(dat, r.Addr, r.Len)
+	if err != nil {
+		return err
+	}
+
+	var value uint64
+	switch f.CPU {
+	case types.CPUArm64, types.CPUArm6432:
+		switch types.RelocTypeARM64(r.Type) {
+		case types.ARM64_RELOC_UNSIGNED, types.ARM64_RELOC_SUBTRACTOR:
+			value = f.dataRelocValue(r, content, layout)
+		case types.ARM64_RELOC_BRANCH26:
+			ins := f.ByteOrder.Uint32(loc)
+			target := f.targetAddr(r.Target, layout) + uint64(r.Addend)
+			if !r.Extern {
+				// the in-place branch already points at the target in the recorded layout
+				imm := int64(int32(ins<<6) >> 4)
+				target = uint64(int64(origPC)+imm) + layout.slide(r.Target.Section)
+			}
+			disp := int64(target - pc)
+			f.ByteOrder.PutUint32(loc, ins&0xfc000000|uint32(disp>>2)&0x03ffffff)
+			return nil
+		case types.ARM64_RELOC_PAGE21:
+			ins := f.ByteOrder.Uint32(loc)
+			target := f.targetAddr(r.Target, layout) + uint64(r.Addend)
+			if !r.Extern {
+				imm := int64((ins>>29)&0x3|((ins>>5)&0x7ffff)<<2) << 43 >> 43
+				target = uint64(int64(origPC&^0xfff)+imm<<12) + layout.slide(r.Target.Section)
+			}
+			pages := int64(target&^0xfff-pc&^0xfff) >> 12
+			ins = ins&0x9f00001f | uint32(pages&0x3)<<29 | uint32(pages>>2&0x7ffff)<<5
+			f.ByteOrder.PutUint32(loc, ins)
+			return nil
+		case types.ARM64_RELOC_PAGEOFF12:
+			ins := f.ByteOrder.Uint32(loc)
+			var scale uint32
+			if ins&0x3b000000 == 0x39000000 { // load/store (unsigned immediate) scales the offset by the access size
+				scale = ins >> 30
+				if ins&0x04800000 == 0x04800000 { // 128-bit SIMD&FP
+					scale = 4
+				}
+			}
+			var off uint32
+			if r.Extern {
+				off = uint32(f.targetAddr(r.Target, layout) + uint64(r.Addend))
+			} else {
+				// the in-place offset is the target's page offset in the recorded layout
+				off = ((ins>>10)&0xfff)<<scale + uint32(layout.slide(r.Target.Section))
+			}
+			off = (off & 0xfff) >> scale
+			f.ByteOrder.PutUint32(loc, ins&^(0xfff<<10)|off<<10)
+			return nil
+		default:
+			return fmt.Errorf("unsupported relocation type %s", r.Kind)
+		}
+	case types.CPUAmd64:
+		switch types.RelocTypeX86_64(r.Type) {
+		case types.X86_64_RELOC_UNSIGNED, types.X86_64_RELOC_SUBTRACTOR:
+			value = f.dataRelocValue(r, content, layout)
+		case types.X86_64_RELOC_BRANCH, types.X86_64_RELOC_SIGNED,
+			types.X86_64_RELOC_SIGNED_1, types.X86_64_RELOC_SIGNED_2, types.X86_64_RELOC_SIGNED_4:
+			value = f.pcRelValue(r, content, pc, origPC, layout)
+		default:
+			return fmt.Errorf("unsupported relocation type %s", r.Kind)
+		}
+	case types.CPUArm:
+		switch types.RelocTypeARM(r.Type) {
+		case types.ARM_RELOC_VANILLA, types.ARM_RELOC_SECTDIFF, types.ARM_RELOC_LOCAL_SECTDIFF:
+			value = f.dataRelocValue(r, content, layout)
+		default:
+			return fmt.Errorf("unsupported relocation type %s", r.Kind)
+		}
+	default:
+		switch types.RelocTypeGeneric(r.Type) {
+		case types.GENERIC_RELOC_VANILLA, types.GENERIC_RELOC_SECTDIFF, types.GENERIC_RELOC_LOCAL_SECTDIFF:
+			if r.Pcrel {
+				value = f.pcRelValue(r, content, pc, origPC, layout)
+			} else {
+				value = f.dataRelocValue(r, content, layout)
+			}
+		default:
+			return fmt.Errorf("unsupported relocation type %s", r.Kind)
+		}
+	}
+
+	switch size {
+	case 1:
+		loc[0] = byte(value)
+	case 2:
+		f.ByteOrder.PutUint16(loc, uint16(value))
+	case 4:
+		f.ByteOrder.PutUint32(loc, uint32(value))
+	default:
+		f.ByteOrder.PutUint64(loc, value)
+	}
+	return nil
+}
+
+// dataRelocValue returns the value of an absolute (pointer or difference) relocation.
+func (f *File) dataRelocValue(r Relocation, content int64, layout SectionLayout) uint64 {
+	value := uint64(content) + f.targetAddr(r.Target, layout)
+	if r.Minus != nil {
+		value -= f.targetAddr(*r.Minus, layout)
+	}
+	return value
+}
+
+// pcRelValue returns the displacement of a 32-bit x86 PC-relative relocation.
+func (f *File) pcRelValue(r Relocation, content int64, pc, origPC uint64, layout SectionLayout) uint64 {
+	if r.Extern {
+		// the displacement is relative to the end of the 4 byte field (x86_64) or of the instruction
+		// (i386 stores the symbol offset from the next instruction in place)
+		if f.CPU == types.CPUAmd64 {
+			return f.targetAddr(r.Target, layout) + uint64(content) - (pc + 4)
+		}
+		return f.targetAddr(r.Target, layout) + uint64(content) - (pc - origPC)
+	}
+	// the displacement already points at the target in the recorded layout
+	return uint64(content) + origPC - pc + f.targetAddr(r.Target, layout)
+}
@@ -0,0 +1,194 @@
+package macho
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/blacktop/go-macho/pkg/fixupchains"
+	"github.com/blacktop/go-macho/types"
+)
+
+// GetStubs decodes the instructions of every symbol stub in the __stubs,
+// __auth_stubs, etc. sections to find the GOT/lazy pointer slot each stub
+// jumps through and returns a map of stub address to the name of the imported
+// symbol bound to that slot.
+//
+// The slots are resolved using the dyld chained fixups, the dyld bind info and
+// finally the indirect symbol table. The arm64 (ADRP/LDR/BR), arm64e
+// (ADRP/ADD/LDR/BRAA), arm64_32 and x86_64 (JMP *[RIP+X]) stub patterns are supported.
+func (f *File) GetStubs() (map[uint64]string, error) {
+	slots, err := f.getSlotSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	stubs := make(map[uint64]string)
+	for _, sec := range f.Sections {
+		if !sec.Flags.IsSymbolStubs() || sec.Size == 0 {
+			continue
+		}
+		dat, err := sec.Data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s.%s data: %v", sec.Seg, sec.Name, err)
+		}
+		stubSize := uint64(sec.Reserved2)
+		if stubSize == 0 {
+			stubSize = f.defaultStubSize(sec)
+		}
+		if stubSize == 0 {
+			return nil, fmt.Errorf("unsupported stub size for CPU %s in %s.%s", f.CPU, sec.Seg, sec.Name)
+		}
+		for off := uint64(0); off+stubSize <= uint64(len(dat)); off += stubSize {
+			addr := sec.Addr + off
+			slot, ok := f.decodeStub(addr, dat[off:off+stubSize])
+			if !ok {
+				continue
+			}
+			if name, ok := slots[slot]; ok {
+				stubs[addr] = name
+			}
+		}
+	}
+
+	return stubs, nil
+}
+
+// GetStubTarget returns the slot address a symbol stub at the given address jumps through.
+func (f *File) GetStubTarget(addr uint64) (uint64, error) {
+	sec := f.FindSectionForVMAddr(addr)
+	if sec == nil || !sec.Flags.IsSymbolStubs() {
+		return 0, fmt.Errorf("address %#x is not in a symbol stubs section", addr)
+	}
+	stubSize := uint64(sec.Reserved2)
+	if stubSize == 0 {
+		stubSize = f.defaultStubSize(sec)
+	}
+	if stubSize == 0 {
+		return 0, fmt.Errorf("unsupported stub size for CPU %s in %s.%s", f.CPU, sec.Seg, sec.Name)
+	}
+	start := sec.Addr + (addr-sec.Addr)/stubSize*stubSize
+	dat := make([]byte, stubSize)
+	if _, err := sec.ReadAt(dat, int64(uint64(sec.Offset)+start-sec.Addr)); err != nil {
+		return 0, fmt.Errorf("failed to read stub at %#x: %v", start, err)
+	}
+	slot, ok := f.decodeStub(start, dat)
+	if !ok {
+		return 0, fmt.Errorf("failed to decode stub at %#x", start)
+	}
+	return slot, nil
+}
+
+func (f *File) defaultStubSize(sec *Section) uint64 {
+	switch f.CPU {
+	case types.CPUAmd64:
+		return 6
+	case types.CPUArm64:
+		if (f.SubCPU&types.CpuSubtypeMask) == types.CPUSubtypeArm64E || sec.Name == "__auth_stubs" {
+			return 16
+		}
+		return 12
+	case types.CPUArm6432:
+		return 12
+	}
+	return 0
+}
+
+func (f *File) decodeStub(addr uint64, dat []byte) (uint64, bool) {
+	switch f.CPU {
+	case types.CPUAmd64:
+		return decodeStubX86_64(addr, dat)
+	case types.CPUArm64, types.CPUArm6432:
+		return decodeStubArm64(addr, dat, f.ByteOrder)
+	}
+	return 0, false
+}
+
+// decodeStubX86_64 decodes a `jmp *disp32(%rip)` stub.
+func decodeStubX86_64(addr uint64, dat []byte) (uint64, bool) {
+	if len(dat) < 6 || dat[0] != 0xff || dat[1] != 0x25 {
+		return 0, false
+	}
+	disp := int32(binary.LittleEndian.Uint32(dat[2:]))
+	return uint64(int64(addr) + 6 + int64(disp)), true
+}
+
+// decodeStubArm64 decodes the arm64 stub patterns:
+//
+//	adrp x16, page ; ldr x16, [x16, pageoff] ; br x16
+//	adrp x17, page ; add x17, x17, pageoff ; ldr x16, [x17] ; braa x16, x17
+//	adrp x16, page ; ldr w16, [x16, pageoff] ; br x16 (arm64_32)
+func decodeStubArm64(addr uint64, dat []byte, bo binary.ByteOrder) (uint64, bool) {
+	if len(dat) < 12 {
+		return 0, false
+	}
+	adrp := bo.Uint32(dat[0:])
+	if adrp&0x9f000000 != 0x90000000 {
+		return 0, false
+	}
+	reg := adrp & 0x1f
+	imm := int64((adrp>>29)&0x3 | ((adrp>>5)&0x7ffff)<<2)
+	imm = imm << 43 >> 43 // sign extend the 21-bit immediate
+	target := uint64(int64(addr&^0xfff) + imm<<12)
+
+	next := bo.Uint32(dat[4:])
+	if next&0xff800000 == 0x91000000 && (next>>5)&0x1f == reg { // add xN, xN, #imm{, lsl #12}
+		off := uint64((next >> 10) & 0xfff)
+		if (next>>22)&1 == 1 {
+			off <<= 12
+		}
+		target += off
+		reg = next & 0x1f
+		next = bo.Uint32(dat[8:])
+	}
+	if (next>>5)&0x1f != reg {
+		return 0, false
+	}
+	switch next & 0xffc00000 {
+	case 0xf9400000: // ldr xN, [xM, #imm]
+		return target + uint64((next>>10)&0xfff)*8, true
+	case 0xb9400000: // ldr wN, [xM, #imm]
+		return target + uint64((next>>10)&0xfff)*4, true
+	}
+	return 0, false
+}
+
+// getSlotSymbols returns a map of GOT/lazy pointer slot address to the name of the symbol bound to it.
+func (f *File) getSlotSymbols() (map[uint64]string, error) {
+	slots := make(map[uint64]string)
+
+	if isyms, err := f.GetIndirectSymbols(); err == nil {
+		for _, isym := range isyms {
+			if isym.Symbol != nil && !isym.Section.Flags.IsSymbolStubs() {
+				slots[isym.Addr] = isym.Symbol.Name
+			}
+		}
+	}
+
+	if binds, err := f.GetBindInfo(); err == nil {
+		for _, bind := range binds {
+			slots[bind.Start+bind.Offset] = bind.Name
+		}
+	}
+
+	if f.HasFixups() {
+		dcf, err := f.DyldChainedFixups()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse dyld chained fixups: %v", err)
+		}
+		for _, start := range dcf.Starts {
+			for _, fixup := range start.Fixups {
+				bind, ok := fixup.(fixupchains.Bind)
+				if !ok {
+					continue
+				}
+				addr, err := f.GetVMAddress(bind.Offset())
+				if err != nil {
+					continue
+				}
+				slots[addr] = bind.Name()
+			}
+		}
+	}
+
+	return slots, nil
+}
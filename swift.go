@@ -60,10 +60,8 @@ func (f *File) GetSwiftProtocols() ([]types.Protocol, error) {
 				if err != nil {
 					return nil, fmt.Errorf("failed to read protocols requirements in signature : %v", err)
 				}
-				fmt.Printf("%s: %s\n", name, proto.SignatureRequirements[0].Flags)
 				switch proto.SignatureRequirements[0].Flags.Kind() {
 				case types.GRKindProtocol:
-					fmt.Println("protocol")
 					off := currentOffset + 8 + int64(proto.SignatureRequirements[0].TypeOrProtocolOrConformanceOrLayout)
 					_ = off
 					var ptr uint64
@@ -85,12 +83,8 @@ func (f *File) GetSwiftProtocols() ([]types.Protocol, error) {
 							return nil, fmt.Errorf("failed to read protocol name: %v", err)
 						}
 					}
-				case types.GRKindSameType:
-					fmt.Println("same type")
-				case types.GRKindSameConformance:
-					fmt.Println("same conformance")
-				case types.GRKindLayout:
-					fmt.Println("layout")
+				default:
+					f.warn(DiagSwift, currentOffset, "unsupported generic requirement %s for %s", proto.SignatureRequirements[0].Flags, name)
 				}
 				f.cr.Seek(currentOffset+int64(binary.Size(proto.SignatureRequirements)), io.SeekStart)
 			}
@@ -122,7 +116,7 @@ func (f *File) GetSwiftProtocols() ([]types.Protocol, error) {
 				}
 
 				if pcDesc.ParentOffset != 0 { // TODO: what if parent has parent ?
-					fmt.Printf("found a grand parent while parsing %s", proto.Parent) // FIXME: if this happens this should be recursive
+					f.warn(DiagSwift, parentOffset, "found a grand parent while parsing %s", proto.Parent) // FIXME: if this happens this should be recursive
 				}
 			}
 
@@ -213,12 +207,8 @@ func (f *File) GetSwiftProtocolConformances() ([]types.ConformanceDescriptor, er
 				if err != nil {
 					return nil, fmt.Errorf("failed to read type: %v", err)
 				}
-			case types.IndirectTypeDescriptor:
-				fmt.Println("SUP - IndirectTypeDescriptor")
-			case types.DirectObjCClassName:
-				fmt.Println("SUP - DirectObjCClassName")
-			case types.IndirectObjCClass:
-				fmt.Println("SUP - IndirectObjCClass")
+			case types.IndirectTypeDescriptor, types.DirectObjCClassName, types.IndirectObjCClass:
+				f.warn(DiagSwift, offset, "unsupported protocol conformance type reference kind %s", pcd.Flags.GetTypeReferenceKind())
 			}
 
 			protoConfDescs = append(protoConfDescs, pcd)
@@ -287,8 +277,6 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 
 	typ.Kind = tDesc.Flags.Kind()
 
-	fmt.Println(tDesc.Flags)
-
 	var metadataInitSize int
 
 	switch tDesc.Flags.KindSpecific().MetadataInitialization() {
@@ -299,7 +287,6 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 	case types.MetadataInitForeign:
 		metadataInitSize = binary.Size(types.TargetForeignMetadataInitialization{})
 	}
-	fmt.Println("metadataInitSize: ", metadataInitSize)
 	_ = metadataInitSize // TODO: use this in size/offset calculations
 
 	switch typ.Kind {
@@ -337,7 +324,6 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 			if cD.Flags.KindSpecific().HasResilientSuperclass() {
 				cD.FieldOffsetVectorOffset += cD.MetadataNegativeSizeInWords
 			}
-			fmt.Printf("FieldOffsetVectorOffset: %d\n", offset+int64(cD.FieldOffsetVectorOffset*8))
 			typ.FieldOffsets = make([]int32, cD.NumFields)
 			if err := binary.Read(f.cr, f.ByteOrder, &typ.FieldOffsets); err != nil {
 				return nil, fmt.Errorf("failed to read field offset vector: %v", err)
@@ -368,9 +354,6 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 			}
 			typ.Generic = &g
 		}
-		if eD.NumPayloadCasesAndPayloadSizeOffset != 0 {
-			fmt.Println("NumPayloadCasesAndPayloadSizeOffset: ", eD.NumPayloadCasesAndPayloadSizeOffset)
-		}
 		typ.Type = &eD
 	case types.CDKindStruct:
 		var sD types.TargetStructDescriptor
@@ -399,7 +382,7 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 			if err := binary.Read(f.cr, f.ByteOrder, &md); err != nil {
 				return nil, fmt.Errorf("failed to read singleton metadata initialization: %v", err)
 			}
-			fmt.Println(md)
+			_ = md // TODO: use this
 		}
 		typ.Type = &sD
 	case types.CDKindProtocol:
@@ -440,28 +423,6 @@ func (f *File) readType(offset int64) (*types.TypeDescriptor, error) {
 
 	typ.AccessFunction = uint64(int64(typ.Address) + int64(sizeOfInt32*3) + int64(tDesc.AccessFunctionPtr))
 
-	if typ.VTable != nil {
-		fmt.Println("METHODS")
-		for idx, m := range typ.VTable.GetMethods(f.preferredLoadAddress()) {
-			fmt.Printf("%2d)  flags:   %s\n", idx, m.Flags)
-			if m.Flags.IsAsync() {
-				fmt.Println("ASYNC")
-			}
-			var sym string
-			syms, _ := f.FindAddressSymbols(m.Address)
-			if len(syms) > 0 {
-				for _, s := range syms {
-					if !s.Type.IsDebugSym() {
-						sym = s.Name
-						break
-					}
-				}
-			}
-			// fmt.Printf("      impl:    %d\n", m.Impl)
-			fmt.Printf("      address: %#x\nsym: %s\n", m.Address, sym)
-		}
-	}
-
 	if tDesc.FieldsOffset != 0 {
 		offset += int64(sizeOfInt32*4) + int64(tDesc.FieldsOffset)
 		fd, err := f.readField(offset, typ.FieldOffsets...)
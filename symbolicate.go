@@ -0,0 +1,278 @@
+package macho
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blacktop/go-dwarf"
+)
+
+// SymbolicateConfig is a Symbolicate configuration.
+type SymbolicateConfig struct {
+	// LoadAddress is the address __TEXT was loaded at; addresses are slid back
+	// by LoadAddress - __TEXT.vmaddr. Zero means the addresses are not slid.
+	LoadAddress uint64
+	// DWARF is used instead of the file's own DWARF, e.g. the DWARF of a
+	// paired dSYM (see DSYM.DWARF or File.FindDSYMDWARF).
+	DWARF *dwarf.Data
+}
+
+// A SymbolicatedFrame is a function of the inline stack of a symbolicated address.
+type SymbolicatedFrame struct {
+	Function string
+	File     string // source file, empty if unknown
+	Line     int
+	Column   int
+	Inlined  bool // the frame was inlined into the next one
+}
+
+func (s SymbolicatedFrame) String() string {
+	if s.File == "" {
+		return s.Function
+	}
+	if s.Column > 0 {
+		return fmt.Sprintf("%s (%s:%d:%d)", s.Function, s.File, s.Line, s.Column)
+	}
+	return fmt.Sprintf("%s (%s:%d)", s.Function, s.File, s.Line)
+}
+
+// A Symbolication is an address resolved to its function and source location.
+type Symbolication struct {
+	Address  uint64 // unslid address
+	Function string // function (or closest symbol) containing the address
+	Offset   uint64 // offset of the address from the start of Function
+	// Frames is the inline stack, innermost first like `atos -i`;
+	// the last frame is the function the code was inlined into.
+	Frames []SymbolicatedFrame
+}
+
+func (s Symbolication) String() string {
+	var lines []string
+	for i, frame := range s.Frames {
+		if i == len(s.Frames)-1 && s.Offset > 0 && frame.File == "" {
+			lines = append(lines, fmt.Sprintf("%s + %d", frame.Function, s.Offset))
+			continue
+		}
+		lines = append(lines, frame.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Symbolicate resolves addr to the function containing it, its offset and,
+// when DWARF is available, its source file, line, column and inline stack.
+//
+// The function is found using the DWARF subprograms, then the symtab,
+// exports and function starts (see SymbolIndex).
+func (f *File) Symbolicate(addr uint64, config ...SymbolicateConfig) (*Symbolication, error) {
+	var cfg SymbolicateConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	if cfg.LoadAddress != 0 {
+		text := f.Segment("__TEXT")
+		if text == nil {
+			return nil, fmt.Errorf("failed to slide address: no __TEXT segment")
+		}
+		addr -= cfg.LoadAddress - text.Addr
+	}
+
+	s := &Symbolication{Address: addr}
+	if sym, off, ok := f.SymbolIndex().Closest(addr); ok && (sym.Size == 0 || off < sym.Size) {
+		s.Function = sym.Name
+		s.Offset = off
+	}
+
+	d := cfg.DWARF
+	if d == nil {
+		d = f.cachedDWARF()
+	}
+	if d != nil {
+		frames, lowpc, err := dwarfInlineStack(d, addr)
+		if err != nil {
+			return nil, err
+		}
+		if len(frames) > 0 {
+			s.Frames = frames
+			if outer := frames[len(frames)-1]; outer.Function != "" && lowpc != 0 {
+				s.Function = outer.Function
+				s.Offset = addr - lowpc
+			} else if outer.Function == "" {
+				s.Frames[len(frames)-1].Function = s.Function
+			}
+		}
+	}
+
+	if s.Function == "" {
+		return nil, fmt.Errorf("no symbol found for address %#x", addr)
+	}
+	if len(s.Frames) == 0 {
+		s.Frames = []SymbolicatedFrame{{Function: s.Function}}
+	}
+
+	return s, nil
+}
+
+// cachedDWARF returns the file's own DWARF, read once, or nil if it has none.
+func (f *File) cachedDWARF() *dwarf.Data {
+	f.dwarfOnce.Do(func() {
+		if f.Section("__DWARF", "__debug_info") != nil {
+			f.dwarf, _ = f.DWARF()
+		}
+	})
+	return f.dwarf
+}
+
+// dwarfInlineStack returns the inline stack of pc, innermost first, and the low pc of the outermost function.
+func dwarfInlineStack(d *dwarf.Data, pc uint64) ([]SymbolicatedFrame, uint64, error) {
+	r := d.Reader()
+	cu, err := r.SeekPC(pc)
+	if err == dwarf.ErrUnknownPC || cu == nil {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("failed to find compile unit for %#x: %v", pc, err)
+	}
+
+	chain, err := inlineChain(d, r, pc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var files []*dwarf.LineFile
+	var loc dwarf.LineEntry
+	var hasLoc bool
+	if lr, err := d.LineReader(cu); err == nil && lr != nil {
+		files = lr.Files()
+		hasLoc = lr.SeekPC(pc, &loc) == nil
+	}
+
+	// the location of the innermost frame is the line table's, the location of
+	// each outer frame is the call site of the frame inlined into it
+	frames := make([]SymbolicatedFrame, 0, len(chain)+1)
+	var frame SymbolicatedFrame
+	if hasLoc && loc.File != nil {
+		frame.File = loc.File.Name
+		frame.Line = loc.Line
+		frame.Column = loc.Column
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		e := chain[i]
+		frame.Function = dieName(d, e, 0)
+		frame.Inlined = e.Tag == dwarf.TagInlinedSubroutine
+		frames = append(frames, frame)
+
+		frame = SymbolicatedFrame{}
+		if idx, ok := e.Val(dwarf.AttrCallFile).(int64); ok && idx >= 0 && int(idx) < len(files) && files[idx] != nil {
+			frame.File = files[idx].Name
+		}
+		if line, ok := e.Val(dwarf.AttrCallLine).(int64); ok {
+			frame.Line = int(line)
+		}
+		if col, ok := e.Val(dwarf.AttrCallColumn).(int64); ok {
+			frame.Column = int(col)
+		}
+	}
+	if len(chain) == 0 && frame.File != "" { // a line but no function DIE
+		frames = append(frames, frame)
+	}
+
+	var lowpc uint64
+	if len(chain) > 0 {
+		if ranges, err := d.Ranges(chain[0]); err == nil && len(ranges) > 0 {
+			lowpc = ranges[0][0]
+			for _, rng := range ranges {
+				if rng[0] < lowpc {
+					lowpc = rng[0]
+				}
+			}
+		}
+	}
+
+	return frames, lowpc, nil
+}
+
+// inlineChain returns the subprogram containing pc and the inlined subroutines
+// containing pc nested in it, outermost first. r must be positioned at the
+// children of the compile unit.
+func inlineChain(d *dwarf.Data, r *dwarf.Reader, pc uint64) ([]*dwarf.Entry, error) {
+	var chain []*dwarf.Entry
+	depth := 0
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DWARF entry: %v", err)
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == 0 { // end of the children of an entry
+			if len(chain) > 0 || depth == 0 {
+				break // nothing nested deeper contains pc
+			}
+			depth--
+			continue
+		}
+		switch e.Tag {
+		case dwarf.TagSubprogram, dwarf.TagInlinedSubroutine, dwarf.TagLexDwarfBlock:
+			ranges, err := d.Ranges(e)
+			if err == nil && rangesContain(ranges, pc) {
+				if e.Tag != dwarf.TagLexDwarfBlock {
+					chain = append(chain, e)
+				}
+				if !e.Children {
+					return chain, nil
+				}
+				depth++
+				continue
+			}
+		case dwarf.TagNamespace, dwarf.TagModule:
+			if e.Children && len(chain) == 0 {
+				depth++
+				continue
+			}
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+	return chain, nil
+}
+
+func rangesContain(ranges [][2]uint64, pc uint64) bool {
+	for _, rng := range ranges {
+		if pc >= rng[0] && pc < rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// dieName returns the name of a subprogram or inlined subroutine DIE,
+// following DW_AT_abstract_origin and DW_AT_specification.
+func dieName(d *dwarf.Data, e *dwarf.Entry, depth int) string {
+	if name, ok := e.Val(dwarf.AttrName).(string); ok {
+		return name
+	}
+	if name, ok := e.Val(dwarf.AttrLinkageName).(string); ok {
+		return name
+	}
+	if depth > 8 {
+		return ""
+	}
+	for _, attr := range []dwarf.Attr{dwarf.AttrAbstractOrigin, dwarf.AttrSpecification} {
+		off, ok := e.Val(attr).(dwarf.Offset)
+		if !ok {
+			continue
+		}
+		r := d.Reader()
+		r.Seek(off)
+		origin, err := r.Next()
+		if err != nil || origin == nil {
+			continue
+		}
+		if name := dieName(d, origin, depth+1); name != "" {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package macho
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SymbolSource is where a symbol in the SymbolIndex came from.
+type SymbolSource uint8
+
+const (
+	SymbolSourceSymtab        SymbolSource = iota // LC_SYMTAB
+	SymbolSourceExport                            // LC_DYLD_EXPORTS_TRIE or LC_DYLD_INFO export trie
+	SymbolSourceFunctionStart                     // LC_FUNCTION_STARTS (unnamed functions)
+)
+
+func (s SymbolSource) String() string {
+	switch s {
+	case SymbolSourceSymtab:
+		return "symtab"
+	case SymbolSourceExport:
+		return "export"
+	case SymbolSourceFunctionStart:
+		return "function start"
+	}
+	return fmt.Sprintf("SymbolSource(%d)", s)
+}
+
+// An IndexedSymbol is a named address in the SymbolIndex.
+type IndexedSymbol struct {
+	Name   string
+	Addr   uint64
+	Size   uint64 // distance to the next symbol or the end of its function/section, 0 if unknown
+	Source SymbolSource
+	Symbol *Symbol // the symtab entry, if Source is SymbolSourceSymtab
+}
+
+func (s IndexedSymbol) String() string {
+	return fmt.Sprintf("%#016x %s", s.Addr, s.Name)
+}
+
+// A SymbolIndex is a sorted index of the symbols of a File for fast lookups by
+// name, name prefix and address.
+type SymbolIndex struct {
+	byAddr []IndexedSymbol // sorted by address
+	byName []int           // indices into byAddr sorted by name
+}
+
+// SymbolIndex returns the file's symbol index, building it on first use from
+// the symtab, the export trie and the function starts.
+func (f *File) SymbolIndex() *SymbolIndex {
+	f.symIndexOnce.Do(func() {
+		f.symIndex = f.buildSymbolIndex()
+	})
+	return f.symIndex
+}
+
+func (f *File) buildSymbolIndex() *SymbolIndex {
+	idx := &SymbolIndex{}
+
+	type key struct {
+		name string
+		addr uint64
+	}
+	seen := make(map[key]bool)
+	named := make(map[uint64]bool)
+	add := func(s IndexedSymbol) {
+		k := key{s.Name, s.Addr}
+		if s.Name == "" || seen[k] {
+			return
+		}
+		seen[k] = true
+		named[s.Addr] = true
+		idx.byAddr = append(idx.byAddr, s)
+	}
+
+	if f.Symtab != nil {
+		for i := range f.Symtab.Syms {
+			sym := &f.Symtab.Syms[i]
+			if sym.Type.IsDebugSym() || !sym.Type.IsDefinedInSection() {
+				continue
+			}
+			add(IndexedSymbol{Name: sym.Name, Addr: sym.Value, Source: SymbolSourceSymtab, Symbol: sym})
+		}
+	}
+
+	exports, err := f.DyldExports()
+	if err != nil {
+		exports, _ = f.GetExports()
+	}
+	for _, e := range exports {
+		if e.Flags.ReExport() {
+			continue
+		}
+		add(IndexedSymbol{Name: e.Name, Addr: e.Address, Source: SymbolSourceExport})
+	}
+
+	funcEnds := make(map[uint64]uint64)
+	for _, fn := range f.GetFunctions() {
+		funcEnds[fn.StartAddr] = fn.EndAddr
+		if !named[fn.StartAddr] {
+			add(IndexedSymbol{Name: fmt.Sprintf("func_%x", fn.StartAddr), Addr: fn.StartAddr, Source: SymbolSourceFunctionStart})
+		}
+	}
+
+	sort.SliceStable(idx.byAddr, func(i, j int) bool { return idx.byAddr[i].Addr < idx.byAddr[j].Addr })
+
+	// size each symbol up to the next address, its function's end or its section's end
+	for i := range idx.byAddr {
+		s := &idx.byAddr[i]
+		end, ok := funcEnds[s.Addr]
+		if !ok {
+			if sec := f.FindSectionForVMAddr(s.Addr); sec != nil {
+				end = sec.Addr + sec.Size
+			}
+		}
+		j := sort.Search(len(idx.byAddr), func(j int) bool { return idx.byAddr[j].Addr > s.Addr })
+		if j < len(idx.byAddr) && (end == 0 || idx.byAddr[j].Addr < end) {
+			end = idx.byAddr[j].Addr
+		}
+		if end > s.Addr {
+			s.Size = end - s.Addr
+		}
+	}
+
+	idx.byName = make([]int, len(idx.byAddr))
+	for i := range idx.byName {
+		idx.byName[i] = i
+	}
+	sort.SliceStable(idx.byName, func(i, j int) bool { return idx.byAddr[idx.byName[i]].Name < idx.byAddr[idx.byName[j]].Name })
+
+	return idx
+}
+
+// Len returns the number of symbols in the index.
+func (idx *SymbolIndex) Len() int {
+	return len(idx.byAddr)
+}
+
+// Symbols returns all the symbols sorted by address.
+func (idx *SymbolIndex) Symbols() []IndexedSymbol {
+	return idx.byAddr
+}
+
+// Lookup returns the symbols with the given (case-sensitive) name.
+func (idx *SymbolIndex) Lookup(name string) []IndexedSymbol {
+	var syms []IndexedSymbol
+	i := sort.Search(len(idx.byName), func(i int) bool { return idx.byAddr[idx.byName[i]].Name >= name })
+	for ; i < len(idx.byName) && idx.byAddr[idx.byName[i]].Name == name; i++ {
+		syms = append(syms, idx.byAddr[idx.byName[i]])
+	}
+	return syms
+}
+
+// Prefix returns the symbols whose name starts with prefix, sorted by name.
+func (idx *SymbolIndex) Prefix(prefix string) []IndexedSymbol {
+	var syms []IndexedSymbol
+	i := sort.Search(len(idx.byName), func(i int) bool { return idx.byAddr[idx.byName[i]].Name >= prefix })
+	for ; i < len(idx.byName) && strings.HasPrefix(idx.byAddr[idx.byName[i]].Name, prefix); i++ {
+		syms = append(syms, idx.byAddr[idx.byName[i]])
+	}
+	return syms
+}
+
+// AtAddress returns the symbols at exactly the given address.
+func (idx *SymbolIndex) AtAddress(addr uint64) []IndexedSymbol {
+	var syms []IndexedSymbol
+	i := sort.Search(len(idx.byAddr), func(i int) bool { return idx.byAddr[i].Addr >= addr })
+	for ; i < len(idx.byAddr) && idx.byAddr[i].Addr == addr; i++ {
+		syms = append(syms, idx.byAddr[i])
+	}
+	return syms
+}
+
+// Closest returns the symbol at or below addr and the offset of addr from it.
+// It returns false if there is no symbol at or below addr.
+func (idx *SymbolIndex) Closest(addr uint64) (IndexedSymbol, uint64, bool) {
+	i := sort.Search(len(idx.byAddr), func(i int) bool { return idx.byAddr[i].Addr > addr }) - 1
+	if i < 0 {
+		return IndexedSymbol{}, 0, false
+	}
+	// prefer the first symbol at that address (symtab entries come first)
+	for i > 0 && idx.byAddr[i-1].Addr == idx.byAddr[i].Addr {
+		i--
+	}
+	return idx.byAddr[i], addr - idx.byAddr[i].Addr, true
+}
+
+// Symbolicate returns addr as "symbol+offset", or the address in hex if no symbol is at or below it.
+func (idx *SymbolIndex) Symbolicate(addr uint64) string {
+	sym, off, ok := idx.Closest(addr)
+	if !ok {
+		return fmt.Sprintf("%#x", addr)
+	}
+	if off == 0 {
+		return sym.Name
+	}
+	return fmt.Sprintf("%s+%#x", sym.Name, off)
+}
@@ -13,8 +13,10 @@ type LoadCmd uint32
 
 func (c LoadCmd) Command() LoadCmd { return c }
 
+// Put is not implemented for a bare load command; it writes nothing and returns 0
+// so callers can fall back to the command's raw bytes.
 func (c LoadCmd) Put(b []byte, o binary.ByteOrder) int {
-	panic(fmt.Sprintf("Put not implemented for %s", c.String()))
+	return 0
 }
 
 const (
@@ -77,6 +79,10 @@ const (
 	LC_DYLD_EXPORTS_TRIE        LoadCmd = (0x33 | LC_REQ_DYLD) // used with linkedit_data_command, payload is trie
 	LC_DYLD_CHAINED_FIXUPS      LoadCmd = (0x34 | LC_REQ_DYLD) // used with linkedit_data_command
 	LC_FILESET_ENTRY            LoadCmd = (0x35 | LC_REQ_DYLD) /* used with fileset_entry_command */
+	LC_ATOM_INFO                LoadCmd = 0x36                 // used with linkedit_data_command
+	LC_FUNCTION_VARIANTS        LoadCmd = 0x37                 // used with linkedit_data_command
+	LC_FUNCTION_VARIANT_FIXUPS  LoadCmd = 0x38                 // used with linkedit_data_command
+	LC_TARGET_TRIPLE            LoadCmd = 0x39                 // target triple used to compile
 )
 
 type SegFlag uint32
@@ -173,8 +179,8 @@ type SymsegCommand struct {
 type Thread struct {
 	LoadCmd // LC_THREAD
 	Len     uint32
-	Type    uint32
-	Data    []uint32
+	Type    uint32   // flavor of the first thread state
+	Data    []uint32 // count and state words that follow Type
 }
 
 // A UnixThreadCmd is a Mach-O unix thread command.
@@ -240,6 +246,12 @@ type DysymtabCmd struct {
 	Nlocrel        uint32
 }
 
+// Special values of an indirect symbol table entry
+const (
+	INDIRECT_SYMBOL_LOCAL uint32 = 0x80000000 // the symbol was strip(1)ed and is local
+	INDIRECT_SYMBOL_ABS   uint32 = 0x40000000 // the symbol was strip(1)ed and is absolute
+)
+
 // A DylibCmd is a Mach-O load dynamic library command.
 // LC_ID_DYLIB, LC_LOAD_{,WEAK_}DYLIB,LC_REEXPORT_DYLIB
 type DylibCmd struct {
@@ -522,6 +534,20 @@ type DyldExportsTrieCmd LinkEditDataCmd // LC_DYLD_EXPORTS_TRIE
 // A DyldChainedFixupsCmd is used with linkedit_data_command command.
 type DyldChainedFixupsCmd LinkEditDataCmd // LC_DYLD_CHAINED_FIXUPS
 
+// A AtomInfoCmd is used with linkedit_data_command command.
+type AtomInfoCmd LinkEditDataCmd // LC_ATOM_INFO
+// A FunctionVariantsCmd is used with linkedit_data_command command.
+type FunctionVariantsCmd LinkEditDataCmd // LC_FUNCTION_VARIANTS
+// A FunctionVariantFixupsCmd is used with linkedit_data_command command.
+type FunctionVariantFixupsCmd LinkEditDataCmd // LC_FUNCTION_VARIANT_FIXUPS
+
+// A TargetTripleCmd contains the target triple the binary was compiled for.
+type TargetTripleCmd struct {
+	LoadCmd        // LC_TARGET_TRIPLE
+	Len     uint32 // includes string
+	Triple  uint32 // offset to target triple string
+}
+
 // FilesetEntryCmd commands describe constituent Mach-O files that are part
 // of a fileset. In one implementation, entries are dylibs with individual
 // mach headers and repositionable text and data segments. Each entry is
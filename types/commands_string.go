@@ -63,9 +63,13 @@ func _() {
 	_ = x[LC_DYLD_EXPORTS_TRIE-2147483699]
 	_ = x[LC_DYLD_CHAINED_FIXUPS-2147483700]
 	_ = x[LC_FILESET_ENTRY-2147483701]
+	_ = x[LC_ATOM_INFO-54]
+	_ = x[LC_FUNCTION_VARIANTS-55]
+	_ = x[LC_FUNCTION_VARIANT_FIXUPS-56]
+	_ = x[LC_TARGET_TRIPLE-57]
 }
 
-const _LoadCmd_name = "LC_SEGMENTLC_SYMTABLC_SYMSEGLC_THREADLC_UNIXTHREADLC_LOADFVMLIBLC_IDFVMLIBLC_IDENTLC_FVMFILELC_PREPAGELC_DYSYMTABLC_LOAD_DYLIBLC_ID_DYLIBLC_LOAD_DYLINKERLC_ID_DYLINKERLC_PREBOUND_DYLIBLC_ROUTINESLC_SUB_FRAMEWORKLC_SUB_UMBRELLALC_SUB_CLIENTLC_SUB_LIBRARYLC_TWOLEVEL_HINTSLC_PREBIND_CKSUMLC_SEGMENT_64LC_ROUTINES_64LC_UUIDLC_CODE_SIGNATURELC_SEGMENT_SPLIT_INFOLC_LAZY_LOAD_DYLIBLC_ENCRYPTION_INFOLC_DYLD_INFOLC_VERSION_MIN_MACOSXLC_VERSION_MIN_IPHONEOSLC_FUNCTION_STARTSLC_DYLD_ENVIRONMENTLC_DATA_IN_CODELC_SOURCE_VERSIONLC_DYLIB_CODE_SIGN_DRSLC_ENCRYPTION_INFO_64LC_LINKER_OPTIONLC_LINKER_OPTIMIZATION_HINTLC_VERSION_MIN_TVOSLC_VERSION_MIN_WATCHOSLC_NOTELC_BUILD_VERSIONLC_REQ_DYLDLC_LOAD_WEAK_DYLIBLC_RPATHLC_REEXPORT_DYLIBLC_DYLD_INFO_ONLYLC_LOAD_UPWARD_DYLIBLC_MAINLC_DYLD_EXPORTS_TRIELC_DYLD_CHAINED_FIXUPSLC_FILESET_ENTRY"
+const _LoadCmd_name = "LC_SEGMENTLC_SYMTABLC_SYMSEGLC_THREADLC_UNIXTHREADLC_LOADFVMLIBLC_IDFVMLIBLC_IDENTLC_FVMFILELC_PREPAGELC_DYSYMTABLC_LOAD_DYLIBLC_ID_DYLIBLC_LOAD_DYLINKERLC_ID_DYLINKERLC_PREBOUND_DYLIBLC_ROUTINESLC_SUB_FRAMEWORKLC_SUB_UMBRELLALC_SUB_CLIENTLC_SUB_LIBRARYLC_TWOLEVEL_HINTSLC_PREBIND_CKSUMLC_SEGMENT_64LC_ROUTINES_64LC_UUIDLC_CODE_SIGNATURELC_SEGMENT_SPLIT_INFOLC_LAZY_LOAD_DYLIBLC_ENCRYPTION_INFOLC_DYLD_INFOLC_VERSION_MIN_MACOSXLC_VERSION_MIN_IPHONEOSLC_FUNCTION_STARTSLC_DYLD_ENVIRONMENTLC_DATA_IN_CODELC_SOURCE_VERSIONLC_DYLIB_CODE_SIGN_DRSLC_ENCRYPTION_INFO_64LC_LINKER_OPTIONLC_LINKER_OPTIMIZATION_HINTLC_VERSION_MIN_TVOSLC_VERSION_MIN_WATCHOSLC_NOTELC_BUILD_VERSIONLC_ATOM_INFOLC_FUNCTION_VARIANTSLC_FUNCTION_VARIANT_FIXUPSLC_TARGET_TRIPLELC_REQ_DYLDLC_LOAD_WEAK_DYLIBLC_RPATHLC_REEXPORT_DYLIBLC_DYLD_INFO_ONLYLC_LOAD_UPWARD_DYLIBLC_MAINLC_DYLD_EXPORTS_TRIELC_DYLD_CHAINED_FIXUPSLC_FILESET_ENTRY"
 
 var _LoadCmd_map = map[LoadCmd]string{
 	1:          _LoadCmd_name[0:10],
@@ -113,16 +117,20 @@ var _LoadCmd_map = map[LoadCmd]string{
 	48:         _LoadCmd_name[624:646],
 	49:         _LoadCmd_name[646:653],
 	50:         _LoadCmd_name[653:669],
-	2147483648: _LoadCmd_name[669:680],
-	2147483672: _LoadCmd_name[680:698],
-	2147483676: _LoadCmd_name[698:706],
-	2147483679: _LoadCmd_name[706:723],
-	2147483682: _LoadCmd_name[723:740],
-	2147483683: _LoadCmd_name[740:760],
-	2147483688: _LoadCmd_name[760:767],
-	2147483699: _LoadCmd_name[767:787],
-	2147483700: _LoadCmd_name[787:809],
-	2147483701: _LoadCmd_name[809:825],
+	54:         _LoadCmd_name[669:681],
+	55:         _LoadCmd_name[681:701],
+	56:         _LoadCmd_name[701:727],
+	57:         _LoadCmd_name[727:743],
+	2147483648: _LoadCmd_name[743:754],
+	2147483672: _LoadCmd_name[754:772],
+	2147483676: _LoadCmd_name[772:780],
+	2147483679: _LoadCmd_name[780:797],
+	2147483682: _LoadCmd_name[797:814],
+	2147483683: _LoadCmd_name[814:834],
+	2147483688: _LoadCmd_name[834:841],
+	2147483699: _LoadCmd_name[841:861],
+	2147483700: _LoadCmd_name[861:883],
+	2147483701: _LoadCmd_name[883:899],
 }
 
 func (i LoadCmd) String() string {
@@ -0,0 +1,92 @@
+package types
+
+import "fmt"
+
+// A ThreadFlavor is the flavor of a thread state stored in a LC_THREAD or LC_UNIXTHREAD command.
+// Flavor values are only unique for a given CPU type.
+type ThreadFlavor uint32
+
+// x86 thread state flavors
+const (
+	X86_THREAD_STATE32    ThreadFlavor = 1
+	X86_FLOAT_STATE32     ThreadFlavor = 2
+	X86_EXCEPTION_STATE32 ThreadFlavor = 3
+	X86_THREAD_STATE64    ThreadFlavor = 4
+	X86_FLOAT_STATE64     ThreadFlavor = 5
+	X86_EXCEPTION_STATE64 ThreadFlavor = 6
+	X86_THREAD_STATE      ThreadFlavor = 7
+	X86_FLOAT_STATE       ThreadFlavor = 8
+	X86_EXCEPTION_STATE   ThreadFlavor = 9
+	X86_DEBUG_STATE32     ThreadFlavor = 10
+	X86_DEBUG_STATE64     ThreadFlavor = 11
+	X86_DEBUG_STATE       ThreadFlavor = 12
+	X86_THREAD_STATE_NONE ThreadFlavor = 13
+)
+
+// ARM thread state flavors
+const (
+	ARM_THREAD_STATE         ThreadFlavor = 1
+	ARM_VFP_STATE            ThreadFlavor = 2
+	ARM_EXCEPTION_STATE      ThreadFlavor = 3
+	ARM_DEBUG_STATE          ThreadFlavor = 4
+	ARM_THREAD_STATE_NONE    ThreadFlavor = 5
+	ARM_THREAD_STATE64       ThreadFlavor = 6
+	ARM_EXCEPTION_STATE64    ThreadFlavor = 7
+	ARM_THREAD_STATE32       ThreadFlavor = 9
+	ARM_EXCEPTION_STATE64_V2 ThreadFlavor = 10
+	ARM_DEBUG_STATE32        ThreadFlavor = 14
+	ARM_DEBUG_STATE64        ThreadFlavor = 15
+	ARM_NEON_STATE           ThreadFlavor = 16
+	ARM_NEON_STATE64         ThreadFlavor = 17
+	ARM_PAGEIN_STATE         ThreadFlavor = 27
+)
+
+var threadFlavorX86Strings = []IntName{
+	{uint32(X86_THREAD_STATE32), "x86_THREAD_STATE32"},
+	{uint32(X86_FLOAT_STATE32), "x86_FLOAT_STATE32"},
+	{uint32(X86_EXCEPTION_STATE32), "x86_EXCEPTION_STATE32"},
+	{uint32(X86_THREAD_STATE64), "x86_THREAD_STATE64"},
+	{uint32(X86_FLOAT_STATE64), "x86_FLOAT_STATE64"},
+	{uint32(X86_EXCEPTION_STATE64), "x86_EXCEPTION_STATE64"},
+	{uint32(X86_THREAD_STATE), "x86_THREAD_STATE"},
+	{uint32(X86_FLOAT_STATE), "x86_FLOAT_STATE"},
+	{uint32(X86_EXCEPTION_STATE), "x86_EXCEPTION_STATE"},
+	{uint32(X86_DEBUG_STATE32), "x86_DEBUG_STATE32"},
+	{uint32(X86_DEBUG_STATE64), "x86_DEBUG_STATE64"},
+	{uint32(X86_DEBUG_STATE), "x86_DEBUG_STATE"},
+	{uint32(X86_THREAD_STATE_NONE), "THREAD_STATE_NONE"},
+}
+
+var threadFlavorARMStrings = []IntName{
+	{uint32(ARM_THREAD_STATE), "ARM_THREAD_STATE"},
+	{uint32(ARM_VFP_STATE), "ARM_VFP_STATE"},
+	{uint32(ARM_EXCEPTION_STATE), "ARM_EXCEPTION_STATE"},
+	{uint32(ARM_DEBUG_STATE), "ARM_DEBUG_STATE"},
+	{uint32(ARM_THREAD_STATE_NONE), "THREAD_STATE_NONE"},
+	{uint32(ARM_THREAD_STATE64), "ARM_THREAD_STATE64"},
+	{uint32(ARM_EXCEPTION_STATE64), "ARM_EXCEPTION_STATE64"},
+	{uint32(ARM_THREAD_STATE32), "ARM_THREAD_STATE32"},
+	{uint32(ARM_EXCEPTION_STATE64_V2), "ARM_EXCEPTION_STATE64_V2"},
+	{uint32(ARM_DEBUG_STATE32), "ARM_DEBUG_STATE32"},
+	{uint32(ARM_DEBUG_STATE64), "ARM_DEBUG_STATE64"},
+	{uint32(ARM_NEON_STATE), "ARM_NEON_STATE"},
+	{uint32(ARM_NEON_STATE64), "ARM_NEON_STATE64"},
+	{uint32(ARM_PAGEIN_STATE), "ARM_PAGEIN_STATE"},
+}
+
+func (f ThreadFlavor) String(cpu CPU) string {
+	switch cpu {
+	case CPU386, CPUAmd64:
+		return StringName(uint32(f), threadFlavorX86Strings, false)
+	case CPUArm, CPUArm64, CPUArm6432:
+		return StringName(uint32(f), threadFlavorARMStrings, false)
+	}
+	return fmt.Sprintf("flavor(%d)", f)
+}
+
+// A ThreadStateHdr precedes each thread state in a thread command and
+// in the x86 generic (x86_THREAD_STATE etc.) states.
+type ThreadStateHdr struct {
+	Flavor ThreadFlavor
+	Count  uint32 // count of uint32s in the state
+}
@@ -137,6 +137,44 @@ const (
 	KindAbsJumpTable32 DiceKind = 0x0005
 )
 
+// LOHKind is the kind of a linker optimization hint.
+type LOHKind uint64
+
+const (
+	LOH_ARM64_ADRP_ADRP        LOHKind = 1
+	LOH_ARM64_ADRP_LDR         LOHKind = 2
+	LOH_ARM64_ADRP_ADD_LDR     LOHKind = 3
+	LOH_ARM64_ADRP_LDR_GOT_LDR LOHKind = 4
+	LOH_ARM64_ADRP_ADD_STR     LOHKind = 5
+	LOH_ARM64_ADRP_LDR_GOT_STR LOHKind = 6
+	LOH_ARM64_ADRP_ADD         LOHKind = 7
+	LOH_ARM64_ADRP_LDR_GOT     LOHKind = 8
+)
+
+var lohKindStrings = []IntName{
+	{uint32(LOH_ARM64_ADRP_ADRP), "LOH_ARM64_ADRP_ADRP"},
+	{uint32(LOH_ARM64_ADRP_LDR), "LOH_ARM64_ADRP_LDR"},
+	{uint32(LOH_ARM64_ADRP_ADD_LDR), "LOH_ARM64_ADRP_ADD_LDR"},
+	{uint32(LOH_ARM64_ADRP_LDR_GOT_LDR), "LOH_ARM64_ADRP_LDR_GOT_LDR"},
+	{uint32(LOH_ARM64_ADRP_ADD_STR), "LOH_ARM64_ADRP_ADD_STR"},
+	{uint32(LOH_ARM64_ADRP_LDR_GOT_STR), "LOH_ARM64_ADRP_LDR_GOT_STR"},
+	{uint32(LOH_ARM64_ADRP_ADD), "LOH_ARM64_ADRP_ADD"},
+	{uint32(LOH_ARM64_ADRP_LDR_GOT), "LOH_ARM64_ADRP_LDR_GOT"},
+}
+
+func (k LOHKind) String() string { return StringName(uint32(k), lohKindStrings, false) }
+
+// ArgCount returns the number of instruction addresses a hint of this kind references.
+func (k LOHKind) ArgCount() int {
+	switch k {
+	case LOH_ARM64_ADRP_ADRP, LOH_ARM64_ADRP_LDR, LOH_ARM64_ADRP_ADD, LOH_ARM64_ADRP_LDR_GOT:
+		return 2
+	case LOH_ARM64_ADRP_ADD_LDR, LOH_ARM64_ADRP_LDR_GOT_LDR, LOH_ARM64_ADRP_ADD_STR, LOH_ARM64_ADRP_LDR_GOT_STR:
+		return 3
+	}
+	return 0
+}
+
 type Function struct {
 	Name      string
 	StartAddr uint64
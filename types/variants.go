@@ -0,0 +1,91 @@
+package types
+
+import "fmt"
+
+// FunctionVariantsKind is the kind of a runtime table in the LC_FUNCTION_VARIANTS payload.
+type FunctionVariantsKind uint32
+
+const (
+	FunctionVariantsPerProcess FunctionVariantsKind = 1
+	FunctionVariantsSystemWide FunctionVariantsKind = 2
+	FunctionVariantsArm64      FunctionVariantsKind = 3
+	FunctionVariantsX86_64     FunctionVariantsKind = 4
+)
+
+func (k FunctionVariantsKind) String() string {
+	switch k {
+	case FunctionVariantsPerProcess:
+		return "per-process"
+	case FunctionVariantsSystemWide:
+		return "system-wide"
+	case FunctionVariantsArm64:
+		return "arm64"
+	case FunctionVariantsX86_64:
+		return "x86_64"
+	}
+	return fmt.Sprintf("FunctionVariantsKind(%d)", k)
+}
+
+// A FunctionVariantsEntry is a single variant in a function variants runtime table.
+// The entries of a table are ordered from most to least specific; the last one is the default.
+type FunctionVariantsEntry struct {
+	Impl        uint32   // bit 31 set means the low bits are the index of another table
+	FlagBitNums [4]uint8 // feature flags that must all be set for this variant
+}
+
+// AnotherTable returns true if the entry points at another table instead of an implementation.
+func (e FunctionVariantsEntry) AnotherTable() bool {
+	return e.Impl&0x80000000 != 0
+}
+
+// ImplOffset returns the offset from the mach header of the implementation,
+// or the table index if AnotherTable is true.
+func (e FunctionVariantsEntry) ImplOffset() uint32 {
+	return e.Impl & 0x7fffffff
+}
+
+func (e FunctionVariantsEntry) String() string {
+	if e.AnotherTable() {
+		return fmt.Sprintf("table=%d flags=%v", e.ImplOffset(), e.FlagBitNums)
+	}
+	return fmt.Sprintf("impl=%#x flags=%v", e.ImplOffset(), e.FlagBitNums)
+}
+
+// A FunctionVariantsTable is a runtime table in the LC_FUNCTION_VARIANTS payload.
+type FunctionVariantsTable struct {
+	Kind    FunctionVariantsKind
+	Entries []FunctionVariantsEntry
+}
+
+// A FunctionVariantFixup is an entry in the LC_FUNCTION_VARIANT_FIXUPS payload;
+// it describes a pointer that must be set to the best variant of a function.
+type FunctionVariantFixup struct {
+	SegOffset uint32
+	Info      uint32
+}
+
+// SegIndex returns the index of the segment containing the pointer.
+func (f FunctionVariantFixup) SegIndex() uint32 { return f.Info & 0xf }
+
+// VariantIndex returns the index of the function variants table to use.
+func (f FunctionVariantFixup) VariantIndex() uint32 { return (f.Info >> 4) & 0xff }
+
+// PacAuth returns true if the pointer is signed.
+func (f FunctionVariantFixup) PacAuth() bool { return (f.Info>>12)&1 != 0 }
+
+// PacAddress returns true if the pointer signature uses address diversity.
+func (f FunctionVariantFixup) PacAddress() bool { return (f.Info>>13)&1 != 0 }
+
+// PacKey returns the PAC key used to sign the pointer.
+func (f FunctionVariantFixup) PacKey() uint32 { return (f.Info >> 14) & 0x3 }
+
+// PacDiversity returns the PAC diversity used to sign the pointer.
+func (f FunctionVariantFixup) PacDiversity() uint32 { return f.Info >> 16 }
+
+func (f FunctionVariantFixup) String() string {
+	var pac string
+	if f.PacAuth() {
+		pac = fmt.Sprintf(" auth(key=%d, addr=%t, diversity=%#x)", f.PacKey(), f.PacAddress(), f.PacDiversity())
+	}
+	return fmt.Sprintf("seg=%d offset=%#x variant=%d%s", f.SegIndex(), f.SegOffset, f.VariantIndex(), pac)
+}
@@ -0,0 +1,110 @@
+package macho
+
+import (
+	"fmt"
+
+	"github.com/blacktop/go-macho/pkg/unwind"
+	"github.com/blacktop/go-macho/types"
+)
+
+// UnwindInfo parses the compact unwind info in __TEXT,__unwind_info.
+func (f *File) UnwindInfo() (*unwind.Info, error) {
+	sec := f.Section("__TEXT", "__unwind_info")
+	if sec == nil {
+		return nil, fmt.Errorf("no __TEXT.__unwind_info section found")
+	}
+	text := f.Segment("__TEXT")
+	if text == nil {
+		return nil, fmt.Errorf("no __TEXT segment found")
+	}
+	dat, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read __unwind_info data: %v", err)
+	}
+	info, err := unwind.Parse(dat, text.Addr, f.ByteOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse __unwind_info: %v", err)
+	}
+	return info, nil
+}
+
+// EHFrame parses the DWARF call frame information in __TEXT,__eh_frame.
+// Relative pointers are resolved against the VM addresses of the section
+// and segments, and indirect ones (e.g. personality GOT slots) are read.
+func (f *File) EHFrame() (*unwind.EHFrame, error) {
+	sec := f.Section("__TEXT", "__eh_frame")
+	if sec == nil {
+		return nil, fmt.Errorf("no __TEXT.__eh_frame section found")
+	}
+	dat, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read __eh_frame data: %v", err)
+	}
+	cfg := unwind.EHFrameConfig{
+		Addr:        sec.Addr,
+		PointerSize: int(f.pointerSize()),
+		ReadPointer: f.GetPointerAtAddress,
+	}
+	if !f.is64bit() {
+		cfg.ReadPointer = func(addr uint64) (uint64, error) {
+			ptr, err := f.GetPointerAtAddress(addr)
+			return ptr & 0xffffffff, err
+		}
+	}
+	if text := f.Segment("__TEXT"); text != nil {
+		cfg.TextAddr = text.Addr
+	}
+	if data := f.Segment("__DATA"); data != nil {
+		cfg.DataAddr = data.Addr
+	}
+	eh, err := unwind.ParseEHFrame(dat, f.ByteOrder, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse __eh_frame: %v", err)
+	}
+	return eh, nil
+}
+
+// A FunctionUnwind is a function (see GetFunctions) and its compact unwind info.
+type FunctionUnwind struct {
+	types.Function
+	Unwind  *unwind.Function // nil if no unwind entry covers the function
+	Decoded unwind.Unwind
+	// FDE is the __eh_frame entry of functions whose encoding is ModeDWARF
+	FDE *unwind.FDE
+	// BoundsMatch is true if the unwind entry starts at the function's start
+	// and covers all of it. ld merges adjacent functions with the same
+	// encoding, so an entry may cover several functions.
+	BoundsMatch bool
+}
+
+// GetFunctionsUnwind returns the functions of LC_FUNCTION_STARTS with their
+// decoded compact unwind encodings, so the function bounds of both can be
+// cross-checked. Functions that use DWARF unwind info get their FDE.
+func (f *File) GetFunctionsUnwind() ([]FunctionUnwind, error) {
+	info, err := f.UnwindInfo()
+	if err != nil {
+		return nil, err
+	}
+	var eh *unwind.EHFrame
+	var funcs []FunctionUnwind
+	for _, fn := range f.GetFunctions() {
+		fu := FunctionUnwind{Function: fn}
+		if u, ok := info.Lookup(fn.StartAddr); ok {
+			fu.Unwind = u
+			fu.BoundsMatch = u.Start == fn.StartAddr && fn.EndAddr <= u.End
+			if fu.Decoded, err = u.Encoding.Decode(f.CPU); err != nil {
+				return nil, err
+			}
+			if fu.Decoded.Mode == unwind.ModeDWARF {
+				if eh == nil {
+					if eh, err = f.EHFrame(); err != nil {
+						return nil, err
+					}
+				}
+				fu.FDE, _ = eh.FDEAt(uint64(fu.Decoded.DWARFOffset))
+			}
+		}
+		funcs = append(funcs, fu)
+	}
+	return funcs, nil
+}